@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go-peek/internal/app"
+	"go-peek/pkg/anonymizer"
+	"go-peek/pkg/persist"
+	"os"
+	"path"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// anonymizerCmd represents the anonymizer command
+var anonymizerCmd = &cobra.Command{
+	Use:   "anonymizer [alias...]",
+	Short: "Export, import and reverse lookup of persistent alias table",
+	Long: `Manage alias table of providentia or enrich stage.
+
+Import is done first, then export. Arguments are resolved from alias to original name.
+Alias table lives in badger store of source stage, so that stage must not be running.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer app.Catch(logger)
+
+		workdir := viper.GetString("work.dir")
+		if workdir == "" {
+			app.Throw("app init", errors.New("missing working directory"), logger)
+		}
+		source := viper.GetString(cmd.Name() + ".source")
+		persist, err := persist.NewBadger(persist.Config{
+			Directory: path.Join(workdir, source, "badger"),
+			Logger:    logger,
+		})
+		app.Throw("persist setup", err, logger)
+		defer persist.Close()
+
+		m, err := anonymizer.NewMapper(anonymizer.Config{Persist: persist, Logger: logger})
+		app.Throw("anonymizer mapper init", err, logger)
+
+		if src := viper.GetString(cmd.Name() + ".import"); src != "" {
+			aliases, err := anonymizer.LoadAliases(src)
+			app.Throw("alias table read", err, logger)
+			app.Throw("alias table import", m.Import(aliases), logger)
+			logger.WithFields(logrus.Fields{
+				"path":   src,
+				"source": source,
+				"count":  len(aliases),
+			}).Info("alias table imported")
+		}
+
+		if dest := viper.GetString(cmd.Name() + ".export"); dest != "" {
+			aliases := m.Export()
+			format := viper.GetString(cmd.Name() + ".format")
+			if dest == "-" {
+				app.Throw("alias table export", aliases.Write(os.Stdout, format), logger)
+			} else {
+				f, err := os.Create(dest)
+				app.Throw("alias table export", err, logger)
+				err = aliases.Write(f, format)
+				f.Close()
+				app.Throw("alias table export", err, logger)
+				logger.WithFields(logrus.Fields{
+					"path":   dest,
+					"source": source,
+					"count":  len(aliases),
+				}).Info("alias table exported")
+			}
+		}
+
+		for _, alias := range args {
+			name, ok := m.Reverse(alias)
+			if !ok {
+				logger.WithField("alias", alias).Warn("alias not found")
+				continue
+			}
+			fmt.Printf("%s\t%s\n", alias, name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(anonymizerCmd)
+
+	pFlags := anonymizerCmd.PersistentFlags()
+
+	pFlags.String("source", "providentia", "Stage whose alias table is used, for example providentia or enrich")
+	viper.BindPFlag(anonymizerCmd.Name()+".source", pFlags.Lookup("source"))
+
+	pFlags.String("export", "", "Export alias table to file, dash for stdout")
+	viper.BindPFlag(anonymizerCmd.Name()+".export", pFlags.Lookup("export"))
+
+	pFlags.String("format", "json", "Export format, json or csv")
+	viper.BindPFlag(anonymizerCmd.Name()+".format", pFlags.Lookup("format"))
+
+	pFlags.String("import", "", "Import alias table from JSON or CSV file, format is deduced from extension")
+	viper.BindPFlag(anonymizerCmd.Name()+".import", pFlags.Lookup("import"))
+}
+
+// newAnonymizerMapper sets up alias mapper with deterministic secret and optional alias table import
+func newAnonymizerMapper(prefix string, persist *persist.Badger) (*anonymizer.Mapper, error) {
+	m, err := anonymizer.NewMapper(anonymizer.Config{
+		Persist: persist,
+		Logger:  logger,
+		Secret:  viper.GetString(prefix + ".anonymizer.secret"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	src := viper.GetString(prefix + ".anonymizer.import")
+	if src == "" {
+		return m, nil
+	}
+	aliases, err := anonymizer.LoadAliases(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Import(aliases); err != nil {
+		return nil, err
+	}
+	logger.WithFields(logrus.Fields{
+		"path":  src,
+		"count": len(aliases),
+	}).Info("alias table imported")
+	return m, nil
+}
@@ -24,6 +24,7 @@ var archiveCmd = &cobra.Command{
 	Short: "Archive logs from kafka topics",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
@@ -33,6 +34,11 @@ var archiveCmd = &cobra.Command{
 			app.Throw("init", fmt.Errorf("Please configure output folder"), logger)
 		}
 
+		topics := viper.GetStringSlice(cmd.Name() + ".input.kafka.topics")
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
 		ctxReader, cancelReader := context.WithCancel(context.Background())
 
 		var wg sync.WaitGroup
@@ -42,7 +48,8 @@ var archiveCmd = &cobra.Command{
 			Name:          cmd.Name() + " consumer",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics:        viper.GetStringSlice(cmd.Name() + ".input.kafka.topics"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
 			Ctx:           ctxReader,
 			OffsetMode:    kafkaOffset,
 		})
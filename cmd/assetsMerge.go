@@ -30,6 +30,7 @@ var assetsMergeCmd = &cobra.Command{
 	Short: "merge providentia and vsphere asset feeds",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
@@ -44,6 +45,14 @@ var assetsMergeCmd = &cobra.Command{
 		var wg sync.WaitGroup
 		defer wg.Wait()
 
+		topics := []string{
+			viper.GetString(cmd.Name() + ".input.kafka.topic_assets_providentia"),
+			viper.GetString(cmd.Name() + ".input.kafka.topic_assets_vcenter"),
+		}
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
@@ -52,12 +61,10 @@ var assetsMergeCmd = &cobra.Command{
 			Name:          cmd.Name() + " event stream",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics: []string{
-				viper.GetString(cmd.Name() + ".input.kafka.topic_assets_providentia"),
-				viper.GetString(cmd.Name() + ".input.kafka.topic_assets_vcenter"),
-			},
-			Ctx:        ctx,
-			OffsetMode: kafkaOffset,
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
+			Ctx:           ctx,
+			OffsetMode:    kafkaOffset,
 		})
 		app.Throw(cmd.Name()+" asset stream setup", err, logger)
 
@@ -65,8 +72,12 @@ var assetsMergeCmd = &cobra.Command{
 		defer close(tx)
 
 		producer, err := kafkaOutput.NewProducer(&kafkaOutput.Config{
-			Brokers: viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
-			Logger:  logger,
+			Brokers:  viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+			Security: app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+			Logger:   logger,
+			Headers:  viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+			Stage:    cmd.Name(),
+			Version:  app.BuildVersion(),
 		})
 		app.Throw("Sarama producer init", err, logger)
 		producer.Feed(tx, cmd.Name()+" producer", ctx, func(m consumer.Message) string {
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-peek/internal/app"
+	"go-peek/pkg/correlate"
+	kafkaIngest "go-peek/pkg/ingest/kafka"
+	"go-peek/pkg/metrics"
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/meta"
+	kafkaOutput "go-peek/pkg/outputs/kafka"
+	"go-peek/pkg/persist"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// correlateCmd represents the correlate command
+var correlateCmd = &cobra.Command{
+	Use:   "correlate",
+	Short: "Group emitted alerts into host-centric incidents",
+	Long: `Consume fast-tracked events from enrichment emit topic and group them per blue team target asset.
+Incident stays open while alerts keep arriving within window and tracks ATT&CK tactics reached.
+Open, update and close records are sent to incident topic.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+
+		defer app.Catch(logger)
+		defer app.Done(cmd.Name(), start, logger)
+
+		workdir := viper.GetString("work.dir")
+		if workdir == "" {
+			app.Throw("app init", errors.New("missing working directory"), logger)
+		}
+		workdir = path.Join(workdir, cmd.Name())
+
+		topics := []string{viper.GetString(cmd.Name() + ".input.kafka.topic_emit")}
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
+		ctxPersist, cancelPersist := context.WithCancel(context.Background())
+		persist, err := persist.NewBadger(persist.Config{
+			Directory:     path.Join(workdir, "badger"),
+			IntervalGC:    1 * time.Minute,
+			RunValueLogGC: true,
+			WaitGroup:     &wg,
+			Ctx:           ctxPersist,
+			Logger:        logger,
+		})
+		app.Throw("persist setup", err, logger)
+		defer persist.Close()
+		defer cancelPersist()
+
+		correlator, err := correlate.NewCorrelator(correlate.Config{
+			Persist: persist,
+			Window:  viper.GetDuration(cmd.Name() + ".window"),
+		})
+		app.Throw("correlator init", err, logger)
+		logger.WithField("open", correlator.Open).Info("loaded open incidents")
+
+		ctxReader, cancelReader := context.WithCancel(context.Background())
+		defer cancelReader()
+
+		logger.Info("Creating kafka consumer for emit stream")
+		input, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
+			Name:          cmd.Name() + " emit stream",
+			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
+			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
+			Ctx:           ctxReader,
+			OffsetMode:    kafkaOffset,
+			Logger:        logger,
+		})
+		app.Throw(cmd.Name()+" emit stream setup", err, logger)
+
+		tx := make(chan consumer.Message, 0)
+		defer close(tx)
+
+		producer, err := kafkaOutput.NewProducer(&kafkaOutput.Config{
+			Brokers:  viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+			Security: app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+			Logger:   logger,
+			Headers:  viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+			Stage:    cmd.Name(),
+			Version:  app.BuildVersion(),
+		})
+		app.Throw("Sarama producer init", err, logger)
+		topic := viper.GetString(cmd.Name() + ".output.kafka.topic_incidents")
+		producer.Feed(tx, cmd.Name()+" producer", context.TODO(), func(m consumer.Message) string {
+			return topic
+		}, &wg)
+
+		stdout := viper.GetBool(cmd.Name() + ".stdout")
+		send := func(incident correlate.Incident) {
+			encoded, err := json.Marshal(incident)
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"raw": incident,
+					"err": err,
+				}).Error("unable to encode json")
+				return
+			}
+			if stdout {
+				os.Stdout.Write(append(encoded, []byte("\n")...))
+			}
+			metrics.EventsOut.WithLabelValues(cmd.Name(), string(incident.State)).Inc()
+			tx <- consumer.Message{
+				Data:   encoded,
+				Time:   incident.Timestamp,
+				Key:    incident.ID,
+				Source: cmd.Name(),
+			}
+		}
+
+		chTerminate := make(chan os.Signal, 1)
+		signal.Notify(chTerminate, os.Interrupt, syscall.SIGTERM)
+
+		expire := time.NewTicker(10 * time.Second)
+		defer expire.Stop()
+
+		report := time.NewTicker(viper.GetDuration(cmd.Name() + ".log.interval"))
+		defer report.Stop()
+
+	loop:
+		for {
+			select {
+			case <-report.C:
+				logger.Infof("%+v", correlator.Counts)
+				if err := correlator.Persist(); err != nil {
+					logger.WithField("err", err).Error("unable to persist correlation state")
+				}
+			case <-expire.C:
+				for _, incident := range correlator.Expire() {
+					send(incident)
+				}
+			case <-chTerminate:
+				break loop
+			case msg, ok := <-input.Messages():
+				if !ok {
+					break loop
+				}
+				var obj struct {
+					GameMeta *meta.GameAsset `json:"GameMeta"`
+				}
+				if err := json.Unmarshal(msg.Data, &obj); err != nil {
+					metrics.ParseErrors.WithLabelValues(cmd.Name(), "emit").Inc()
+					logger.WithFields(logrus.Fields{
+						"raw":    string(msg.Data),
+						"source": msg.Source,
+						"err":    err,
+					}).Error("unable to parse emitted event")
+					continue loop
+				}
+				// suppression summaries and other records without meta are not alerts
+				if obj.GameMeta == nil {
+					continue loop
+				}
+				metrics.EventsIn.WithLabelValues(cmd.Name(), obj.GameMeta.EventType).Inc()
+				incidents, err := correlator.Add(msg.Time, obj.GameMeta)
+				if err != nil {
+					logger.WithFields(logrus.Fields{
+						"raw": string(msg.Data),
+						"err": err,
+					}).Debug("alert not correlated")
+					continue loop
+				}
+				for _, incident := range incidents {
+					send(incident)
+				}
+			}
+		}
+		if err := correlator.Persist(); err != nil {
+			logger.WithField("err", err).Error("unable to persist correlation state")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(correlateCmd)
+
+	pFlags := correlateCmd.PersistentFlags()
+
+	pFlags.Duration("window", 30*time.Minute, "Close incident if no new alerts arrive for target within this period.")
+	viper.BindPFlag(correlateCmd.Name()+".window", pFlags.Lookup("window"))
+
+	pFlags.Bool("stdout", false, "Write incident records to stdout. Mostly for debug.")
+	viper.BindPFlag(correlateCmd.Name()+".stdout", pFlags.Lookup("stdout"))
+
+	app.RegisterLogging(correlateCmd.Name(), pFlags)
+	app.RegisterInputKafkaCorrelate(correlateCmd.Name(), pFlags)
+	app.RegisterOutputKafkaCorrelate(correlateCmd.Name(), pFlags)
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-peek/internal/app"
+	"go-peek/pkg/deadletter"
+	"go-peek/pkg/ingest/kafka"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dlqCmd represents the dlq command
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect, summarize and re-inject dead-letter messages",
+	Long: `Read envelopes from dead-letter topic written by enrich and preprocess stages.
+
+Topic is read from oldest to newest message without consumer group, so reading never moves offsets.
+Filters apply to all subcommands.`,
+}
+
+// dlqInspectCmd represents the dlq inspect command
+var dlqInspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "List dead-letter envelopes",
+	Run: func(cmd *cobra.Command, args []string) {
+		defer app.Catch(logger)
+
+		format := viper.GetString(dlqCmd.Name() + ".inspect.format")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		if format == "table" {
+			fmt.Fprintln(w, "position\ttime\tstage\treason\torigin\terror")
+		}
+		readDeadLetters(func(r deadletter.Record) {
+			e := r.Envelope
+			switch format {
+			case "json":
+				encoded, err := json.Marshal(e)
+				app.Throw("envelope encode", err, logger)
+				fmt.Println(string(encoded))
+			case "payload":
+				fmt.Println(string(e.Payload))
+			default:
+				fmt.Fprintln(w, strings.Join([]string{
+					strconv.Itoa(int(r.Partition)) + ":" + strconv.FormatInt(r.Offset, 10),
+					e.Timestamp.Format(time.RFC3339),
+					e.Stage,
+					e.Reason,
+					dlqOrigin(e),
+					e.Error,
+				}, "\t"))
+			}
+		})
+		w.Flush()
+	},
+}
+
+// dlqSummaryCmd represents the dlq summary command
+var dlqSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Count dead-letter envelopes by error class",
+	Long: `Count dead-letter envelopes by stage, reason and error text.
+Numbers and quoted values are masked in error text, so errors that only differ by offsets or field values are grouped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer app.Catch(logger)
+
+		summary := make(deadletter.Summary)
+		readDeadLetters(func(r deadletter.Record) {
+			summary.Add(r.Envelope)
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, row := range summary.CSVFormat(true) {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		w.Flush()
+	},
+}
+
+// dlqReinjectCmd represents the dlq reinject command
+var dlqReinjectCmd = &cobra.Command{
+	Use:   "reinject",
+	Short: "Write original payloads back to kafka",
+	Long: `Write payloads of matching envelopes back to their original topic with original key.
+
+Syslog server input has no original topic, target topic must be given for it.
+Re-injected messages that fail again end up in dead-letter topic as new envelopes,
+so use --since to avoid handling same envelopes twice.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		defer app.Catch(logger)
+
+		injector, err := deadletter.NewInjector(deadletter.InjectConfig{
+			Brokers:  viper.GetStringSlice(dlqCmd.Name() + ".input.kafka.brokers"),
+			Security: app.KafkaSecurity(dlqCmd.Name() + ".input.kafka"),
+			Target:   viper.GetString(dlqCmd.Name() + ".reinject.target"),
+		})
+		app.Throw("dead-letter injector init", err, logger)
+		defer injector.Close()
+
+		dryRun := viper.GetBool(dlqCmd.Name() + ".reinject.dry_run")
+		var injected, skipped int
+		topics := make(map[string]int)
+		readDeadLetters(func(r deadletter.Record) {
+			e := r.Envelope
+			topic, err := injector.Topic(e)
+			if err != nil {
+				logger.WithFields(logrus.Fields{
+					"partition": r.Partition,
+					"offset":    r.Offset,
+					"err":       err,
+				}).Warn("skipping envelope")
+				skipped++
+				return
+			}
+			if !dryRun {
+				app.Throw("dead-letter reinject", injector.Inject(e), logger)
+			}
+			topics[topic]++
+			injected++
+		})
+		for topic, count := range topics {
+			fmt.Printf("%s\t%d\n", topic, count)
+		}
+		logger.WithFields(logrus.Fields{
+			"injected": injected,
+			"skipped":  skipped,
+			"dry_run":  dryRun,
+		}).Info("dead-letter reinject done")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dlqCmd)
+	dlqCmd.AddCommand(dlqInspectCmd)
+	dlqCmd.AddCommand(dlqSummaryCmd)
+	dlqCmd.AddCommand(dlqReinjectCmd)
+
+	pFlags := dlqCmd.PersistentFlags()
+
+	pFlags.StringSlice(app.FlagInKafkaBrokers, []string{"localhost:9092"}, "List of kafka brokers")
+	viper.BindPFlag(dlqCmd.Name()+".input.kafka.brokers", pFlags.Lookup(app.FlagInKafkaBrokers))
+
+	pFlags.String("topic", "", "Dead-letter topic")
+	viper.BindPFlag(dlqCmd.Name()+".input.kafka.topic", pFlags.Lookup("topic"))
+
+	pFlags.String("stage", "", "Only envelopes from this stage, for example enrich or preprocess")
+	viper.BindPFlag(dlqCmd.Name()+".filter.stage", pFlags.Lookup("stage"))
+
+	pFlags.String("reason", "", "Only envelopes with this failure reason: unmapped, decode, enrich, encode or parse")
+	viper.BindPFlag(dlqCmd.Name()+".filter.reason", pFlags.Lookup("reason"))
+
+	pFlags.String("match", "", "Only envelopes whose error contains this text")
+	viper.BindPFlag(dlqCmd.Name()+".filter.match", pFlags.Lookup("match"))
+
+	pFlags.String("since", "", "Only envelopes written at or after this time. RFC3339 timestamp or duration ago, e.g. 2h.")
+	viper.BindPFlag(dlqCmd.Name()+".filter.since", pFlags.Lookup("since"))
+
+	app.RegisterInputKafkaSecurity(dlqCmd.Name(), pFlags)
+
+	dlqInspectCmd.Flags().String("format", "table", "Output format. table, json for envelopes as JSON lines, or payload for raw payloads only.")
+	viper.BindPFlag(dlqCmd.Name()+".inspect.format", dlqInspectCmd.Flags().Lookup("format"))
+
+	dlqReinjectCmd.Flags().String("target", "", "Write all payloads to this topic instead of original ones")
+	viper.BindPFlag(dlqCmd.Name()+".reinject.target", dlqReinjectCmd.Flags().Lookup("target"))
+
+	dlqReinjectCmd.Flags().Bool("dry-run", false, "Only print how many payloads would be written to each topic")
+	viper.BindPFlag(dlqCmd.Name()+".reinject.dry_run", dlqReinjectCmd.Flags().Lookup("dry-run"))
+}
+
+// readDeadLetters passes envelopes that match filter flags to fn, malformed messages are logged and skipped
+func readDeadLetters(fn func(deadletter.Record)) {
+	since, err := kafka.ParseSeekTime(viper.GetString(dlqCmd.Name()+".filter.since"), time.Now())
+	app.Throw("dead-letter since filter", err, logger)
+	filter := deadletter.Filter{
+		Stage:  viper.GetString(dlqCmd.Name() + ".filter.stage"),
+		Reason: viper.GetString(dlqCmd.Name() + ".filter.reason"),
+		Error:  viper.GetString(dlqCmd.Name() + ".filter.match"),
+		Since:  since,
+	}
+	var matched, malformed int
+	err = deadletter.Read(context.Background(), deadletter.ReadConfig{
+		Brokers:  viper.GetStringSlice(dlqCmd.Name() + ".input.kafka.brokers"),
+		Topic:    viper.GetString(dlqCmd.Name() + ".input.kafka.topic"),
+		Security: app.KafkaSecurity(dlqCmd.Name() + ".input.kafka"),
+	}, func(r deadletter.Record) error {
+		if r.Err != nil {
+			logger.WithFields(logrus.Fields{
+				"partition": r.Partition,
+				"offset":    r.Offset,
+				"err":       r.Err,
+			}).Warn("malformed dead-letter envelope")
+			malformed++
+			return nil
+		}
+		if !filter.Match(r.Envelope) {
+			return nil
+		}
+		matched++
+		fn(r)
+		return nil
+	})
+	app.Throw("dead-letter read", err, logger)
+	logger.WithFields(logrus.Fields{
+		"matched":   matched,
+		"malformed": malformed,
+	}).Debug("dead-letter topic read")
+}
+
+func dlqOrigin(e deadletter.Envelope) string {
+	if e.Topic == "" || e.Offset < 0 {
+		return e.Input
+	}
+	return e.Topic + ":" + strconv.FormatInt(e.Partition, 10) + ":" + strconv.FormatInt(e.Offset, 10)
+}
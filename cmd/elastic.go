@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"go-peek/internal/app"
 	"go-peek/pkg/ingest/kafka"
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/models/consumer"
 	"go-peek/pkg/outputs/elastic"
 	"os"
@@ -25,10 +26,16 @@ var elasticCmd = &cobra.Command{
 	Short: "Consume messages from kafka and ship to elastic",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
 
+		topics := viper.GetStringSlice(cmd.Name() + ".input.kafka.topics")
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
 		ctxReader, cancelReader := context.WithCancel(context.Background())
 
 		var wg sync.WaitGroup
@@ -38,7 +45,8 @@ var elasticCmd = &cobra.Command{
 			Name:          cmd.Name() + " consumer",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics:        viper.GetStringSlice(cmd.Name() + ".input.kafka.topics"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
 			Ctx:           ctxReader,
 			OffsetMode:    kafkaOffset,
 			Logger:        logger,
@@ -96,12 +104,19 @@ var elasticCmd = &cobra.Command{
 				if !ok {
 					break loop
 				}
+				metrics.EventsIn.WithLabelValues(cmd.Name(), msg.Source).Inc()
 				tx <- *msg
 				count++
 			case <-chTerminate:
 				break loop
 			case <-report.C:
 				logger.WithFields(logrus.Fields{"forwarded": count}).Debug("elastic bulk")
+				stats := writer.Stats()
+				metrics.ElasticBulk.WithLabelValues("flushed").Set(float64(stats.Flushed))
+				metrics.ElasticBulk.WithLabelValues("committed").Set(float64(stats.Committed))
+				metrics.ElasticBulk.WithLabelValues("indexed").Set(float64(stats.Indexed))
+				metrics.ElasticBulk.WithLabelValues("succeeded").Set(float64(stats.Succeeded))
+				metrics.ElasticBulk.WithLabelValues("failed").Set(float64(stats.Failed))
 			}
 		}
 		cancelReader()
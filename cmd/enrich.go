@@ -4,14 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go-peek/internal/app"
+	"go-peek/pkg/anonymizer"
+	"go-peek/pkg/deadletter"
 	"go-peek/pkg/enrich"
 	"go-peek/pkg/intel/mitre"
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/mitremeerkat"
 	"go-peek/pkg/models/consumer"
 	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
 	"go-peek/pkg/persist"
 	"go-peek/pkg/providentia"
+	"net"
 	"os"
 	"os/signal"
 	"path"
@@ -23,7 +29,6 @@ import (
 	kafkaIngest "go-peek/pkg/ingest/kafka"
 	kafkaOutput "go-peek/pkg/outputs/kafka"
 
-	"github.com/markuskont/go-sigma-rule-engine"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -35,6 +40,7 @@ var enrichCmd = &cobra.Command{
 	Short: "Enrich events with game metadata",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
@@ -50,15 +56,22 @@ var enrichCmd = &cobra.Command{
 			viper.GetStringSlice(cmd.Name() + ".input.kafka.topic_map"),
 		)
 		app.Throw("topic map parse", err, logger)
+		if seekConsumerGroup(cmd.Name(), topics.Topics()) {
+			return
+		}
+
+		atLeastOnce := viper.GetBool(cmd.Name() + ".delivery.at_least_once")
 
 		logger.Info("Creating kafka consumer for event stream")
 		streamEvents, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
 			Name:          cmd.Name() + " event stream",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
 			Topics:        topics.Topics(),
 			Ctx:           ctxReader,
 			OffsetMode:    kafkaOffset,
+			AtLeastOnce:   atLeastOnce,
 		})
 		app.Throw(cmd.Name()+" event stream setup", err, logger)
 
@@ -67,6 +80,7 @@ var enrichCmd = &cobra.Command{
 			Name:          cmd.Name() + " asset stream",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
 			Topics:        []string{viper.GetString(cmd.Name() + ".input.kafka.topic_assets")},
 			Ctx:           ctxReader,
 			OffsetMode:    kafkaOffset,
@@ -74,28 +88,46 @@ var enrichCmd = &cobra.Command{
 		})
 		app.Throw(cmd.Name()+" asset stream setup", err, logger)
 
+		logger.Info("Creating kafka consumer for suricata SID mapping stream")
+		streamSidMap, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
+			Name:          cmd.Name() + " sid map stream",
+			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
+			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        []string{viper.GetString(cmd.Name() + ".input.kafka.topic_sid_mitre")},
+			Ctx:           ctxReader,
+			OffsetMode:    kafkaOffset,
+			Logger:        logger,
+		})
+		app.Throw(cmd.Name()+" sid map stream setup", err, logger)
+
 		tx := make(chan consumer.Message, 0)
 		defer close(tx)
 
 		streamOutput, err := kafkaOutput.NewProducer(&kafkaOutput.Config{
-			Brokers: viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
-			Logger:  logger,
+			Brokers:     viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+			Security:    app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+			Logger:      logger,
+			Headers:     viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+			Stage:       cmd.Name(),
+			Version:     app.BuildVersion(),
+			AtLeastOnce: atLeastOnce,
 		})
 		app.Throw("Sarama producer init", err, logger)
 		topic := viper.GetString(cmd.Name() + ".output.kafka.topic")
-		streamOutput.Feed(tx, cmd.Name()+" output producer", context.TODO(),
-			func(m consumer.Message) string {
-				if m.Source == "oracle" {
-					return viper.GetString(cmd.Name() + ".output.kafka.topic_oracle")
-				}
-				if m.Source == "emit" {
-					return viper.GetString(cmd.Name() + ".output.kafka.topic_emit")
-				}
-				if viper.GetBool(cmd.Name() + ".output.kafka.topic_split") {
-					return topic + "-" + m.Source
-				}
-				return topic
-			}, &wg)
+		outputTopic := func(m consumer.Message) string {
+			if m.Source == "oracle" {
+				return viper.GetString(cmd.Name() + ".output.kafka.topic_oracle")
+			}
+			if m.Source == "emit" {
+				return viper.GetString(cmd.Name() + ".output.kafka.topic_emit")
+			}
+			if viper.GetBool(cmd.Name() + ".output.kafka.topic_split") {
+				return topic + "-" + m.Source
+			}
+			return topic
+		}
+		streamOutput.Feed(tx, cmd.Name()+" output producer", context.TODO(), outputTopic, &wg)
 
 		chTerminate := make(chan os.Signal, 1)
 		signal.Notify(chTerminate, os.Interrupt, syscall.SIGTERM)
@@ -130,51 +162,400 @@ var enrichCmd = &cobra.Command{
 			app.Throw("sigma ruleset init", err, logger)
 		}
 
-		sigmaRuleMap := make(map[events.Atomic]sigma.Ruleset)
+		sigmaConfigs := make([]enrich.SigmaConfig, 0, len(rulesets))
 		for _, rs := range rulesets {
+			sigmaConfigs = append(sigmaConfigs, enrich.SigmaConfig{Kind: rs.Type, Path: rs.Topic})
+		}
+		logSigmaStats := func(stats []enrich.SigmaStats) {
+			for _, s := range stats {
+				logger.WithFields(logrus.Fields{
+					"path":         s.Path,
+					"type":         s.Kind.String(),
+					"sigma_parsed": s.Ok,
+					"sigma_failed": s.Failed,
+					"sigma_unsupp": s.Unsupported,
+					"sigma_total":  s.Total,
+				}).Info("ruleset parsed")
+			}
+		}
+		sigmaRuleMap, sigmaStats, err := enrich.LoadSigmaRulesets(sigmaConfigs)
+		app.Throw("sigma ruleset parse", err, logger)
+		logSigmaStats(sigmaStats)
+
+		correlations, err := enrich.LoadCorrelationRules(
+			viper.GetStringSlice(cmd.Name() + ".sigma.correlation_path")...,
+		)
+		app.Throw("sigma correlation rule load", err, logger)
+		if len(correlations) > 0 {
+			logger.WithField("count", len(correlations)).Info("sigma correlation rules loaded")
+		}
+
+		fieldMaps, err := enrich.LoadFieldMaps(viper.GetString(cmd.Name() + ".sigma.field_map"))
+		app.Throw("sigma field map init", err, logger)
+
+		mitreMaps, err := enrich.LoadMitreMaps(viper.GetString(cmd.Name() + ".mitre.map"))
+		app.Throw("MITRE extraction map init", err, logger)
+
+		var networks []*meta.Network
+		if networkTable := viper.GetString(cmd.Name() + ".input.network_table"); networkTable != "" {
+			networks, err = enrich.LoadNetworks(networkTable)
+			app.Throw("network table load", err, logger)
 			logger.WithFields(logrus.Fields{
-				"kind": rs.Type.String(),
-				"path": rs.Topic,
-			}).Trace("parsing sigma ruleset")
-			ruleset, err := sigma.NewRuleset(sigma.Config{
-				Directory: []string{rs.Topic},
-			})
-			app.Throw("sigma "+rs.Topic+" init", err, logger)
-			sigmaRuleMap[rs.Type] = *ruleset
-			logger.WithFields(logrus.Fields{
-				"path":         rs.Topic,
-				"type":         rs.Type.String(),
-				"sigma_parsed": ruleset.Ok,
-				"sigma_failed": ruleset.Failed,
-				"sigma_unsupp": ruleset.Unsupported,
-				"sigma_total":  ruleset.Total,
-			}).Debug("ruleset parsed")
+				"path":     networkTable,
+				"segments": len(networks),
+			}).Info("network table loaded")
 		}
 
+		assetLookup, err := enrich.NewAssetLookupMode(viper.GetString(cmd.Name() + ".assets.lookup"))
+		app.Throw("asset lookup mode", err, logger)
+
+		partitionKey, err := enrich.NewPartitionKey(viper.GetString(cmd.Name() + ".output.kafka.key"))
+		app.Throw("partition key strategy", err, logger)
+
 		enricher, err := enrich.NewHandler(
 			enrich.Config{
 				Persist: persist,
 				Mitre: mitre.Config{
 					EnterpriseDump: filepath.Join(workdir, "enterprise.json"),
+					IcsDump:        filepath.Join(workdir, "ics.json"),
 					MappingsDump:   filepath.Join(workdir, "mappings.json"),
+					Bundles:        viper.GetStringSlice(cmd.Name() + ".mitre.bundle"),
+					Download:       viper.GetBool(cmd.Name() + ".mitre.download"),
 				},
-				Sigma: sigmaRuleMap,
+				Sigma:        sigmaRuleMap,
+				Correlations: correlations,
+				FieldMaps:    fieldMaps,
+				MitreMaps:    mitreMaps,
+				Networks:     networks,
+				AssetTTL:     viper.GetDuration(cmd.Name() + ".assets.ttl"),
+				AssetLookup:  assetLookup,
 			},
 		)
 		app.Throw("enrich handler create", err, logger)
+		for _, version := range enricher.MitreVersions() {
+			logger.WithFields(logrus.Fields{
+				"name":       version.Name,
+				"version":    version.Version,
+				"source":     version.Source,
+				"techniques": version.Count,
+			}).Info("MITRE ATT&CK dataset loaded")
+			metrics.MitreDataset.WithLabelValues(version.Name, version.Version, version.Source).Set(float64(version.Count))
+		}
 		defer func() {
 			if err := enricher.Close(); err != nil {
 				logger.WithField("err", err).Error("problem closing enricher")
 			}
 		}()
 
+		// rulesets are reloaded on SIGHUP or, if enabled, when rule files change
+		chReload := make(chan os.Signal, 1)
+		signal.Notify(chReload, syscall.SIGHUP)
+
+		var (
+			sigmaChanged <-chan struct{}
+			sigmaErrs    <-chan error
+		)
+		if viper.GetBool(cmd.Name()+".sigma.watch") && len(sigmaConfigs) > 0 {
+			watcher, err := enrich.NewSigmaWatcher(sigmaConfigs, 2*time.Second)
+			app.Throw("sigma watcher init", err, logger)
+			app.Throw("sigma watcher start", watcher.Run(&wg, ctxReader), logger)
+			sigmaChanged = watcher.Notify()
+			sigmaErrs = watcher.Errors
+		}
+		reloadSigma := func(reason string) {
+			if len(sigmaConfigs) == 0 {
+				logger.WithField("reason", reason).Warn("no sigma rulesets configured, skipping reload")
+				return
+			}
+			logger.WithField("reason", reason).Info("reloading sigma rulesets")
+			stats, err := enricher.ReloadSigma(sigmaConfigs)
+			logSigmaStats(stats)
+			if err != nil {
+				logger.WithField("err", err).Error("sigma reload failed, keeping active rulesets")
+				return
+			}
+			logger.WithField("reason", reason).Info("sigma rulesets reloaded")
+		}
+
+		pseudoTopics, err := app.ParsePseudoTopicItems(viper.GetStringSlice(cmd.Name() + ".pseudo.topics"))
+		app.Throw("pseudonymized topic parse", err, logger)
+		var pseudo *anonymizer.Pseudonymizer
+		if len(pseudoTopics) > 0 {
+			mapper, err := newAnonymizerMapper(cmd.Name(), persist)
+			app.Throw("anonymizer mapper init", err, logger)
+			networks := make([]*net.IPNet, 0)
+			for _, raw := range viper.GetStringSlice(cmd.Name() + ".pseudo.networks") {
+				_, network, err := net.ParseCIDR(raw)
+				app.Throw("pseudonymized network parse", err, logger)
+				networks = append(networks, network)
+			}
+			pseudo, err = anonymizer.NewPseudonymizer(anonymizer.PseudoConfig{
+				Mapper:   mapper,
+				IPs:      viper.GetBool(cmd.Name() + ".pseudo.ips"),
+				Networks: networks,
+			})
+			app.Throw("pseudonymizer init", err, logger)
+			logger.WithField("topics", pseudoTopics).Info("pseudonymization enabled")
+		}
+
+		// send routes message to its output topic
+		// pseudonymized topics get sanitized payload in place, or as a copy next to real one
+		// sanitize may be nil for messages that carry no identifying info
+		// msg.Ack is the single hold taken for this message, copies take their own
+		send := func(msg consumer.Message, sanitize func() ([]byte, error)) {
+			target := outputTopic(msg)
+			copyTopic, ok := pseudoTopics[target]
+			if !ok || sanitize == nil {
+				tx <- msg
+				return
+			}
+			data, err := sanitize()
+			if err != nil {
+				// real payload must never reach sanitized topic
+				logger.WithFields(logrus.Fields{
+					"topic": target,
+					"err":   err,
+				}).Error("unable to pseudonymize message")
+				if copyTopic != "" {
+					tx <- msg
+				} else {
+					msg.Ack.Done()
+				}
+				return
+			}
+			clean := msg
+			clean.Data = data
+			clean.Topic = target
+			// partition key may hold host name or address
+			clean.Key = pseudo.Replace(msg.Key)
+			if copyTopic != "" {
+				clean.Ack = msg.Ack.Hold()
+				tx <- msg
+				clean.Topic = copyTopic
+			}
+			tx <- clean
+		}
+
+		var (
+			stdoutEmit   = viper.GetBool(cmd.Name() + ".stdout.emit")
+			stdoutEvents = viper.GetBool(cmd.Name() + ".stdout.events")
+			produce      = !viper.GetBool(cmd.Name() + ".noproduce")
+		)
+		var (
+			suppressor     *enrich.Suppressor
+			suppressReport <-chan time.Time
+		)
+		if window := viper.GetDuration(cmd.Name() + ".suppress.window"); window > 0 {
+			suppressor, err = enrich.NewSuppressor(enrich.SuppressConfig{
+				Window: window,
+				Fields: viper.GetStringSlice(cmd.Name() + ".suppress.key"),
+			})
+			app.Throw("alert suppression init", err, logger)
+			ticker := time.NewTicker(viper.GetDuration(cmd.Name() + ".suppress.summary_interval"))
+			defer ticker.Stop()
+			suppressReport = ticker.C
+			logger.WithFields(logrus.Fields{
+				"window": window,
+				"key":    viper.GetStringSlice(cmd.Name() + ".suppress.key"),
+			}).Info("alert suppression enabled")
+		}
+
+		deadLetters, err := deadletter.NewWriter(deadletter.Config{
+			Topic: func() string {
+				if !produce {
+					return ""
+				}
+				return viper.GetString(cmd.Name() + ".output.kafka.topic_dead_letter")
+			}(),
+			Stage:  cmd.Name(),
+			Output: tx,
+		})
+		app.Throw("dead-letter output init", err, logger)
+
+		// deadLetter reports message that could not be handled and passes it to dead-letter topic
+		// raw payload is only logged at debug level, as it would flood the log on systematic failures
+		deadLetter := func(msg *consumer.Message, reason string, err error, text string) {
+			logger.WithFields(logrus.Fields{
+				"source":    msg.Source,
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+				"kind":      msg.Event.String(),
+				"err":       err,
+			}).Error(text)
+			logger.WithField("raw", string(msg.Data)).Debug(text)
+			deadLetters.Send(*msg, reason, err)
+		}
+
+		// processEvent is invoked concurrently by enrichment workers
+		// input is acknowledged once every output derived from it is delivered
+		processEvent := func(msg *consumer.Message) {
+			defer msg.Ack.Done()
+			kind, ok := topicMapFn(msg.Source)
+			if !ok {
+				deadLetter(msg, deadletter.ReasonUnmapped, fmt.Errorf("no event kind mapped for topic %s", msg.Source), "invalid kind")
+				return
+			}
+			msg.Event = kind
+
+			metrics.EventsIn.WithLabelValues(cmd.Name(), kind.String()).Inc()
+			event, err := enricher.Decode(msg.Data, kind)
+			if err != nil {
+				metrics.ParseErrors.WithLabelValues(cmd.Name(), kind.String()).Inc()
+				deadLetter(msg, deadletter.ReasonDecode, err, "unable to decode")
+				return
+			}
+
+			asset, err := enricher.Enrich(event)
+			if err != nil {
+				deadLetter(msg, deadletter.ReasonEnrich, err, "unable to enrich")
+				return
+			}
+
+			origin := &consumer.Origin{Source: msg.Source, Partition: msg.Partition, Offset: msg.Offset}
+			key := partitionKey.Key(kind, asset)
+			// keyOr returns partition key of event, or fallback if strategy has no value for it
+			keyOr := func(fallback string) string {
+				if key == "" {
+					return fallback
+				}
+				return key
+			}
+
+			msgID := fmt.Sprintf("%s:%d:%d", msg.Source, msg.Partition, msg.Offset)
+			for _, alert := range enricher.Correlate(event, asset, msgID) {
+				encoded, err := json.Marshal(alert)
+				if err != nil {
+					logger.WithFields(logrus.Fields{
+						"err":   err,
+						"alert": alert,
+					}).Error("correlation alert encode error")
+					continue
+				}
+				if stdoutEmit {
+					os.Stdout.Write(append(encoded, []byte("\n")...))
+				}
+				if produce {
+					alert := alert
+					send(consumer.Message{
+						Data:   encoded,
+						Time:   alert.Timestamp,
+						Key:    keyOr("sigma-correlation"),
+						Event:  kind,
+						Source: "emit",
+						Ack:    msg.Ack.Hold(),
+						Origin: origin,
+					}, func() ([]byte, error) {
+						if alert.GameMeta != nil {
+							cpy := *alert.GameMeta
+							cpy.Anonymize(pseudo)
+							alert.GameMeta = &cpy
+						}
+						groups := make(map[string]string, len(alert.GroupBy))
+						for key, val := range alert.GroupBy {
+							groups[key] = pseudo.Replace(val)
+						}
+						alert.GroupBy = groups
+						return json.Marshal(alert)
+					})
+				}
+			}
+
+			encoded, err := event.JSONFormat()
+			if err != nil {
+				deadLetter(msg, deadletter.ReasonEncode, err, "event encode error")
+				return
+			}
+
+			// event is rewritten in place, so sanitized copy is made only after real payload is encoded
+			var (
+				sanitized    []byte
+				sanitizeErr  error
+				sanitizeDone bool
+			)
+			sanitizeEvent := func() ([]byte, error) {
+				if sanitizeDone {
+					return sanitized, sanitizeErr
+				}
+				sanitizeDone = true
+				anon, ok := event.(events.Anonymizer)
+				if !ok {
+					sanitizeErr = fmt.Errorf("%s events do not support pseudonymization", kind)
+					return nil, sanitizeErr
+				}
+				if sanitizeErr = anon.Anonymize(pseudo); sanitizeErr != nil {
+					return nil, sanitizeErr
+				}
+				sanitized, sanitizeErr = event.JSONFormat()
+				return sanitized, sanitizeErr
+			}
+
+			emit := event.Emit()
+			if emit && suppressor != nil && !suppressor.Allow(suppressor.Key(kind, asset), time.Now()) {
+				// regular event topic still receives suppressed alerts
+				metrics.SuppressedEmits.WithLabelValues(kind.String()).Inc()
+				emit = false
+			}
+			if emit {
+				metrics.MitreEmits.WithLabelValues(kind.String()).Inc()
+				if stdoutEmit {
+					os.Stdout.Write(append(encoded, []byte("\n")...))
+				}
+				if produce {
+					// mitre-enriched events should be fast-tracked
+					send(consumer.Message{
+						Data:   encoded,
+						Time:   event.Time(),
+						Key:    keyOr(kind.String()),
+						Event:  kind,
+						Source: "emit",
+						Ack:    msg.Ack.Hold(),
+						Origin: origin,
+					}, sanitizeEvent)
+				}
+			}
+
+			if stdoutEvents {
+				os.Stdout.Write(append(encoded, []byte("\n")...))
+			}
+
+			if produce {
+				metrics.EventsOut.WithLabelValues(cmd.Name(), kind.String()).Inc()
+				// send to generic topics
+				send(consumer.Message{
+					Data:   encoded,
+					Time:   event.Time(),
+					Key:    key,
+					Event:  kind,
+					Source: kind.String(),
+					Ack:    msg.Ack.Hold(),
+					Origin: origin,
+				}, sanitizeEvent)
+			}
+		}
+
+		var wgWorkers sync.WaitGroup
+		pool, err := enrich.NewWorkerPool(enrich.WorkerConfig{
+			Workers:     viper.GetInt(cmd.Name() + ".workers"),
+			Ordered:     viper.GetBool(cmd.Name() + ".workers_ordered"),
+			HandlerFunc: processEvent,
+		})
+		app.Throw("worker pool init", err, logger)
+		app.Throw("worker pool start", pool.Run(&wgWorkers), logger)
+		// workers must drain before output channel is closed
+		defer wgWorkers.Wait()
+		defer pool.Close()
+
 		report := time.NewTicker(viper.GetDuration(cmd.Name() + ".log.interval"))
 		defer report.Stop()
 	loop:
 		for {
 			select {
 			case <-report.C:
-				logger.Infof("%+v", enricher.Counts)
+				enricher.ExpireCorrelations()
+				if expired := enricher.ExpireAssets(); expired > 0 {
+					logger.WithField("count", expired).Info("expired stale assets")
+				}
+				logger.Infof("%+v", enricher.Stats())
 				if missing := enricher.MissingKeys(); len(missing) > 0 {
 					logger.WithField("count", len(missing)).Warn("missing asset lookup keys")
 					for _, key := range missing {
@@ -204,11 +585,20 @@ var enrichCmd = &cobra.Command{
 						Data:   encoded,
 					}
 				}
-				enricher.Persist()
+				if err := enricher.Persist(); err != nil {
+					logger.WithField("err", err).Error("unable to persist enrichment state")
+				}
 			case msg, ok := <-streamAssets.Messages():
 				if !ok {
 					continue loop
 				}
+				// empty value is a tombstone for deleted asset on compacted topic
+				if len(msg.Data) == 0 || string(msg.Data) == "null" {
+					if msg.Key != "" {
+						enricher.RemoveAsset(msg.Key, msg.Time)
+					}
+					continue loop
+				}
 				var obj providentia.Record
 				if err := json.Unmarshal(msg.Data, &obj); err != nil {
 					logger.WithFields(logrus.Fields{
@@ -219,77 +609,63 @@ var enrichCmd = &cobra.Command{
 					continue loop
 				}
 				enricher.AddAsset(obj)
-			case msg, ok := <-streamEvents.Messages():
-				if !ok {
-					break loop
+				if pseudo != nil {
+					if err := pseudo.Reserve(obj.Pretty, obj.HostName, obj.AnsibleName, obj.FQDN); err != nil {
+						logger.WithField("err", err).Error("unable to reserve asset alias")
+					}
 				}
-				kind, ok := topicMapFn(msg.Source)
+			case msg, ok := <-streamSidMap.Messages():
 				if !ok {
-					logger.WithFields(logrus.Fields{
-						"raw":    string(msg.Data),
-						"source": msg.Source,
-					}).Error("invalid kind")
-					continue loop
-				}
-
-				event, err := enricher.Decode(msg.Data, kind)
-				if err != nil {
-					logrus.WithFields(logrus.Fields{
-						"raw":  string(msg.Data),
-						"kind": kind.String(),
-						"err":  err.Error(),
-					}).Error("unable to decode")
 					continue loop
 				}
-
-				if err := enricher.Enrich(event); err != nil {
+				var obj mitremeerkat.Mapping
+				if err := json.Unmarshal(msg.Data, &obj); err != nil {
 					logger.WithFields(logrus.Fields{
 						"raw":    string(msg.Data),
 						"source": msg.Source,
 						"err":    err,
-					}).Error("unable to enrich")
+					}).Error("unable to parse suricata sid mapping")
 					continue loop
 				}
-
-				encoded, err := event.JSONFormat()
-				if err != nil {
-					logger.WithFields(logrus.Fields{
-						"err":   err,
-						"event": event,
-					}).Error("event encode error")
-					continue loop
+				enricher.AddSidMap(obj)
+			case msg, ok := <-streamEvents.Messages():
+				if !ok {
+					break loop
 				}
-
-				if event.Emit() {
-					if viper.GetBool(cmd.Name() + ".stdout.emit") {
+				pool.Send(msg)
+			case <-suppressReport:
+				for _, summary := range suppressor.Flush(time.Now()) {
+					encoded, err := json.Marshal(summary)
+					if err != nil {
+						logger.WithFields(logrus.Fields{
+							"raw": summary,
+							"err": err,
+						}).Error("unable to encode json")
+						continue
+					}
+					if stdoutEmit {
 						os.Stdout.Write(append(encoded, []byte("\n")...))
 					}
-					if !viper.GetBool(cmd.Name() + ".noproduce") {
-						// mitre-enriched events should be fast-tracked
-						tx <- consumer.Message{
+					if produce {
+						summary := summary
+						send(consumer.Message{
 							Data:   encoded,
-							Time:   event.Time(),
-							Key:    kind.String(),
-							Event:  kind,
+							Time:   summary.Timestamp,
+							Key:    "suppression",
 							Source: "emit",
-						}
-					}
-				}
-
-				if viper.GetBool(cmd.Name() + ".stdout.events") {
-					os.Stdout.Write(append(encoded, []byte("\n")...))
-				}
-
-				if !viper.GetBool(cmd.Name() + ".noproduce") {
-					// send to generic topics
-					tx <- consumer.Message{
-						Data:   encoded,
-						Time:   event.Time(),
-						Event:  kind,
-						Source: kind.String(),
+						}, func() ([]byte, error) {
+							summary.Src = pseudo.Replace(summary.Src)
+							summary.Dst = pseudo.Replace(summary.Dst)
+							return json.Marshal(summary)
+						})
 					}
 				}
-
+			case <-chReload:
+				reloadSigma("SIGHUP")
+			case <-sigmaChanged:
+				reloadSigma("ruleset change")
+			case err := <-sigmaErrs:
+				logger.WithField("err", err).Error("sigma watcher")
 			case <-chTerminate:
 				break loop
 			}
@@ -311,12 +687,60 @@ func init() {
 	pFlags.Bool("no-produce", false, "Do not write to kafka. Mostly for debug.")
 	viper.BindPFlag(enrichCmd.Name()+".noproduce", pFlags.Lookup("no-produce"))
 
+	pFlags.Int("workers", 1, "Number of parallel enrichment workers.")
+	viper.BindPFlag(enrichCmd.Name()+".workers", pFlags.Lookup("workers"))
+
+	pFlags.Bool("workers-ordered", false, "Pin each input partition to a single worker to preserve event order within partition.")
+	viper.BindPFlag(enrichCmd.Name()+".workers_ordered", pFlags.Lookup("workers-ordered"))
+
+	pFlags.Duration("asset-ttl", 0, "Drop asset records that have not been updated within this period. 0 keeps assets until deleted.")
+	viper.BindPFlag(enrichCmd.Name()+".assets.ttl", pFlags.Lookup("asset-ttl"))
+
+	pFlags.String("asset-lookup", "latest", "Asset lookup mode. latest uses current inventory, point-in-time uses inventory at event time for reprocessing old data.")
+	viper.BindPFlag(enrichCmd.Name()+".assets.lookup", pFlags.Lookup("asset-lookup"))
+
+	pFlags.String("output-kafka-key", "default", "Kafka message key for enriched events. "+
+		"default keys only emitted events by kind, host, ip and team keep events of the same asset in one partition, kind keys all events by event kind.")
+	viper.BindPFlag(enrichCmd.Name()+".output.kafka.key", pFlags.Lookup("output-kafka-key"))
+
+	pFlags.Duration("suppress-window", 0, "Forward only the first of repeated alerts to emit topic within this window. 0 disables suppression.")
+	viper.BindPFlag(enrichCmd.Name()+".suppress.window", pFlags.Lookup("suppress-window"))
+
+	pFlags.StringSlice("suppress-key", enrich.SuppressKeyFields, "Alert fields that identify repeats. Supported: kind, rule, src, dst.")
+	viper.BindPFlag(enrichCmd.Name()+".suppress.key", pFlags.Lookup("suppress-key"))
+
+	pFlags.Duration("suppress-summary-interval", 1*time.Minute, "Interval for sending suppressed alert summaries to emit topic.")
+	viper.BindPFlag(enrichCmd.Name()+".suppress.summary_interval", pFlags.Lookup("suppress-summary-interval"))
+
+	pFlags.StringSlice("mitre-bundle", []string{}, "MITRE ATT&CK STIX bundles, plain or gzipped json. Overrides dataset embedded in binary.")
+	viper.BindPFlag(enrichCmd.Name()+".mitre.bundle", pFlags.Lookup("mitre-bundle"))
+
+	pFlags.String("mitre-map", "", "YAML file with per event kind field paths and regexes for extracting MITRE techniques and tactics. Overrides built-in rules.")
+	viper.BindPFlag(enrichCmd.Name()+".mitre.map", pFlags.Lookup("mitre-map"))
+
+	pFlags.Bool("mitre-download", false, "Download pinned MITRE ATT&CK release if no bundle is available offline.")
+	viper.BindPFlag(enrichCmd.Name()+".mitre.download", pFlags.Lookup("mitre-download"))
+
+	pFlags.StringSlice("pseudo-topics", []string{}, "Output topics that receive pseudonymized events, in topic or topic:copy format. "+
+		"With copy, real events still go to topic and pseudonymized ones to copy topic.")
+	viper.BindPFlag(enrichCmd.Name()+".pseudo.topics", pFlags.Lookup("pseudo-topics"))
+
+	pFlags.Bool("pseudo-ips", false, "Also rewrite internal IP addresses in pseudonymized events.")
+	viper.BindPFlag(enrichCmd.Name()+".pseudo.ips", pFlags.Lookup("pseudo-ips"))
+
+	pFlags.StringSlice("pseudo-networks", []string{}, "CIDR ranges considered internal for IP pseudonymization. Private ranges are used if empty.")
+	viper.BindPFlag(enrichCmd.Name()+".pseudo.networks", pFlags.Lookup("pseudo-networks"))
+
 	app.RegisterLogging(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterInputKafkaCore(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterInputKafkaTopicMap(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterInputKafkaEnrich(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterSigmaRulesetPaths(enrichCmd.Name(), enrichCmd.PersistentFlags())
+	app.RegisterAnonymizer(enrichCmd.Name(), enrichCmd.PersistentFlags())
+	app.RegisterDelivery(enrichCmd.Name(), enrichCmd.PersistentFlags())
+	app.RegisterInputNetworkTable(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterOutputKafka(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterOutputKafkaEnrichment(enrichCmd.Name(), enrichCmd.PersistentFlags())
 	app.RegisterOutputKafkaOracle(enrichCmd.Name(), enrichCmd.PersistentFlags())
+	app.RegisterOutputKafkaDeadLetter(enrichCmd.Name(), enrichCmd.PersistentFlags())
 }
@@ -37,8 +37,12 @@ var mitremeerkatCmd = &cobra.Command{
 		defer close(tx)
 
 		output, err := kafkaOutput.NewProducer(&kafkaOutput.Config{
-			Brokers: viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
-			Logger:  logger,
+			Brokers:  viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+			Security: app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+			Logger:   logger,
+			Headers:  viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+			Stage:    cmd.Name(),
+			Version:  app.BuildVersion(),
 		})
 		app.Throw("Sarama producer init", err, logger)
 		defer output.Close()
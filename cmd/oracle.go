@@ -33,6 +33,7 @@ var oracleCmd = &cobra.Command{
 	Short: "Yellow team know-it-all API",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		var wg sync.WaitGroup
 		// defer wg.Wait()
@@ -45,6 +46,15 @@ var oracleCmd = &cobra.Command{
 			app.Throw("app init", errors.New("missing working directory"), logger)
 		}
 		workdir = path.Join(workdir, cmd.Name())
+
+		topicMitreMeerkat := viper.GetString(cmd.Name() + ".input.kafka.topic_sid_mitre")
+		topicAssets := viper.GetString(cmd.Name() + ".input.kafka.topic_assets")
+		topicInOracle := viper.GetString(cmd.Name() + ".input.kafka.topic_oracle")
+		topics := []string{topicMitreMeerkat, topicAssets, topicInOracle}
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
 		ctxPersist, cancelPersist := context.WithCancel(context.Background())
 		persist, err := persist.NewBadger(persist.Config{
 			Directory:     path.Join(workdir, "badger"),
@@ -60,22 +70,15 @@ var oracleCmd = &cobra.Command{
 
 		ctxReader, cancelReader := context.WithCancel(context.Background())
 
-		topicMitreMeerkat := viper.GetString(cmd.Name() + ".input.kafka.topic_sid_mitre")
-		topicAssets := viper.GetString(cmd.Name() + ".input.kafka.topic_assets")
-		topicInOracle := viper.GetString(cmd.Name() + ".input.kafka.topic_oracle")
-
 		logger.Info("Creating kafka consumer for event stream")
 		input, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
 			Name:          cmd.Name() + " event stream",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics: []string{
-				topicMitreMeerkat,
-				topicAssets,
-				topicInOracle,
-			},
-			Ctx:        ctxReader,
-			OffsetMode: kafkaOffset,
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
+			Ctx:           ctxReader,
+			OffsetMode:    kafkaOffset,
 		})
 		app.Throw(cmd.Name()+" event stream setup", err, logger)
 		defer cancelReader()
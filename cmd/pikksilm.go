@@ -25,10 +25,16 @@ This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
 
+		topics := viper.GetStringSlice(cmd.Name() + ".input.kafka.topics")
+		if seekConsumerGroup(cmd.Name(), topics) {
+			return
+		}
+
 		ctxReader, cancelReader := context.WithCancel(context.Background())
 		defer cancelReader()
 
@@ -38,7 +44,8 @@ to quickly create a Cobra application.`,
 			Name:          cmd.Name() + " event stream",
 			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
 			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics:        viper.GetStringSlice(cmd.Name() + ".input.kafka.topics"),
+			Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+			Topics:        topics,
 			Ctx:           ctxReader,
 			OffsetMode:    kafkaOffset,
 		})
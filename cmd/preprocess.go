@@ -1,13 +1,16 @@
 package cmd
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"go-peek/internal/app"
+	"go-peek/pkg/deadletter"
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/models/consumer"
 	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/fields"
 	"go-peek/pkg/process"
 	"io"
 	"os"
@@ -26,48 +29,71 @@ import (
 	"github.com/spf13/viper"
 )
 
+var errMissingSyslogBody = errors.New("missing syslog message body")
+
 // preprocessCmd represents the preprocess command
 var preprocessCmd = &cobra.Command{
 	Use:   "preprocess",
 	Short: "Preprocess and normalize messages",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
 
-		ctxReader, cancelReader := context.WithCancel(context.Background())
-
 		var wg sync.WaitGroup
 
+		syslogEnabled := viper.GetBool(cmd.Name() + ".input.syslog.enabled")
+		atLeastOnce := viper.GetBool(cmd.Name() + ".delivery.at_least_once")
+
 		topics, err := app.ParseKafkaTopicItems(
 			viper.GetStringSlice(cmd.Name() + ".input.kafka.topic_map"),
 		)
-		app.Throw("topic map parse", err, logger)
-
-		logger.Info("Creating kafka consumer")
-		input, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
-			Name:          cmd.Name() + " consumer",
-			ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
-			Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
-			Topics:        topics.Topics(),
-			Ctx:           ctxReader,
-			OffsetMode:    kafkaOffset,
-			Logger:        logger,
-			LogInterval:   viper.GetDuration(cmd.Name() + ".log.interval"),
-		})
-		app.Throw("kafka consumer", err, logger)
+		if err == app.ErrInvalidTopicFlags && syslogEnabled {
+			logger.Warn("no kafka topics configured, only consuming syslog")
+		} else {
+			app.Throw("topic map parse", err, logger)
+		}
+		if len(topics) > 0 && seekConsumerGroup(cmd.Name(), topics.Topics()) {
+			return
+		}
+
+		ctxReader, cancelReader := context.WithCancel(context.Background())
+
+		var rx <-chan *consumer.Message
+		if len(topics) > 0 {
+			logger.Info("Creating kafka consumer")
+			input, err := kafkaIngest.NewConsumer(&kafkaIngest.Config{
+				Name:          cmd.Name() + " consumer",
+				ConsumerGroup: viper.GetString(cmd.Name() + ".input.kafka.consumer_group"),
+				Brokers:       viper.GetStringSlice(cmd.Name() + ".input.kafka.brokers"),
+				Security:      app.KafkaSecurity(cmd.Name() + ".input.kafka"),
+				Topics:        topics.Topics(),
+				Ctx:           ctxReader,
+				OffsetMode:    kafkaOffset,
+				Logger:        logger,
+				LogInterval:   viper.GetDuration(cmd.Name() + ".log.interval"),
+				AtLeastOnce:   atLeastOnce,
+			})
+			app.Throw("kafka consumer", err, logger)
+			rx = input.Messages()
+		}
 
 		topicMapFn := topics.TopicMap()
 
-		rx := input.Messages()
 		tx := make(chan consumer.Message, 0)
 		defer close(tx)
 
 		ctxWriter, cancelWriter := context.WithCancel(context.Background())
 		producer, err := kafkaOutput.NewProducer(&kafkaOutput.Config{
-			Brokers: viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
-			Logger:  logger,
+			Brokers:     viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+			Security:    app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+			Logger:      logger,
+			Headers:     viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+			Stage:       cmd.Name(),
+			Version:     app.BuildVersion(),
+			AtLeastOnce: atLeastOnce,
 		})
 		app.Throw("Sarama producer init", err, logger)
 		topic := viper.GetString(cmd.Name() + ".output.kafka.topic")
@@ -75,6 +101,37 @@ var preprocessCmd = &cobra.Command{
 			return topic + "-" + m.Source
 		}, &wg)
 
+		deadLetters, err := deadletter.NewWriter(deadletter.Config{
+			Topic:  viper.GetString(cmd.Name() + ".output.kafka.topic_dead_letter"),
+			Stage:  cmd.Name(),
+			Output: tx,
+		})
+		app.Throw("dead-letter output init", err, logger)
+
+		// deadLetter reports message that could not be parsed and passes it to dead-letter topic
+		// raw payload is only logged at debug level, as it would flood the log on systematic failures
+		deadLetter := func(msg consumer.Message, reason string, err error, text string) {
+			logger.WithFields(logrus.Fields{
+				"source": msg.Source,
+				"sender": msg.Sender,
+				"kind":   msg.Event.String(),
+				"err":    err,
+			}).Error(text)
+			logger.WithField("raw", string(msg.Data)).Debug(text)
+			deadLetters.Send(msg, reason, err)
+		}
+		// batchLine describes i-th line from collected batch with position of its input message
+		batchLine := func(data []byte, kind events.Atomic, ack *consumer.Ack, origins []consumer.Message, i int) consumer.Message {
+			msg := consumer.Message{Type: consumer.Kafka, Partition: -1, Offset: -1}
+			if i < len(origins) {
+				msg = origins[i]
+			}
+			msg.Data = data
+			msg.Event = kind
+			msg.Ack = ack
+			return msg
+		}
+
 		chTerminate := make(chan os.Signal, 1)
 		signal.Notify(chTerminate, os.Interrupt, syscall.SIGTERM)
 
@@ -91,80 +148,99 @@ var preprocessCmd = &cobra.Command{
 		}
 
 		normalizer := process.NewNormalizer()
+		normalizeSyslog := func(src consumer.Message) {
+			sender := src.Sender
+			obj, err := normalizer.NormalizeSyslog(src.Data)
+			if err != nil && err != io.EOF {
+				metrics.ParseErrors.WithLabelValues(cmd.Name(), events.SyslogE.String()).Inc()
+				src.Event = events.SyslogE
+				deadLetter(src, deadletter.ReasonParse, err, "syslog entry parse")
+				return
+			} else if err != nil {
+				return
+			}
+			var kind events.Atomic
+			switch val := obj.(type) {
+			case *events.Syslog:
+				kind = events.SyslogE
+				if val.IP == nil && sender != nil {
+					val.IP = &fields.StringIP{IP: sender}
+				}
+			case *events.Snoopy:
+				kind = events.SnoopyE
+				if val.Syslog.IP == nil && sender != nil {
+					val.Syslog.IP = &fields.StringIP{IP: sender}
+				}
+			case *events.Cef:
+				kind = val.Kind()
+				if val.Syslog.IP == nil && sender != nil {
+					val.Syslog.IP = &fields.StringIP{IP: sender}
+				}
+			}
+			bin, err := json.Marshal(obj)
+			if err != nil {
+				src.Event = kind
+				deadLetter(src, deadletter.ReasonEncode, err, "syslog entry encode")
+				return
+			}
+			tx <- consumer.Message{
+				Data:   bin,
+				Event:  kind,
+				Source: kind.String(),
+				Sender: sender,
+				Ack:    src.Ack.Hold(),
+			}
+			metrics.EventsOut.WithLabelValues(cmd.Name(), kind.String()).Inc()
+			messages.syslog++
+		}
 		syslogCollector := &process.Collector{
-			HandlerFunc: func(b *bytes.Buffer) error {
+			HandlerFunc: func(b *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error {
 				batches.syslog++
-				scanner := bufio.NewScanner(b)
-				for scanner.Scan() {
-					obj, err := normalizer.NormalizeSyslog(scanner.Bytes())
-					if err != nil && err != io.EOF {
-						logger.Error(err)
-					} else if err == nil {
-						bin, err := json.Marshal(obj)
-						if err != nil {
-							logger.Error(err)
-						} else {
-							var kind events.Atomic
-							switch obj.(type) {
-							case *events.Syslog:
-								kind = events.SyslogE
-							case *events.Snoopy:
-								kind = events.SnoopyE
-							}
-							tx <- consumer.Message{
-								Data:   bin,
-								Event:  kind,
-								Source: kind.String(),
-							}
-							messages.syslog++
-						}
-					}
-				}
-				return scanner.Err()
+				return process.ScanLines(b, func(line []byte, i int) {
+					normalizeSyslog(batchLine(line, events.SyslogE, ack, origins, i))
+				}, func(line []byte, i int, err error) {
+					deadLetter(batchLine(line, events.SyslogE, ack, origins, i), deadletter.ReasonParse, err, "syslog batch scan")
+				})
 			},
 			Size: 64 * 1024,
 		}
 
 		windowsCollector := &process.Collector{
-			HandlerFunc: func(b *bytes.Buffer) error {
+			HandlerFunc: func(b *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error {
 				batches.windows++
-				scanner := bufio.NewScanner(b)
-				for scanner.Scan() {
+				return process.ScanLines(b, func(line []byte, i int) {
 					// TODO - topic map per object type
-					slc := make([]byte, len(scanner.Bytes()))
-					copy(slc, scanner.Bytes())
+					slc := make([]byte, len(line))
+					copy(slc, line)
 					tx <- consumer.Message{
 						Data:   slc,
 						Event:  events.EventLogE,
 						Source: events.EventLogE.String(),
+						Ack:    ack.Hold(),
 					}
+					metrics.EventsOut.WithLabelValues(cmd.Name(), events.EventLogE.String()).Inc()
 					messages.windows++
-				}
-				return scanner.Err()
+				}, func(line []byte, i int, err error) {
+					deadLetter(batchLine(line, events.EventLogE, ack, origins, i), deadletter.ReasonParse, err, "windows batch scan")
+				})
 			},
 			Size: 64 * 1024,
 		}
 
 		suricataCollector := &process.Collector{
-			HandlerFunc: func(b *bytes.Buffer) error {
+			HandlerFunc: func(b *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error {
 				batches.suricata++
-				scanner := bufio.NewScanner(b)
-			loop:
-				for scanner.Scan() {
-					obj, err := normalizer.RFC5424.Parse(scanner.Bytes())
+				return process.ScanLines(b, func(line []byte, i int) {
+					obj, err := normalizer.RFC5424.Parse(line)
 					if err != nil {
-						logger.WithFields(logrus.Fields{
-							"msg": scanner.Text(),
-							"err": err,
-						}).Error("suricata syslog entry parse")
-						continue loop
+						metrics.ParseErrors.WithLabelValues(cmd.Name(), events.SuricataE.String()).Inc()
+						deadLetter(batchLine(line, events.SuricataE, ack, origins, i), deadletter.ReasonParse, err, "suricata syslog entry parse")
+						return
 					}
 					msg, ok := obj.(*rfc5424.SyslogMessage)
 					if !ok || msg == nil || msg.Message == nil {
-						logger.WithFields(logrus.Fields{
-							"msg": scanner.Text(),
-						}).Error("suricata message extract")
-						continue loop
+						deadLetter(batchLine(line, events.SuricataE, ack, origins, i), deadletter.ReasonParse, errMissingSyslogBody, "suricata message extract")
+						return
 					}
 					m := *msg.Message
 					if bits := strings.SplitN(m, "LOGSTASH[-]:", 2); len(bits) == 2 {
@@ -175,17 +251,37 @@ var preprocessCmd = &cobra.Command{
 						Data:   []byte(m),
 						Event:  events.SuricataE,
 						Source: events.SuricataE.String(),
+						Ack:    ack.Hold(),
 					}
+					metrics.EventsOut.WithLabelValues(cmd.Name(), events.SuricataE.String()).Inc()
 					messages.suricata++
-				}
-				return scanner.Err()
+				}, func(line []byte, i int, err error) {
+					deadLetter(batchLine(line, events.SuricataE, ack, origins, i), deadletter.ReasonParse, err, "suricata batch scan")
+				})
 			},
 		}
 
-		logger.
-			WithField("port", viper.GetInt(cmd.Name()+".input.syslog.udp.port")).
-			WithField("proto", "udp").
-			Info("starting up syslog server")
+		var (
+			syslogRx   <-chan *consumer.Message
+			syslogErrs <-chan error
+		)
+		if syslogEnabled {
+			logger.
+				WithField("port_udp", viper.GetInt(cmd.Name()+".input.syslog.udp.port")).
+				WithField("port_tcp", viper.GetInt(cmd.Name()+".input.syslog.tcp.port")).
+				Info("starting up syslog server")
+			server, err := process.NewSyslogServer(process.SyslogServerConfig{
+				UDPPort:     viper.GetInt(cmd.Name() + ".input.syslog.udp.port"),
+				TCPPort:     viper.GetInt(cmd.Name() + ".input.syslog.tcp.port"),
+				IdleTimeout: viper.GetDuration(cmd.Name() + ".input.syslog.tcp.idle_timeout"),
+			})
+			app.Throw("syslog server init", err, logger)
+			app.Throw("syslog server start", server.Run(&wg, ctxReader), logger)
+			syslogRx = server.Messages()
+			syslogErrs = server.Errors
+		} else if rx == nil {
+			app.Throw("input init", errors.New("no kafka topics or syslog server configured"), logger)
+		}
 
 		report := time.NewTicker(viper.GetDuration(cmd.Name() + ".log.interval"))
 		defer report.Stop()
@@ -201,14 +297,29 @@ var preprocessCmd = &cobra.Command{
 				if !ok {
 					break loop
 				}
-				switch val, ok := topicMapFn(msg.Source); ok {
-				case val == events.SyslogE, val == events.SnoopyE:
-					app.ErrLog(syslogCollector.Collect(msg.Data), logger)
+				val, ok := topicMapFn(msg.Source)
+				if ok {
+					metrics.EventsIn.WithLabelValues(cmd.Name(), val.String()).Inc()
+				}
+				switch ok {
+				case val == events.SyslogE, val == events.SnoopyE, val == events.MazeRunnerE:
+					app.ErrLog(syslogCollector.Collect(msg), logger)
 				case val == events.EventLogE:
-					app.ErrLog(windowsCollector.Collect(msg.Data), logger)
+					app.ErrLog(windowsCollector.Collect(msg), logger)
 				case val == events.SuricataE:
-					app.ErrLog(suricataCollector.Collect(msg.Data), logger)
+					app.ErrLog(suricataCollector.Collect(msg), logger)
+				default:
+					// unmapped topic, nothing to deliver
+					msg.Ack.Done()
 				}
+			case msg, ok := <-syslogRx:
+				if !ok {
+					break loop
+				}
+				metrics.EventsIn.WithLabelValues(cmd.Name(), events.SyslogE.String()).Inc()
+				normalizeSyslog(*msg)
+			case err := <-syslogErrs:
+				logger.WithField("err", err).Error("syslog server")
 			case <-flush.C:
 				app.ErrLog(syslogCollector.Flush(), logger)
 				app.ErrLog(windowsCollector.Flush(), logger)
@@ -230,6 +341,15 @@ var preprocessCmd = &cobra.Command{
 						"suricata": messages.suricata,
 					},
 				).Debug("messages")
+				logger.WithFields(
+					logrus.Fields{
+						"rfc5424":     normalizer.Counts.RFC5424,
+						"rfc3164":     normalizer.Counts.RFC3164,
+						"err_rfc5424": normalizer.Counts.ErrRFC5424,
+						"err_rfc3164": normalizer.Counts.ErrRFC3164,
+						"err_unknown": normalizer.Counts.ErrUnknown,
+					},
+				).Debug("syslog parser")
 			}
 		}
 
@@ -244,5 +364,8 @@ func init() {
 
 	app.RegisterLogging(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
 	app.RegisterInputKafkaPreproc(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
+	app.RegisterInputSyslog(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
 	app.RegisterOutputKafka(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
+	app.RegisterOutputKafkaDeadLetter(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
+	app.RegisterDelivery(preprocessCmd.Name(), preprocessCmd.PersistentFlags())
 }
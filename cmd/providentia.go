@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"go-peek/internal/app"
-	"go-peek/pkg/anonymizer"
 	"go-peek/pkg/models/consumer"
 	"go-peek/pkg/outputs/kafka"
 	"go-peek/pkg/persist"
@@ -29,6 +28,7 @@ var providentiaCmd = &cobra.Command{
 	Short: "Pull asset data from providentia API",
 	Run: func(cmd *cobra.Command, args []string) {
 		start := app.Start(cmd.Name(), logger)
+		app.ServeMetrics(cmd.Name(), logger)
 
 		defer app.Catch(logger)
 		defer app.Done(cmd.Name(), start, logger)
@@ -44,8 +44,12 @@ var providentiaCmd = &cobra.Command{
 		tx := make(chan consumer.Message, 10)
 		if viper.GetBool(cmd.Name() + ".output.kafka.enabled") {
 			producer, err := kafka.NewProducer(&kafka.Config{
-				Brokers: viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
-				Logger:  logger,
+				Brokers:  viper.GetStringSlice(cmd.Name() + ".output.kafka.brokers"),
+				Security: app.KafkaSecurity(cmd.Name() + ".output.kafka"),
+				Headers:  viper.GetBool(cmd.Name() + ".output.kafka.headers"),
+				Stage:    cmd.Name(),
+				Version:  app.BuildVersion(),
+				Logger:   logger,
 			})
 			app.Throw("Sarama producer init", err, logger)
 			topic := viper.GetString(cmd.Name() + ".output.kafka.topic")
@@ -71,12 +75,7 @@ var providentiaCmd = &cobra.Command{
 		app.Throw("persist setup", err, logger)
 		defer persist.Close()
 
-		m, err := anonymizer.NewMapper(
-			anonymizer.Config{
-				Persist: persist,
-				Logger:  logger,
-			},
-		)
+		m, err := newAnonymizerMapper(cmd.Name(), persist)
 		app.Throw("Anonymizer creation", err, logger)
 
 		chTerminate := make(chan os.Signal, 1)
@@ -174,4 +173,5 @@ func init() {
 	viper.BindPFlag(providentiaCmd.Name()+".oneshot", providentiaCmd.PersistentFlags().Lookup("oneshot"))
 
 	app.RegisterOutputKafka(providentiaCmd.Name(), providentiaCmd.PersistentFlags())
+	app.RegisterAnonymizer(providentiaCmd.Name(), providentiaCmd.PersistentFlags())
 }
@@ -2,10 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"go-peek/internal/app"
 	"go-peek/pkg/ingest/kafka"
 	"os"
 	"path"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/sirupsen/logrus"
 
@@ -27,8 +29,9 @@ var kafkaOffset = kafka.OffsetLatest
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "peek",
-	Short: "Simple streaming pre-processor and enrichment tool for structured logs.",
+	Use:     "peek",
+	Short:   "Simple streaming pre-processor and enrichment tool for structured logs.",
+	Version: app.BuildVersion(),
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -54,6 +57,9 @@ func init() {
 		".local/peek",
 	), "Working directory for storing dumps, temp files, etc.")
 	viper.BindPFlag("work.dir", rootCmd.PersistentFlags().Lookup("work-dir"))
+
+	rootCmd.PersistentFlags().String("metrics-listen", "", "Expose prometheus metrics on this address, e.g. :9100. Disabled if empty.")
+	viper.BindPFlag("metrics.listen", rootCmd.PersistentFlags().Lookup("metrics-listen"))
 }
 
 func initLogging() {
@@ -103,3 +109,35 @@ func initConfig() {
 		logger.Info("Using config file: ", viper.ConfigFileUsed())
 	}
 }
+
+// seekConsumerGroup moves consumer group offsets of topics when seek options are set
+// resolved offsets are printed, returns true on dry run as command should exit without consuming
+func seekConsumerGroup(prefix string, topics []string) bool {
+	seek, err := app.KafkaSeek(prefix)
+	app.Throw("kafka seek options", err, logger)
+	if seek.Empty() {
+		return false
+	}
+	offsets, err := kafka.SeekConsumerGroup(kafka.SeekConfig{
+		Brokers:       viper.GetStringSlice(prefix + ".input.kafka.brokers"),
+		ConsumerGroup: viper.GetString(prefix + ".input.kafka.consumer_group"),
+		Topics:        topics,
+		Security:      app.KafkaSecurity(prefix + ".input.kafka"),
+		OffsetMode:    kafkaOffset,
+		Seek:          seek,
+	})
+	app.Throw("kafka seek", err, logger)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range offsets.CSVFormat(true) {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	logger.WithFields(logrus.Fields{
+		"group":      viper.GetString(prefix + ".input.kafka.consumer_group"),
+		"partitions": len(offsets),
+		"dry_run":    seek.DryRun,
+	}).Info("consumer group seek resolved")
+	return seek.DryRun
+}
@@ -1,13 +1,16 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/providentia"
 	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 func Throw(context string, err error, logger *logrus.Logger) {
@@ -42,6 +45,20 @@ func Start(command string, logger *logrus.Logger) time.Time {
 	return time.Now()
 }
 
+// ServeMetrics exposes prometheus metrics if listen address is configured
+// server lives until process exits
+func ServeMetrics(command string, logger *logrus.Logger) {
+	addr := viper.GetString("metrics.listen")
+	if addr == "" {
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"command": command,
+		"listen":  addr,
+	}).Info("Exposing prometheus metrics")
+	Throw("metrics server", metrics.Serve(context.Background(), nil, addr, logger), logger)
+}
+
 func Done(command string, start time.Time, logger *logrus.Logger) {
 	logger.WithFields(
 		logrus.Fields{
@@ -2,6 +2,8 @@ package app
 
 import (
 	"fmt"
+	"go-peek/pkg/ingest/kafka"
+	"go-peek/pkg/kafkaauth"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -19,22 +21,32 @@ const (
 	FlagInKafkaTopicAssetsVcenter     = "input-kafka-topic-assets-vcenter"
 	FlagInKafkaTopicAssetsProvidentia = "input-kafka-topic-assets-providentia"
 	FlagInKafkaTopicOracle            = "input-kafka-topic-oracle"
+	FlagInKafkaTopicEmit              = "input-kafka-topic-emit"
 
 	// Syslog input
+	FlagInSyslogEnabled = "input-syslog-enabled"
 	FlagInSyslogUDPPort = "input-syslog-udp-port"
+	FlagInSyslogTCPPort = "input-syslog-tcp-port"
+	FlagInSyslogTCPIdle = "input-syslog-tcp-idle-timeout"
 
 	// Kafka topic mapper
 	FlagInKafkaTopicMapper = "input-kafka-topic-map"
 	FlagInKafkaTopicAssets = "input-kafka-topic-assets"
 	FlagInKafkaTopicSidMap = "input-kafka-topic-sid-mitre"
 
+	// Network segment table
+	FlagInNetworkTable = "input-network-table"
+
 	// Kafka Output
-	FlagOutKafkaEnabled     = "output-kafka-enabled"
-	FlagOutKafkaTopic       = "output-kafka-topic"
-	FlagOutKafkaBrokers     = "output-kafka-brokers"
-	FlagOutKafkaTopicSplit  = "output-kafka-topic-split"
-	FlagOutKafkaTopicEmit   = "output-kafka-topic-emit"
-	FlagOutKafkaTopicOracle = "output-kafka-topic-oracle"
+	FlagOutKafkaEnabled         = "output-kafka-enabled"
+	FlagOutKafkaTopic           = "output-kafka-topic"
+	FlagOutKafkaBrokers         = "output-kafka-brokers"
+	FlagOutKafkaTopicSplit      = "output-kafka-topic-split"
+	FlagOutKafkaTopicEmit       = "output-kafka-topic-emit"
+	FlagOutKafkaTopicOracle     = "output-kafka-topic-oracle"
+	FlagOutKafkaTopicIncidents  = "output-kafka-topic-incidents"
+	FlagOutKafkaTopicDeadLetter = "output-kafka-topic-dead-letter"
+	FlagOutKafkaHeaders         = "output-kafka-headers"
 
 	// Elastic Output
 	FlagOutElasticHosts     = "output-elastic-hosts"
@@ -47,6 +59,41 @@ const (
 
 	// Sigma flags
 	FlagSigmaRulesetPaths = "sigma-ruleset-path"
+	FlagSigmaFieldMap     = "sigma-field-map"
+	FlagSigmaWatch        = "sigma-watch"
+	FlagSigmaCorrelations = "sigma-correlation-path"
+
+	// Kafka security flags
+	FlagInKafkaTLS            = "input-kafka-tls"
+	FlagInKafkaTLSCA          = "input-kafka-tls-ca"
+	FlagInKafkaTLSCert        = "input-kafka-tls-cert"
+	FlagInKafkaTLSKey         = "input-kafka-tls-key"
+	FlagInKafkaTLSSkipVerify  = "input-kafka-tls-skip-verify"
+	FlagInKafkaSASLMechanism  = "input-kafka-sasl-mechanism"
+	FlagInKafkaSASLUser       = "input-kafka-sasl-user"
+	FlagInKafkaSASLPassword   = "input-kafka-sasl-password"
+	FlagOutKafkaTLS           = "output-kafka-tls"
+	FlagOutKafkaTLSCA         = "output-kafka-tls-ca"
+	FlagOutKafkaTLSCert       = "output-kafka-tls-cert"
+	FlagOutKafkaTLSKey        = "output-kafka-tls-key"
+	FlagOutKafkaTLSSkipVerify = "output-kafka-tls-skip-verify"
+	FlagOutKafkaSASLMechanism = "output-kafka-sasl-mechanism"
+	FlagOutKafkaSASLUser      = "output-kafka-sasl-user"
+	FlagOutKafkaSASLPassword  = "output-kafka-sasl-password"
+
+	// Kafka seek flags
+	FlagInKafkaSeekTime     = "input-kafka-seek-time"
+	FlagInKafkaSeekOffsets  = "input-kafka-seek-offsets"
+	FlagInKafkaSeekRelative = "input-kafka-seek-relative"
+	FlagInKafkaSeekTopics   = "input-kafka-seek-topics"
+	FlagInKafkaSeekDryRun   = "input-kafka-seek-dry-run"
+
+	// Delivery flags
+	FlagAtLeastOnce = "at-least-once"
+
+	// Anonymizer flags
+	FlagAnonymizerSecret = "anonymizer-secret"
+	FlagAnonymizerImport = "anonymizer-import"
 )
 
 func RegisterOutputKafka(prefix string, pFlags *pflag.FlagSet) {
@@ -58,6 +105,14 @@ func RegisterOutputKafka(prefix string, pFlags *pflag.FlagSet) {
 
 	pFlags.StringSlice(FlagOutKafkaBrokers, []string{"localhost:9092"}, "Kafka output broker list")
 	viper.BindPFlag(prefix+".output.kafka.brokers", pFlags.Lookup(FlagOutKafkaBrokers))
+
+	RegisterOutputKafkaSecurity(prefix, pFlags)
+	RegisterOutputKafkaHeaders(prefix, pFlags)
+}
+
+func RegisterOutputKafkaHeaders(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.Bool(FlagOutKafkaHeaders, true, "Add provenance record headers with input position, event kind, stage, peek version and processing time.")
+	viper.BindPFlag(prefix+".output.kafka.headers", pFlags.Lookup(FlagOutKafkaHeaders))
 }
 
 func RegisterOutputKafkaOracle(prefix string, pFlags *pflag.FlagSet) {
@@ -65,6 +120,11 @@ func RegisterOutputKafkaOracle(prefix string, pFlags *pflag.FlagSet) {
 	viper.BindPFlag(prefix+".output.kafka.topic_oracle", pFlags.Lookup(FlagOutKafkaTopicOracle))
 }
 
+func RegisterOutputKafkaDeadLetter(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.String(FlagOutKafkaTopicDeadLetter, "", "Kafka topic for messages that could not be handled, wrapped in envelope with origin and error. Empty disables dead-letter output.")
+	viper.BindPFlag(prefix+".output.kafka.topic_dead_letter", pFlags.Lookup(FlagOutKafkaTopicDeadLetter))
+}
+
 func RegisterInputKafkaOracle(prefix string, pFlags *pflag.FlagSet) {
 	pFlags.String(FlagInKafkaTopicOracle, "peek-oracle", "Kafka topic sending oracle metadata.")
 	viper.BindPFlag(prefix+".input.kafka.topic_oracle", pFlags.Lookup(FlagInKafkaTopicOracle))
@@ -79,6 +139,24 @@ func RegisterOutputKafkaEnrichment(prefix string, pFlags *pflag.FlagSet) {
 	viper.BindPFlag(prefix+".output.kafka.topic_emit", pFlags.Lookup(FlagOutKafkaTopicEmit))
 }
 
+func RegisterInputKafkaCorrelate(prefix string, pFlags *pflag.FlagSet) {
+	RegisterInputKafkaCore(prefix, pFlags)
+
+	pFlags.String(FlagInKafkaTopicEmit, "emit", "Kafka topic holding fast-tracked events from enrichment.")
+	viper.BindPFlag(prefix+".input.kafka.topic_emit", pFlags.Lookup(FlagInKafkaTopicEmit))
+}
+
+func RegisterOutputKafkaCorrelate(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.StringSlice(FlagOutKafkaBrokers, []string{"localhost:9092"}, "Kafka output broker list")
+	viper.BindPFlag(prefix+".output.kafka.brokers", pFlags.Lookup(FlagOutKafkaBrokers))
+
+	RegisterOutputKafkaSecurity(prefix, pFlags)
+	RegisterOutputKafkaHeaders(prefix, pFlags)
+
+	pFlags.String(FlagOutKafkaTopicIncidents, "incidents", "Kafka topic for incident open, update and close records.")
+	viper.BindPFlag(prefix+".output.kafka.topic_incidents", pFlags.Lookup(FlagOutKafkaTopicIncidents))
+}
+
 func RegisterOutputElastic(prefix string, pFlags *pflag.FlagSet) {
 	pFlags.StringSlice(FlagOutElasticHosts, []string{"http://localhost:9200"}, "List of elastic hosts. Needs http:// prefix.")
 	viper.BindPFlag(prefix+".output.elasticsearch.hosts", pFlags.Lookup(FlagOutElasticHosts))
@@ -121,13 +199,144 @@ func RegisterInputKafkaCore(prefix string, pFlags *pflag.FlagSet) {
 
 	pFlags.String(FlagInKafkaConsumerGroup, "peek", "Kafka consumer group")
 	viper.BindPFlag(prefix+".input.kafka.consumer_group", pFlags.Lookup(FlagInKafkaConsumerGroup))
+
+	RegisterInputKafkaSecurity(prefix, pFlags)
+	RegisterInputKafkaSeek(prefix, pFlags)
+}
+
+func RegisterInputKafkaSeek(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.String(FlagInKafkaSeekTime, "", "Move consumer group to first message at or after this time before consuming. RFC3339 timestamp or duration ago, e.g. 26h.")
+	viper.BindPFlag(prefix+".input.kafka.seek.time", pFlags.Lookup(FlagInKafkaSeekTime))
+
+	pFlags.StringSlice(FlagInKafkaSeekOffsets, []string{}, "Move consumer group to explicit offsets before consuming. Topic, partition and offset separated by colon.")
+	viper.BindPFlag(prefix+".input.kafka.seek.offsets", pFlags.Lookup(FlagInKafkaSeekOffsets))
+
+	pFlags.Int64(FlagInKafkaSeekRelative, 0, "Move committed consumer group offsets by this many messages before consuming. Negative value rewinds.")
+	viper.BindPFlag(prefix+".input.kafka.seek.relative", pFlags.Lookup(FlagInKafkaSeekRelative))
+
+	pFlags.StringSlice(FlagInKafkaSeekTopics, []string{}, "Limit time and relative seek to these topics. All consumed topics are moved if empty.")
+	viper.BindPFlag(prefix+".input.kafka.seek.topics", pFlags.Lookup(FlagInKafkaSeekTopics))
+
+	pFlags.Bool(FlagInKafkaSeekDryRun, false, "Print resolved seek offsets per topic and partition and exit without committing them.")
+	viper.BindPFlag(prefix+".input.kafka.seek.dry_run", pFlags.Lookup(FlagInKafkaSeekDryRun))
+}
+
+// KafkaSeek reads consumer group seek options registered for command
+func KafkaSeek(prefix string) (kafka.Seek, error) {
+	ts, err := kafka.ParseSeekTime(viper.GetString(prefix+".input.kafka.seek.time"), time.Now())
+	if err != nil {
+		return kafka.Seek{}, err
+	}
+	offsets, err := kafka.ParseSeekOffsets(viper.GetStringSlice(prefix + ".input.kafka.seek.offsets"))
+	if err != nil {
+		return kafka.Seek{}, err
+	}
+	seek := kafka.Seek{
+		Time:     ts,
+		Offsets:  offsets,
+		Relative: viper.GetInt64(prefix + ".input.kafka.seek.relative"),
+		Topics:   viper.GetStringSlice(prefix + ".input.kafka.seek.topics"),
+		DryRun:   viper.GetBool(prefix + ".input.kafka.seek.dry_run"),
+	}
+	return seek, seek.Validate()
+}
+
+func RegisterInputKafkaSecurity(prefix string, pFlags *pflag.FlagSet) {
+	registerKafkaSecurity(prefix+".input.kafka", pFlags, kafkaSecurityFlags{
+		TLS:           FlagInKafkaTLS,
+		TLSCA:         FlagInKafkaTLSCA,
+		TLSCert:       FlagInKafkaTLSCert,
+		TLSKey:        FlagInKafkaTLSKey,
+		TLSSkipVerify: FlagInKafkaTLSSkipVerify,
+		SASLMechanism: FlagInKafkaSASLMechanism,
+		SASLUser:      FlagInKafkaSASLUser,
+		SASLPassword:  FlagInKafkaSASLPassword,
+	})
+}
+
+func RegisterOutputKafkaSecurity(prefix string, pFlags *pflag.FlagSet) {
+	registerKafkaSecurity(prefix+".output.kafka", pFlags, kafkaSecurityFlags{
+		TLS:           FlagOutKafkaTLS,
+		TLSCA:         FlagOutKafkaTLSCA,
+		TLSCert:       FlagOutKafkaTLSCert,
+		TLSKey:        FlagOutKafkaTLSKey,
+		TLSSkipVerify: FlagOutKafkaTLSSkipVerify,
+		SASLMechanism: FlagOutKafkaSASLMechanism,
+		SASLUser:      FlagOutKafkaSASLUser,
+		SASLPassword:  FlagOutKafkaSASLPassword,
+	})
+}
+
+type kafkaSecurityFlags struct {
+	TLS, TLSCA, TLSCert, TLSKey, TLSSkipVerify string
+	SASLMechanism, SASLUser, SASLPassword      string
+}
+
+func registerKafkaSecurity(key string, pFlags *pflag.FlagSet, flags kafkaSecurityFlags) {
+	pFlags.Bool(flags.TLS, false, "Connect to kafka brokers over TLS")
+	viper.BindPFlag(key+".tls.enabled", pFlags.Lookup(flags.TLS))
+
+	pFlags.String(flags.TLSCA, "", "PEM CA bundle for verifying kafka brokers. System pool is used if empty.")
+	viper.BindPFlag(key+".tls.ca", pFlags.Lookup(flags.TLSCA))
+
+	pFlags.String(flags.TLSCert, "", "PEM client certificate for kafka TLS authentication")
+	viper.BindPFlag(key+".tls.cert", pFlags.Lookup(flags.TLSCert))
+
+	pFlags.String(flags.TLSKey, "", "PEM client key for kafka TLS authentication")
+	viper.BindPFlag(key+".tls.key", pFlags.Lookup(flags.TLSKey))
+
+	pFlags.Bool(flags.TLSSkipVerify, false, "Do not verify kafka broker certificates. Only for lab setups.")
+	viper.BindPFlag(key+".tls.skip_verify", pFlags.Lookup(flags.TLSSkipVerify))
+
+	pFlags.String(flags.SASLMechanism, "", "Kafka SASL mechanism: PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512. Empty disables SASL.")
+	viper.BindPFlag(key+".sasl.mechanism", pFlags.Lookup(flags.SASLMechanism))
+
+	pFlags.String(flags.SASLUser, "", "Kafka SASL user")
+	viper.BindPFlag(key+".sasl.user", pFlags.Lookup(flags.SASLUser))
+
+	pFlags.String(flags.SASLPassword, "", "Kafka SASL password")
+	viper.BindPFlag(key+".sasl.password", pFlags.Lookup(flags.SASLPassword))
+}
+
+// KafkaSecurity reads kafka security options registered under key, for example enrich.input.kafka
+func KafkaSecurity(key string) kafkaauth.Config {
+	return kafkaauth.Config{
+		TLS: kafkaauth.TLSConfig{
+			Enabled:    viper.GetBool(key + ".tls.enabled"),
+			CA:         viper.GetString(key + ".tls.ca"),
+			Cert:       viper.GetString(key + ".tls.cert"),
+			Key:        viper.GetString(key + ".tls.key"),
+			SkipVerify: viper.GetBool(key + ".tls.skip_verify"),
+		},
+		SASL: kafkaauth.SASLConfig{
+			Mechanism: viper.GetString(key + ".sasl.mechanism"),
+			User:      viper.GetString(key + ".sasl.user"),
+			Password:  viper.GetString(key + ".sasl.password"),
+		},
+	}
+}
+
+func RegisterInputSyslog(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.Bool(FlagInSyslogEnabled, false, "Enable syslog server input")
+	viper.BindPFlag(prefix+".input.syslog.enabled", pFlags.Lookup(FlagInSyslogEnabled))
+
+	RegisterInputSyslogUDP(prefix, pFlags)
+	RegisterInputSyslogTCP(prefix, pFlags)
 }
 
 func RegisterInputSyslogUDP(prefix string, pFlags *pflag.FlagSet) {
-	pFlags.Int(FlagInSyslogUDPPort, 514, "UDP syslog port")
+	pFlags.Int(FlagInSyslogUDPPort, 514, "UDP syslog port. 0 disables UDP listener.")
 	viper.BindPFlag(prefix+".input.syslog.udp.port", pFlags.Lookup(FlagInSyslogUDPPort))
 }
 
+func RegisterInputSyslogTCP(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.Int(FlagInSyslogTCPPort, 514, "TCP syslog port. Octet counting and newline framing. 0 disables TCP listener.")
+	viper.BindPFlag(prefix+".input.syslog.tcp.port", pFlags.Lookup(FlagInSyslogTCPPort))
+
+	pFlags.Duration(FlagInSyslogTCPIdle, 5*time.Minute, "Close TCP syslog connections that send nothing for this long.")
+	viper.BindPFlag(prefix+".input.syslog.tcp.idle_timeout", pFlags.Lookup(FlagInSyslogTCPIdle))
+}
+
 func RegisterInputKafkaTopicMap(prefix string, pFlags *pflag.FlagSet) {
 	pFlags.StringSlice(FlagInKafkaTopicMapper, []string{}, "Topic and event type separated by colon")
 	viper.BindPFlag(prefix+".input.kafka.topic_map", pFlags.Lookup(FlagInKafkaTopicMapper))
@@ -141,9 +350,36 @@ func RegisterInputKafkaEnrich(prefix string, pFlags *pflag.FlagSet) {
 	viper.BindPFlag(prefix+".input.kafka.topic_sid_mitre", pFlags.Lookup(FlagInKafkaTopicSidMap))
 }
 
+func RegisterInputNetworkTable(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.String(FlagInNetworkTable, "", "Network segment table for CIDR lookups. Pandas JSON export, CSV or YAML.")
+	viper.BindPFlag(prefix+".input.network_table", pFlags.Lookup(FlagInNetworkTable))
+}
+
 func RegisterSigmaRulesetPaths(prefix string, pFlags *pflag.FlagSet) {
 	pFlags.StringSlice(FlagSigmaRulesetPaths, []string{}, "Ruleset kind and path separated by colon")
 	viper.BindPFlag(prefix+".sigma.ruleset_path", pFlags.Lookup(FlagSigmaRulesetPaths))
+
+	pFlags.String(FlagSigmaFieldMap, "", "YAML file that maps sigma field names to event paths per event kind. Overrides built-in mappings.")
+	viper.BindPFlag(prefix+".sigma.field_map", pFlags.Lookup(FlagSigmaFieldMap))
+
+	pFlags.Bool(FlagSigmaWatch, false, "Reload sigma rulesets when rule files change. SIGHUP triggers reload regardless.")
+	viper.BindPFlag(prefix+".sigma.watch", pFlags.Lookup(FlagSigmaWatch))
+
+	pFlags.StringSlice(FlagSigmaCorrelations, []string{}, "Files or directories with sigma correlation rules. Documents without correlation section are ignored.")
+	viper.BindPFlag(prefix+".sigma.correlation_path", pFlags.Lookup(FlagSigmaCorrelations))
+}
+
+func RegisterDelivery(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.Bool(FlagAtLeastOnce, false, "Commit input offsets only after outputs are acknowledged by kafka. Restart may replay some events, but does not drop them.")
+	viper.BindPFlag(prefix+".delivery.at_least_once", pFlags.Lookup(FlagAtLeastOnce))
+}
+
+func RegisterAnonymizer(prefix string, pFlags *pflag.FlagSet) {
+	pFlags.String(FlagAnonymizerSecret, "", "Exercise secret for deterministic aliases. Random aliases from name pool are used if empty.")
+	viper.BindPFlag(prefix+".anonymizer.secret", pFlags.Lookup(FlagAnonymizerSecret))
+
+	pFlags.String(FlagAnonymizerImport, "", "Alias table to load on startup, JSON or CSV by file extension. Existing aliases are replaced.")
+	viper.BindPFlag(prefix+".anonymizer.import", pFlags.Lookup(FlagAnonymizerImport))
 }
 
 func RegisterLogging(prefix string, pFlags *pflag.FlagSet) {
@@ -75,3 +75,30 @@ func (k KafkaTopicItems) TopicMap() TopicMapFunc {
 		return events.SimpleE, false
 	}
 }
+
+// PseudoTopics maps output topics to pseudonymized copy topics
+// empty copy topic means topic itself only receives pseudonymized events
+type PseudoTopics map[string]string
+
+// ParsePseudoTopicItems parses topic or topic:copy items
+func ParsePseudoTopicItems(flags []string) (PseudoTopics, error) {
+	items := make(PseudoTopics, len(flags))
+	for _, item := range flags {
+		bits := strings.Split(item, ":")
+		if len(bits) > 2 {
+			return items, ErrInvalidTopicItem{item, "should split to at most 2 substrings"}
+		}
+		if bits[0] == "" {
+			return items, ErrInvalidTopicItem{item, "empty topic"}
+		}
+		if len(bits) == 1 {
+			items[bits[0]] = ""
+			continue
+		}
+		if bits[1] == "" || bits[1] == bits[0] {
+			return items, ErrInvalidTopicItem{item, "copy topic should differ from source topic"}
+		}
+		items[bits[0]] = bits[1]
+	}
+	return items, nil
+}
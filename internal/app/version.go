@@ -0,0 +1,23 @@
+package app
+
+import "runtime/debug"
+
+// Version is set at build time with -ldflags "-X go-peek/internal/app.Version=..."
+var Version = "dev"
+
+// BuildVersion returns Version, or VCS revision embedded by go build if version was not set
+func BuildVersion() string {
+	if Version != "dev" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return Version + "-" + setting.Value[:12]
+		}
+	}
+	return Version
+}
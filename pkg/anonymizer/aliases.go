@@ -0,0 +1,121 @@
+package anonymizer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ErrAliasFormat struct {
+	Format string
+}
+
+func (e ErrAliasFormat) Error() string {
+	return fmt.Sprintf("unsupported alias table format %s, use json or csv", e.Format)
+}
+
+// Aliases is exportable alias table
+type Aliases []Pretty
+
+// Keys is for implementing CSV header
+func (a Aliases) Keys() []string {
+	return []string{"name", "alias", "first_seen", "last_seen"}
+}
+
+// CSVFormat implements atomic.CSVFormatter
+func (a Aliases) CSVFormat(header bool) [][]string {
+	tx := make([][]string, 0, len(a)+1)
+	if header {
+		tx = append(tx, a.Keys())
+	}
+	for _, item := range a {
+		tx = append(tx, []string{
+			item.Name,
+			item.Rename,
+			item.FirstSeen.Format(time.RFC3339),
+			item.LastSeen.Format(time.RFC3339),
+		})
+	}
+	return tx
+}
+
+// Write encodes alias table in json or csv format
+func (a Aliases) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(a.CSVFormat(true)); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+	return ErrAliasFormat{Format: format}
+}
+
+// ReadAliases decodes alias table in json or csv format
+// CSV timestamps are optional
+func ReadAliases(r io.Reader, format string) (Aliases, error) {
+	switch format {
+	case "json":
+		var tx Aliases
+		if err := json.NewDecoder(r).Decode(&tx); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		tx := make(Aliases, 0, len(rows))
+		for i, row := range rows {
+			// header row is optional
+			if i == 0 && len(row) > 1 && row[0] == "name" && row[1] == "alias" {
+				continue
+			}
+			if len(row) < 2 {
+				return nil, fmt.Errorf("alias table row %d has %d columns, need at least name and alias", i+1, len(row))
+			}
+			item := Pretty{Name: row[0], Rename: row[1]}
+			if len(row) > 2 && row[2] != "" {
+				if item.FirstSeen, err = time.Parse(time.RFC3339, row[2]); err != nil {
+					return nil, err
+				}
+			}
+			if len(row) > 3 && row[3] != "" {
+				if item.LastSeen, err = time.Parse(time.RFC3339, row[3]); err != nil {
+					return nil, err
+				}
+			}
+			tx = append(tx, item)
+		}
+		return tx, nil
+	}
+	return nil, ErrAliasFormat{Format: format}
+}
+
+// LoadAliases reads alias table from file, format is deduced from extension
+func LoadAliases(path string) (Aliases, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadAliases(f, AliasFormat(path))
+}
+
+// AliasFormat returns table format from file extension
+func AliasFormat(path string) string {
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
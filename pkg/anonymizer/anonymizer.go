@@ -2,11 +2,17 @@ package anonymizer
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
 	"errors"
+	"fmt"
 	"go-peek/pkg/persist"
 	"math/rand"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	_ "embed"
@@ -18,24 +24,40 @@ var ErrEmptyPool = errors.New("rename pool is empty")
 
 const (
 	PersistKeyPrefix = "pretty"
+	// aliasWords is number of pool words combined into generated alias
+	aliasWords = 3
 )
 
+type ErrAliasConflict struct {
+	Name  string
+	Alias string
+	Owner string
+}
+
+func (e ErrAliasConflict) Error() string {
+	return fmt.Sprintf("alias %s for %s is already assigned to %s", e.Alias, e.Name, e.Owner)
+}
+
 //go:embed name_pool.txt
 var pool []byte
 
 type Config struct {
 	Persist *persist.Badger
 	Logger  *logrus.Logger
+	// Secret enables deterministic aliases, same secret yields same aliases between environments
+	Secret string
 }
 
 type Pretty struct {
-	Name   string
-	Rename string
+	Name   string `json:"name"`
+	Rename string `json:"alias"`
 
-	FirstSeen time.Time
-	LastSeen  time.Time
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
 }
 
+// Mapper assigns persistent aliases from name pool
+// safe for concurrent use
 type Mapper struct {
 	Data    map[string]*Pretty
 	Pool    map[string]bool
@@ -43,34 +65,37 @@ type Mapper struct {
 
 	Hits, Misses int
 
+	// words is sorted name pool for building combinatorial aliases
+	words  []string
+	secret []byte
+
+	mu     *sync.Mutex
 	logger *logrus.Logger
 }
 
 func (m *Mapper) CheckAndUpdate(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if val, ok := m.Data[name]; ok && val.Rename != "" {
 		m.Hits++
 		val.LastSeen = time.Now()
 		return val.Rename, nil
 	}
 	m.Misses++
-	if len(m.Pool) == 0 {
-		return "", ErrEmptyPool
+	var rename string
+	switch {
+	case len(m.secret) > 0:
+		rename = m.keyed(name)
+	case len(m.Pool) > 0:
+		rename = m.draw()
+	default:
+		// pool is spent, keep going with word combinations rather than failing the stream
+		rename = m.combine()
 	}
-	idx := rand.Intn(len(m.Pool))
-	var (
-		rename string
-		offset int
-	)
-
-loop:
-	for key := range m.Pool {
-		if offset == idx {
-			rename = key
-			delete(m.Pool, key)
-			break loop
-		}
-		offset++
+	if rename == "" {
+		return "", ErrEmptyPool
 	}
+	delete(m.Pool, rename)
 
 	pretty := &Pretty{
 		Name:      name,
@@ -89,8 +114,7 @@ loop:
 		m.logger.WithFields(logrus.Fields{
 			"name":      name,
 			"alias":     rename,
-			"idx":       idx,
-			"offset":    offset,
+			"keyed":     len(m.secret) > 0,
 			"pool_size": len(m.Pool),
 		}).Trace("anonymizer name not found")
 	}
@@ -98,13 +122,230 @@ loop:
 	return pretty.Rename, nil
 }
 
+// draw picks random unused name from pool
+func (m *Mapper) draw() string {
+	idx := rand.Intn(len(m.Pool))
+	var offset int
+	for key := range m.Pool {
+		if offset == idx {
+			return key
+		}
+		offset++
+	}
+	return ""
+}
+
+// combine builds random alias from pool words, adding words until alias is unique
+func (m *Mapper) combine() string {
+	if len(m.words) == 0 {
+		return ""
+	}
+	words := make([]string, 0, aliasWords)
+	for {
+		words = append(words, m.words[rand.Intn(len(m.words))])
+		if len(words) < aliasWords {
+			continue
+		}
+		alias := strings.Join(words, "-")
+		if _, ok := m.Data[alias]; !ok {
+			return alias
+		}
+	}
+}
+
+// keyed derives alias from HMAC of name, so it does not depend on order of appearance
+// digest is consumed in 4 byte chunks, each selecting a pool word
+// further words are added only if alias is already taken by another name
+func (m *Mapper) keyed(name string) string {
+	if len(m.words) == 0 {
+		return ""
+	}
+	sum := m.digest(name)
+
+	words := make([]string, 0, len(sum)/4)
+	for i := 0; i+4 <= len(sum); i += 4 {
+		idx := binary.BigEndian.Uint32(sum[i:]) % uint32(len(m.words))
+		words = append(words, m.words[idx])
+		if len(words) < aliasWords {
+			continue
+		}
+		alias := strings.Join(words, "-")
+		if val, ok := m.Data[alias]; !ok || val.Name == name {
+			return alias
+		}
+	}
+	return fmt.Sprintf("%s-%x", strings.Join(words[:aliasWords], "-"), sum)
+}
+
+// digest is HMAC of name with mapper secret
+func (m *Mapper) digest(name string) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(name))
+	return mac.Sum(nil)
+}
+
+// Lookup returns alias for name without assigning a new one
+func (m *Mapper) Lookup(name string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if val, ok := m.Data[name]; ok && val.Rename != "" {
+		return val.Rename, true
+	}
+	return "", false
+}
+
+// Reverse returns original name for alias
+func (m *Mapper) Reverse(alias string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if val, ok := m.Data[alias]; ok && val.Rename == alias {
+		return val.Name, true
+	}
+	return "", false
+}
+
+// Export returns alias table sorted by name
+func (m *Mapper) Export() Aliases {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := make(Aliases, 0, len(m.Data)/2)
+	for key, val := range m.Data {
+		// every record is stored under both name and alias
+		if key != val.Name {
+			continue
+		}
+		tx = append(tx, *val)
+	}
+	sort.Slice(tx, func(i, j int) bool { return tx[i].Name < tx[j].Name })
+	return tx
+}
+
+// Import loads alias table from another environment
+// existing aliases are replaced, several names may share an alias, for example host label and FQDN
+// import fails if alias belongs to a different canonical name that imported table does not bind to it
+func (m *Mapper) Import(aliases Aliases) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bound := make(map[string]bool, len(aliases))
+	for _, item := range aliases {
+		bound[item.Rename+"\x00"+item.Name] = true
+	}
+	for _, item := range aliases {
+		if item.Name == "" || item.Rename == "" {
+			continue
+		}
+		owner, owned := m.Data[item.Rename]
+		if owned && owner.Name != item.Name &&
+			!bound[item.Rename+"\x00"+owner.Name] &&
+			!m.shared(item.Rename, item.Name) {
+			return ErrAliasConflict{Name: item.Name, Alias: item.Rename, Owner: owner.Name}
+		}
+		if val, ok := m.Data[item.Name]; ok && val.Rename != item.Rename {
+			// previous alias may still be shared with other names
+			if prev, ok := m.Data[val.Rename]; ok && prev.Name == item.Name {
+				delete(m.Data, val.Rename)
+				if err := m.Persist.Delete(PersistKeyPrefix, val.Rename); err != nil {
+					return err
+				}
+			}
+		}
+		pretty := item
+		if pretty.FirstSeen.IsZero() {
+			pretty.FirstSeen = time.Now()
+		}
+		if pretty.LastSeen.IsZero() {
+			pretty.LastSeen = pretty.FirstSeen
+		}
+		values := []persist.GenericValue{{Key: pretty.Name, Data: pretty}}
+		m.Data[pretty.Name] = &pretty
+		// alias keeps pointing to first name, so reverse lookup is stable
+		if !owned || owner.Name == pretty.Name {
+			m.Data[pretty.Rename] = &pretty
+			values = append(values, persist.GenericValue{Key: pretty.Rename, Data: pretty})
+		}
+		delete(m.Pool, pretty.Rename)
+		if err := m.Persist.Set(PersistKeyPrefix, values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shared reports if alias is already bound to a name with same canonical form
+// caller must hold lock
+func (m *Mapper) shared(alias, name string) bool {
+	for key, val := range m.Data {
+		if key == val.Name && val.Rename == alias && canonical(val.Name) == canonical(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical strips domain from host names, so FQDN and host label are treated as the same name
+func canonical(name string) string {
+	if !strings.HasPrefix(name, pseudoHost) {
+		return name
+	}
+	return strings.SplitN(name, ".", 2)[0]
+}
+
+// Len returns number of known names and aliases
+func (m *Mapper) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Data)
+}
+
+// Count returns number of names with given prefix
+func (m *Mapper) Count(prefix string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int
+	for name := range m.Data {
+		if strings.HasPrefix(name, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// Reserve binds name to alias that was assigned elsewhere, for example by inventory
+// alias is taken out of the pool, so it would not be handed out to another name
+func (m *Mapper) Reserve(name, rename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if val, ok := m.Data[name]; ok && val.Rename == rename {
+		val.LastSeen = time.Now()
+		return nil
+	}
+	pretty := &Pretty{
+		Name:      name,
+		Rename:    rename,
+		FirstSeen: time.Now(),
+		LastSeen:  time.Now(),
+	}
+	m.Data[pretty.Name] = pretty
+	m.Data[pretty.Rename] = pretty
+	delete(m.Pool, rename)
+	return m.Persist.Set(
+		PersistKeyPrefix,
+		persist.GenericValue{Key: pretty.Name, Data: pretty},
+		persist.GenericValue{Key: pretty.Rename, Data: pretty},
+	)
+}
+
 func NewMapper(c Config) (*Mapper, error) {
 	m := &Mapper{
 		Pool:    make(map[string]bool),
 		Data:    make(map[string]*Pretty),
 		Persist: c.Persist,
+		mu:      &sync.Mutex{},
 		logger:  c.Logger,
 	}
+	if c.Secret != "" {
+		m.secret = []byte(c.Secret)
+	}
 
 	records := m.Persist.Scan(PersistKeyPrefix)
 	var count int
@@ -127,7 +368,14 @@ func NewMapper(c Config) (*Mapper, error) {
 		Available int
 	}
 	names := strings.Split(string(pool), "\n")
+	unique := make(map[string]bool, len(names))
 	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || unique[name] {
+			continue
+		}
+		unique[name] = true
+		m.words = append(m.words, name)
 		// only load names that have not been used
 		if _, ok := m.Data[name]; !ok {
 			m.Pool[name] = true
@@ -144,5 +392,6 @@ func NewMapper(c Config) (*Mapper, error) {
 			"pool_names":     len(names),
 		}).Trace("rename pool setup")
 	}
+	sort.Strings(m.words)
 	return m, nil
 }
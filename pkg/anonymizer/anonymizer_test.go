@@ -0,0 +1,144 @@
+package anonymizer
+
+import (
+	"bytes"
+	"go-peek/pkg/persist"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestMapper(t *testing.T, secret string) *Mapper {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	m, err := NewMapper(Config{Persist: p, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMapperKeyed(t *testing.T) {
+	staging, prod := newTestMapper(t, "exercise"), newTestMapper(t, "exercise")
+	// order of appearance must not matter
+	for _, name := range []string{"ws01", "ws02", "dc01"} {
+		if _, err := staging.CheckAndUpdate(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"dc01", "ws02", "ws01"} {
+		alias, err := prod.CheckAndUpdate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, _ := staging.Lookup(name); alias != expected {
+			t.Fatalf("%s alias mismatch between environments: %s and %s", name, expected, alias)
+		}
+		if reversed, ok := prod.Reverse(alias); !ok || reversed != name {
+			t.Fatalf("reverse lookup of %s returned %s", alias, reversed)
+		}
+	}
+	other, _ := newTestMapper(t, "other").CheckAndUpdate("ws01")
+	if expected, _ := staging.Lookup("ws01"); other == expected {
+		t.Fatal("different secret should yield different alias")
+	}
+}
+
+func TestMapperSpentPool(t *testing.T) {
+	m := newTestMapper(t, "")
+	m.Pool = map[string]bool{}
+	alias, err := m.CheckAndUpdate("ws01")
+	if err != nil || alias == "" {
+		t.Fatalf("spent pool should fall back to word combinations, got %q: %v", alias, err)
+	}
+}
+
+func TestMapperExportImport(t *testing.T) {
+	src, dst := newTestMapper(t, ""), newTestMapper(t, "")
+	for _, name := range []string{"ws01", "dc01"} {
+		if _, err := src.CheckAndUpdate(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, format := range []string{"json", "csv"} {
+		var buf bytes.Buffer
+		if err := src.Export().Write(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		aliases, err := ReadAliases(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(aliases) != 2 || aliases[0].Name != "dc01" {
+			t.Fatalf("%s export should hold sorted names, got %+v", format, aliases)
+		}
+		if err := dst.Import(aliases); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"ws01", "dc01"} {
+		expected, _ := src.Lookup(name)
+		if alias, _ := dst.CheckAndUpdate(name); alias != expected {
+			t.Fatalf("imported alias for %s should be %s, got %s", name, expected, alias)
+		}
+	}
+	conflict, _ := src.Lookup("ws01")
+	if err := dst.Import(Aliases{{Name: "ws99", Rename: conflict}}); err == nil {
+		t.Fatal("alias owned by another name should not be imported")
+	}
+}
+
+func TestMapperImportSharedAlias(t *testing.T) {
+	src, dst := newTestMapper(t, ""), newTestMapper(t, "")
+	pseudo, err := NewPseudonymizer(PseudoConfig{Mapper: src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// inventory names, host label and FQDN all resolve to the same alias
+	if err := pseudo.Reserve("Gizmo", "ws01", "team01_ws01", "ws01.corp.ex"); err != nil {
+		t.Fatal(err)
+	}
+	alias := pseudo.Host("srv02.corp.ex")
+
+	var buf bytes.Buffer
+	if err := src.Export().Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	aliases, err := ReadAliases(&buf, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 5 {
+		t.Fatalf("expected every name to be exported, got %+v", aliases)
+	}
+	for i := 0; i < 2; i++ {
+		if err := dst.Import(aliases); err != nil {
+			t.Fatalf("import %d: %s", i, err)
+		}
+	}
+	for name, expected := range map[string]string{
+		"host/ws01":          "Gizmo",
+		"host/team01_ws01":   "Gizmo",
+		"host/ws01.corp.ex":  "Gizmo",
+		"host/srv02":         alias,
+		"host/srv02.corp.ex": alias,
+	} {
+		if got, _ := dst.Lookup(name); got != expected {
+			t.Fatalf("imported alias for %s should be %s, got %s", name, expected, got)
+		}
+	}
+	if name, ok := dst.Reverse("Gizmo"); !ok || name != "host/team01_ws01" {
+		t.Fatalf("alias should reverse to first imported name, got %s", name)
+	}
+	if err := dst.Import(Aliases{{Name: "host/db01", Rename: "Gizmo"}}); err == nil {
+		t.Fatal("alias owned by another name should not be imported")
+	}
+	if err := dst.Import(Aliases{{Name: "host/ws01.other.ex", Rename: "Gizmo"}}); err != nil {
+		t.Fatalf("FQDN of aliased host label should be imported, got %s", err)
+	}
+}
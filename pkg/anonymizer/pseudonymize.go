@@ -0,0 +1,252 @@
+package anonymizer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// key prefixes keep aliases of different value kinds apart in mapper
+const (
+	pseudoHost = "host/"
+	pseudoUser = "user/"
+	pseudoIP   = "ip/"
+)
+
+// pseudoNet is RFC 2544 benchmarking range, internal addresses are mapped into it
+// in order of appearance, or by HMAC of address if mapper has a secret
+var pseudoNet = net.IPNet{IP: net.IPv4(198, 18, 0, 0).To4(), Mask: net.CIDRMask(15, 32)}
+
+// pseudoKeep lists built-in accounts and domains that do not reveal anything about game network
+var pseudoKeep = map[string]bool{
+	"-":               true,
+	"system":          true,
+	"local service":   true,
+	"network service": true,
+	"nt authority":    true,
+	"root":            true,
+}
+
+// pseudoToken matches words in free text that could be host or user names
+var pseudoToken = regexp.MustCompile(`[\p{L}\p{N}_.$-]{2,}`)
+
+var ErrMissingMapper = errors.New("missing anonymizer mapper")
+
+// PseudoConfig is used as parameter when instanciating new Pseudonymizer
+type PseudoConfig struct {
+	Mapper *Mapper
+	// IPs enables address rewrite for internal addresses
+	IPs bool
+	// Networks are considered internal, private ranges are used if empty
+	Networks []*net.IPNet
+}
+
+func (c PseudoConfig) Validate() error {
+	if c.Mapper == nil {
+		return ErrMissingMapper
+	}
+	return nil
+}
+
+// Pseudonymizer implements meta.Pseudonyms with persistent mapper aliases
+// safe for concurrent use
+type Pseudonymizer struct {
+	mapper   *Mapper
+	ips      bool
+	networks []*net.IPNet
+
+	// mu serializes alias allocation, so concurrent events would not assign two aliases for one value
+	mu     *sync.Mutex
+	nextIP uint32
+
+	Errors uint
+}
+
+// Host returns alias for host name, FQDN resolves to alias of its host label
+func (p *Pseudonymizer) Host(name string) string {
+	if ip := net.ParseIP(name); ip != nil {
+		return p.IP(ip).String()
+	}
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" || pseudoKeep[key] || p.isAlias(name) {
+		return name
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if alias, ok := p.mapper.Lookup(pseudoHost + key); ok {
+		return alias
+	}
+	label := strings.SplitN(key, ".", 2)[0]
+	alias := p.assign(pseudoHost, label)
+	if label != key {
+		if err := p.mapper.Reserve(pseudoHost+key, alias); err != nil {
+			p.Errors++
+		}
+	}
+	return alias
+}
+
+// User returns alias for user name, machine accounts resolve to alias of the host
+func (p *Pseudonymizer) User(name string) string {
+	if strings.HasSuffix(name, "$") {
+		return p.Host(strings.TrimSuffix(name, "$")) + "$"
+	}
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" || pseudoKeep[key] || p.isAlias(name) {
+		return name
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.assign(pseudoUser, key)
+}
+
+// IP returns alias address for internal addresses
+func (p *Pseudonymizer) IP(ip net.IP) net.IP {
+	if !p.ips || ip == nil || !p.internal(ip) || pseudoNet.Contains(ip) {
+		return ip
+	}
+	key := pseudoIP + ip.String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if alias, ok := p.mapper.Lookup(key); ok {
+		return net.ParseIP(alias)
+	}
+	// network and broadcast addresses are skipped
+	ones, bits := pseudoNet.Mask.Size()
+	size := uint32(1<<(bits-ones)) - 2
+	var (
+		offset uint32
+		ok     bool
+	)
+	if len(p.mapper.secret) > 0 {
+		offset, ok = p.keyedIP(key, size)
+	} else if ok = p.nextIP < size; ok {
+		offset = p.nextIP
+		p.nextIP++
+	}
+	if !ok {
+		// never leak real address, even if it means losing distinction between hosts
+		p.Errors++
+		return pseudoNet.IP
+	}
+	alias := pseudoAddr(offset)
+	if err := p.mapper.Reserve(key, alias.String()); err != nil {
+		p.Errors++
+	}
+	return alias
+}
+
+// keyedIP derives address offset from HMAC of key, so environments sharing a secret get identical aliases
+// next free address is probed if derived one is already taken by another address
+// caller must hold lock
+func (p *Pseudonymizer) keyedIP(key string, size uint32) (uint32, bool) {
+	start := binary.BigEndian.Uint32(p.mapper.digest(key)) % size
+	for i := uint32(0); i < size; i++ {
+		offset := (start + i) % size
+		if _, taken := p.mapper.Reverse(pseudoAddr(offset).String()); !taken {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+func pseudoAddr(offset uint32) net.IP {
+	alias := make(net.IP, 4)
+	binary.BigEndian.PutUint32(alias, binary.BigEndian.Uint32(pseudoNet.IP)+offset+1)
+	return alias
+}
+
+// Replace rewrites known host and user names and internal addresses in free text
+// unknown words are kept, as text may refer to anything
+func (p *Pseudonymizer) Replace(text string) string {
+	if text == "" {
+		return text
+	}
+	return pseudoToken.ReplaceAllStringFunc(text, func(token string) string {
+		word := strings.TrimRight(token, ".")
+		suffix := token[len(word):]
+		if ip := net.ParseIP(word); ip != nil {
+			return p.IP(ip).String() + suffix
+		}
+		key := strings.ToLower(word)
+		if alias, ok := p.mapper.Lookup(pseudoHost + key); ok {
+			return alias + suffix
+		}
+		if alias, ok := p.mapper.Lookup(pseudoUser + key); ok {
+			return alias + suffix
+		}
+		return token
+	})
+}
+
+// Reserve binds inventory names to alias assigned by providentia stage
+// so event body and asset meta refer to host by the same alias
+func (p *Pseudonymizer) Reserve(alias string, names ...string) error {
+	if alias == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range names {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if key == "" {
+			continue
+		}
+		if err := p.mapper.Reserve(pseudoHost+key, alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign returns alias from pool, falling back to numbered alias if pool is empty
+// caller must hold lock
+func (p *Pseudonymizer) assign(prefix, key string) string {
+	alias, err := p.mapper.CheckAndUpdate(prefix + key)
+	if err == nil {
+		return alias
+	}
+	p.Errors++
+	alias = fmt.Sprintf("%s%d", strings.TrimSuffix(prefix, "/")+"-", p.mapper.Len())
+	if err := p.mapper.Reserve(prefix+key, alias); err != nil {
+		p.Errors++
+	}
+	return alias
+}
+
+// isAlias reports if value was handed out as alias, so already rewritten values are not renamed again
+func (p *Pseudonymizer) isAlias(name string) bool {
+	alias, ok := p.mapper.Lookup(name)
+	return ok && alias == name
+}
+
+func (p *Pseudonymizer) internal(ip net.IP) bool {
+	if len(p.networks) == 0 {
+		return ip.IsPrivate()
+	}
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewPseudonymizer(c PseudoConfig) (*Pseudonymizer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	p := &Pseudonymizer{
+		mapper:   c.Mapper,
+		ips:      c.IPs,
+		networks: c.Networks,
+		mu:       &sync.Mutex{},
+	}
+	// continue address allocation after aliases from previous runs
+	p.nextIP = uint32(c.Mapper.Count(pseudoIP))
+	return p, nil
+}
@@ -0,0 +1,191 @@
+package anonymizer
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/fields"
+	"go-peek/pkg/models/meta"
+	"go-peek/pkg/persist"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	m, err := NewMapper(Config{Persist: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pseudo, err := NewPseudonymizer(PseudoConfig{Mapper: m, IPs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pseudo.Reserve("Gizmo", "ws01", "team01_ws01", "ws01.corp.ex"); err != nil {
+		t.Fatal(err)
+	}
+	if alias := pseudo.Host("WS01.corp.ex"); alias != "Gizmo" {
+		t.Fatalf("inventory alias not used, got %s", alias)
+	}
+	if alias := pseudo.Host("srv02.corp.ex"); alias != pseudo.Host("srv02") || alias == "srv02" {
+		t.Fatalf("FQDN and host label should share alias, got %s", alias)
+	}
+	if alias := pseudo.Host("Gizmo"); alias != "Gizmo" {
+		t.Fatalf("alias should not be renamed again, got %s", alias)
+	}
+	ip := pseudo.IP(net.ParseIP("10.1.2.3"))
+	if !pseudoNet.Contains(ip) || !pseudo.IP(net.ParseIP("10.1.2.3")).Equal(ip) {
+		t.Fatalf("unexpected internal address alias %s", ip)
+	}
+	if ext := pseudo.IP(net.ParseIP("8.8.8.8")); ext.String() != "8.8.8.8" {
+		t.Fatalf("external address should be kept, got %s", ext)
+	}
+
+	event := &events.Snoopy{
+		Snoopy: atomic.Snoopy{
+			Cmd:      "ssh alice@ws01 cat /home/alice/notes.txt",
+			Username: "alice",
+			SSH:      &atomic.SnoopySSH{SrcIP: &fields.StringIP{IP: net.ParseIP("10.1.2.3")}},
+		},
+		Syslog: atomic.Syslog{Host: "ws01.corp.ex", Message: "session opened for alice"},
+	}
+	asset := event.GetAsset()
+	asset.Domain = "corp.ex"
+	asset.EventData = event.DumpEventData()
+	event.SetAsset(asset)
+	if err := event.Anonymize(pseudo); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"alice", "ws01", "corp.ex", "10.1.2.3"} {
+		if strings.Contains(string(encoded), leak) {
+			t.Fatalf("%s leaked in %s", leak, encoded)
+		}
+	}
+	if event.Syslog.Host != "Gizmo" || !strings.Contains(event.Cmd, "/home/"+event.Username+"/") {
+		t.Fatalf("unexpected pseudonymized event %s", encoded)
+	}
+
+	// aliases survive restart and address allocation continues after previous ones
+	m, err = NewMapper(Config{Persist: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewPseudonymizer(PseudoConfig{Mapper: m, IPs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.User("alice") != event.Username || !restored.IP(net.ParseIP("10.1.2.3")).Equal(ip) {
+		t.Fatal("aliases not restored from persist")
+	}
+	if next := restored.IP(net.ParseIP("10.1.2.4")); next.Equal(ip) {
+		t.Fatalf("address alias %s reused", next)
+	}
+	var _ meta.Pseudonyms = restored
+}
+
+func TestPseudonymizeCef(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	m, err := NewMapper(Config{Persist: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pseudo, err := NewPseudonymizer(PseudoConfig{Mapper: m, IPs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cef, err := atomic.ParseCEF(`CEF:0|Cymmetria|MazeRunner|1.0|SMB|SMB connection|10|` +
+		`src=10.1.2.3 dst=10.1.2.4 dvchost=honey01.corp.ex suser=alice msg=alice logged in to honey01`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &events.Cef{
+		Syslog:  atomic.Syslog{Host: "honey01.corp.ex", Message: "honeypot alert"},
+		Cef:     *cef,
+		Profile: events.CefMazeRunner,
+	}
+	asset := event.GetAsset()
+	asset.Destination.Host = "honey01"
+	event.SetAsset(asset)
+
+	var anon events.Anonymizer = event
+	if err := anon.Anonymize(pseudo); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := event.JSONFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"alice", "honey01", "corp.ex", "10.1.2.3", "10.1.2.4"} {
+		if strings.Contains(string(encoded), leak) {
+			t.Fatalf("%s leaked in %s", leak, encoded)
+		}
+	}
+	ext := event.Cef.Extensions
+	if ext["dvchost"] != pseudo.Host("honey01") || ext["suser"] != pseudo.User("alice") {
+		t.Fatalf("unexpected host or user alias %+v", ext)
+	}
+	// src is both standard and profile key, alias must not be aliased again
+	if ext["src"] != pseudo.IP(net.ParseIP("10.1.2.3")).String() {
+		t.Fatalf("unexpected source alias %s", ext["src"])
+	}
+	if event.Sender() != event.GameMeta.Host {
+		t.Fatalf("sender %s does not match asset %s", event.Sender(), event.GameMeta.Host)
+	}
+}
+
+func TestPseudonymizerKeyedIP(t *testing.T) {
+	newPseudo := func() *Pseudonymizer {
+		pseudo, err := NewPseudonymizer(PseudoConfig{Mapper: newTestMapper(t, "exercise"), IPs: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pseudo
+	}
+	staging, prod := newPseudo(), newPseudo()
+	addrs := []string{"10.0.0.1", "10.0.0.2", "172.16.5.5", "192.168.1.10"}
+	// order of appearance must not matter
+	for _, addr := range addrs {
+		staging.IP(net.ParseIP(addr))
+	}
+	for i := len(addrs) - 1; i >= 0; i-- {
+		alias := prod.IP(net.ParseIP(addrs[i]))
+		if expected := staging.IP(net.ParseIP(addrs[i])); !alias.Equal(expected) {
+			t.Fatalf("%s alias mismatch between environments: %s and %s", addrs[i], expected, alias)
+		}
+		if !pseudoNet.Contains(alias) {
+			t.Fatalf("alias %s outside of pseudo network", alias)
+		}
+	}
+
+	// derived address that is taken by another address is probed forward
+	collide := newPseudo()
+	ones, bits := pseudoNet.Mask.Size()
+	offset, _ := collide.keyedIP(pseudoIP+"10.0.0.1", uint32(1<<(bits-ones))-2)
+	if err := collide.mapper.Reserve(pseudoIP+"10.9.9.9", pseudoAddr(offset).String()); err != nil {
+		t.Fatal(err)
+	}
+	alias := collide.IP(net.ParseIP("10.0.0.1"))
+	if alias.Equal(pseudoAddr(offset)) || !pseudoNet.Contains(alias) || collide.Errors != 0 {
+		t.Fatalf("taken address %s should not be reused, got %s", pseudoAddr(offset), alias)
+	}
+}
@@ -0,0 +1,261 @@
+package correlate
+
+/*
+	correlate package groups fast-tracked alerts from enrichment into host-centric incidents
+	incident is keyed by blue team and target asset, and stays open while alerts keep arriving within window
+*/
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"go-peek/pkg/models/meta"
+	"go-peek/pkg/persist"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+const (
+	badgerPrefix       = "incidents"
+	badgerWatermarkKey = "correlate-watermark"
+)
+
+var (
+	ErrMissingPersist = errors.New("missing badgerdb persistance")
+	ErrMissingTarget  = errors.New("unable to resolve incident target asset")
+)
+
+type Config struct {
+	Persist *persist.Badger
+	// Window is how long incident stays open after last alert, defaults to 30 minutes
+	Window time.Duration
+}
+
+func (c *Config) Validate() error {
+	if c.Persist == nil {
+		return ErrMissingPersist
+	}
+	if c.Window <= 0 {
+		c.Window = 30 * time.Minute
+	}
+	return nil
+}
+
+type Counts struct {
+	Alerts     uint
+	NoTarget   uint
+	Opened     uint
+	Updated    uint
+	Closed     uint
+	Open       int
+	PersistErr uint
+}
+
+// Correlator tracks open incidents
+// not safe for concurrent use, meant to be driven from a single consumer loop
+type Correlator struct {
+	Counts
+
+	window    time.Duration
+	incidents map[string]*Incident
+	clock     streamClock
+	persist   *persist.Badger
+}
+
+// Add merges alert into incident of target asset
+// returns open or update record if incident is new or gained new tactics, techniques or sources
+// late alert for expired incident returns close record of old incident before open record of new one
+func (c *Correlator) Add(ts time.Time, asset *meta.GameAsset) ([]Incident, error) {
+	c.Alerts++
+	target, source, ok := Target(asset)
+	if !ok {
+		c.NoTarget++
+		return nil, ErrMissingTarget
+	}
+	if ts.IsZero() {
+		ts = c.clock.now()
+	}
+	c.clock.observe(ts)
+
+	team := targetTeam(target)
+	key := team + "/" + targetKey(target)
+
+	tx := make([]Incident, 0, 2)
+	incident, ok := c.incidents[key]
+	// late alert for incident that would already be closed starts a new one
+	if ok && ts.Sub(incident.Last) > c.window {
+		tx = append(tx, c.close(key))
+		ok = false
+	}
+	if !ok {
+		incident = &Incident{
+			ID:     key + "/" + strconv.FormatInt(ts.Unix(), 10),
+			Team:   team,
+			Target: *target,
+			Start:  ts,
+			Last:   ts,
+		}
+		incident.add(ts, asset, source)
+		c.incidents[key] = incident
+		c.Opened++
+		c.Open = len(c.incidents)
+		c.save(key, incident)
+		return append(tx, incident.Copy(StateOpen, time.Now())), nil
+	}
+	if !incident.add(ts, asset, source) {
+		return tx, nil
+	}
+	c.Updated++
+	c.save(key, incident)
+	return append(tx, incident.Copy(StateUpdate, time.Now())), nil
+}
+
+// Expire closes incidents that have not received alerts within window
+func (c *Correlator) Expire() []Incident {
+	now := c.clock.now()
+	keys := make([]string, 0)
+	for key, incident := range c.incidents {
+		if now.Sub(incident.Last) > c.window {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	tx := make([]Incident, 0, len(keys))
+	for _, key := range keys {
+		tx = append(tx, c.close(key))
+	}
+	return tx
+}
+
+func (c *Correlator) close(key string) Incident {
+	incident := c.incidents[key]
+	delete(c.incidents, key)
+	if err := c.persist.Delete(badgerPrefix, key); err != nil {
+		c.PersistErr++
+	}
+	c.Closed++
+	c.Open = len(c.incidents)
+	return incident.Copy(StateClose, time.Now())
+}
+
+func (c *Correlator) save(key string, incident *Incident) {
+	if err := c.persist.Set(badgerPrefix, persist.GenericValue{Key: key, Data: *incident}); err != nil {
+		c.PersistErr++
+	}
+}
+
+// Persist stores event time watermark, incidents themselves are written on every change
+func (c *Correlator) Persist() error {
+	if c.clock.watermark.IsZero() {
+		return nil
+	}
+	return c.persist.SetSingle(badgerWatermarkKey, c.clock.watermark)
+}
+
+func NewCorrelator(c Config) (*Correlator, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	correlator := &Correlator{
+		window:    c.Window,
+		incidents: make(map[string]*Incident),
+		persist:   c.Persist,
+	}
+	for record := range c.Persist.Scan(badgerPrefix) {
+		var obj Incident
+		buf := bytes.NewBuffer(record.Data)
+		if err := gob.NewDecoder(buf).Decode(&obj); err != nil {
+			return nil, err
+		}
+		correlator.incidents[obj.Team+"/"+targetKey(&obj.Target)] = &obj
+	}
+	correlator.Open = len(correlator.incidents)
+
+	err := c.Persist.GetSingle(badgerWatermarkKey, func(b []byte) error {
+		buf := bytes.NewBuffer(b)
+		return gob.NewDecoder(buf).Decode(&correlator.clock.watermark)
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	correlator.clock.arrival = time.Now()
+
+	return correlator, nil
+}
+
+// Target picks the blue team asset that alert is about, and the other side of connection
+// for inbound and lateral traffic it is destination, for outbound it is the source
+func Target(asset *meta.GameAsset) (*meta.Asset, string, bool) {
+	if asset == nil {
+		return nil, "", false
+	}
+	var target, other *meta.Asset
+	switch asset.Directionality {
+	case meta.DirInbound, meta.DirLateral:
+		target, other = asset.Destination, asset.Source
+	case meta.DirOutbound:
+		target, other = asset.Source, asset.Destination
+	default:
+		for _, candidate := range []*meta.Asset{&asset.Asset, asset.Destination, asset.Source} {
+			if candidate != nil && candidate.IsAsset {
+				target = candidate
+				break
+			}
+		}
+		if target == nil {
+			target = &asset.Asset
+		}
+	}
+	if target == nil || targetKey(target) == "" {
+		return nil, "", false
+	}
+	var source string
+	if other != nil {
+		source = targetKey(other)
+	}
+	return target, source, true
+}
+
+func targetKey(asset *meta.Asset) string {
+	switch {
+	case asset.VM != "":
+		return asset.VM
+	case asset.Host != "":
+		return asset.Host
+	case asset.IP != nil:
+		return asset.IP.String()
+	}
+	return ""
+}
+
+// targetTeam prefers segment team, as inventory only distinguishes blue from other teams
+func targetTeam(asset *meta.Asset) string {
+	if asset.Segment != nil && asset.Segment.Team != "" {
+		return asset.Segment.Team
+	}
+	return asset.Team
+}
+
+// streamClock follows event time, advancing with wall clock between alerts
+// so incidents close during live processing and replayed data is windowed by event time
+type streamClock struct {
+	watermark time.Time
+	arrival   time.Time
+}
+
+func (s *streamClock) observe(ts time.Time) {
+	if ts.After(s.watermark) {
+		s.watermark = ts
+	}
+	s.arrival = time.Now()
+}
+
+func (s streamClock) now() time.Time {
+	if s.watermark.IsZero() {
+		return time.Now()
+	}
+	return s.watermark.Add(time.Since(s.arrival))
+}
@@ -0,0 +1,118 @@
+package correlate
+
+import (
+	"go-peek/pkg/models/meta"
+	"go-peek/pkg/persist"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func alert(ts time.Time, src string, technique meta.Technique) *meta.GameAsset {
+	return &meta.GameAsset{
+		EventType:      "suricata",
+		Directionality: meta.DirInbound,
+		Source:         &meta.Asset{IP: net.ParseIP(src)},
+		Destination: &meta.Asset{
+			Host:       "dc01",
+			Team:       "blue",
+			Segment:    &meta.NetSegment{Team: "BT01"},
+			Indicators: meta.Indicators{IsAsset: true},
+		},
+		MitreAttack: &meta.MitreAttack{Technique: technique, Techniques: []meta.Technique{technique}},
+	}
+}
+
+func TestCorrelator(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	c, err := NewCorrelator(Config{Persist: p, Window: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 4, 19, 8, 0, 0, 0, time.UTC)
+	exec := meta.Technique{ID: "T1059", Phases: []string{"execution"}}
+	access := meta.Technique{ID: "T1190", Phases: []string{"initial-access"}}
+
+	incidents, err := c.Add(start, alert(start, "100.64.0.1", exec))
+	if err != nil || len(incidents) != 1 || incidents[0].State != StateOpen {
+		t.Fatalf("expected open record, got %+v %v", incidents, err)
+	}
+	incident := incidents[0]
+	if incident.Team != "BT01" || incident.Target.Host != "dc01" {
+		t.Fatalf("wrong target %s %s", incident.Team, incident.Target.Host)
+	}
+	if incidents, _ := c.Add(start.Add(time.Minute), alert(start, "100.64.0.1", exec)); len(incidents) != 0 {
+		t.Fatal("repeated alert should not produce update")
+	}
+	incidents, _ = c.Add(start.Add(2*time.Minute), alert(start, "100.64.0.2", access))
+	if len(incidents) != 1 || incidents[0].State != StateUpdate {
+		t.Fatal("new technique should produce update")
+	}
+	incident = incidents[0]
+	if incident.Stage != "execution" || incident.Tactics[0] != "initial-access" || incident.Events != 3 {
+		t.Fatalf("unexpected tactics %v stage %s events %d", incident.Tactics, incident.Stage, incident.Events)
+	}
+
+	// restart keeps open incident
+	c, err = NewCorrelator(Config{Persist: p, Window: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Open != 1 {
+		t.Fatalf("expected 1 restored incident, got %d", c.Open)
+	}
+	c.clock.observe(start.Add(time.Hour))
+	closed := c.Expire()
+	if len(closed) != 1 || closed[0].State != StateClose || len(closed[0].Sources) != 2 {
+		t.Fatalf("expected closed incident with 2 sources, got %+v", closed)
+	}
+}
+
+func TestCorrelatorLateAlert(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	c, err := NewCorrelator(Config{Persist: p, Window: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 4, 19, 8, 0, 0, 0, time.UTC)
+	exec := meta.Technique{ID: "T1059", Phases: []string{"execution"}}
+
+	opened, err := c.Add(start, alert(start, "100.64.0.1", exec))
+	if err != nil || len(opened) != 1 {
+		t.Fatalf("expected open record, got %+v %v", opened, err)
+	}
+	// alert arrives after window, before expiry ticker had a chance to close the incident
+	late := start.Add(time.Hour)
+	incidents, err := c.Add(late, alert(late, "100.64.0.1", exec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incidents) != 2 {
+		t.Fatalf("expected close and open records, got %+v", incidents)
+	}
+	if incidents[0].State != StateClose || incidents[0].ID != opened[0].ID {
+		t.Fatalf("expected close record of %s first, got %s %s", opened[0].ID, incidents[0].State, incidents[0].ID)
+	}
+	if incidents[1].State != StateOpen || incidents[1].ID == opened[0].ID {
+		t.Fatalf("expected open record of new incident, got %s %s", incidents[1].State, incidents[1].ID)
+	}
+	if c.Opened != 2 || c.Closed != 1 || c.Open != 1 {
+		t.Fatalf("unexpected counts %+v", c.Counts)
+	}
+}
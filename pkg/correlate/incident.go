@@ -0,0 +1,156 @@
+package correlate
+
+import (
+	"go-peek/pkg/models/meta"
+	"sort"
+	"time"
+)
+
+// maxIncidentSources limits attacker list so a scan would not grow incident without bounds
+const maxIncidentSources = 64
+
+// Tactics lists enterprise ATT&CK tactic phase names in kill chain order
+var Tactics = []string{
+	"reconnaissance",
+	"resource-development",
+	"initial-access",
+	"execution",
+	"persistence",
+	"privilege-escalation",
+	"defense-evasion",
+	"credential-access",
+	"discovery",
+	"lateral-movement",
+	"collection",
+	"command-and-control",
+	"exfiltration",
+	"impact",
+}
+
+var tacticOrder = func() map[string]int {
+	tx := make(map[string]int, len(Tactics))
+	for i, tactic := range Tactics {
+		tx[tactic] = i
+	}
+	return tx
+}()
+
+// IncidentState marks lifecycle stage of incident record
+type IncidentState string
+
+const (
+	StateOpen   IncidentState = "open"
+	StateUpdate IncidentState = "update"
+	StateClose  IncidentState = "close"
+)
+
+// Incident groups MITRE-tagged alerts that affect a single blue team asset
+type Incident struct {
+	ID    string        `json:"ID"`
+	State IncidentState `json:"State"`
+
+	Team   string     `json:"Team"`
+	Target meta.Asset `json:"Target"`
+
+	Timestamp time.Time `json:"@timestamp"`
+	Start     time.Time `json:"Start"`
+	Last      time.Time `json:"Last"`
+
+	Events     uint             `json:"Events"`
+	EventTypes []string         `json:"EventTypes"`
+	Tactics    []string         `json:"Tactics"`
+	Stage      string           `json:"Stage"`
+	Techniques []meta.Technique `json:"Techniques"`
+	Sources    []string         `json:"Sources"`
+}
+
+// add merges alert into incident and reports if incident gained new info
+func (i *Incident) add(ts time.Time, asset *meta.GameAsset, source string) bool {
+	i.Events++
+	if ts.After(i.Last) {
+		i.Last = ts
+	}
+	var changed bool
+	if asset.EventType != "" && !contains(i.EventTypes, asset.EventType) {
+		i.EventTypes = append(i.EventTypes, asset.EventType)
+		changed = true
+	}
+	if source != "" && len(i.Sources) < maxIncidentSources && !contains(i.Sources, source) {
+		i.Sources = append(i.Sources, source)
+		changed = true
+	}
+	if asset.MitreAttack == nil {
+		return changed
+	}
+	techniques := asset.MitreAttack.Techniques
+	if len(techniques) == 0 && asset.MitreAttack.ID != "" {
+		techniques = []meta.Technique{asset.MitreAttack.Technique}
+	}
+	for _, technique := range techniques {
+		if !i.hasTechnique(technique.ID) {
+			i.Techniques = append(i.Techniques, technique)
+			changed = true
+		}
+		for _, phase := range technique.Phases {
+			if !contains(i.Tactics, phase) {
+				i.Tactics = append(i.Tactics, phase)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		i.sortTactics()
+	}
+	return changed
+}
+
+func (i Incident) hasTechnique(id string) bool {
+	for _, t := range i.Techniques {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortTactics orders reached tactics by kill chain and sets furthest one as stage
+// unknown phases, for example from ICS matrix, are kept at the end
+func (i *Incident) sortTactics() {
+	sort.SliceStable(i.Tactics, func(a, b int) bool {
+		return order(i.Tactics[a]) < order(i.Tactics[b])
+	})
+	i.Stage = ""
+	for _, tactic := range i.Tactics {
+		if _, ok := tacticOrder[tactic]; ok {
+			i.Stage = tactic
+		}
+	}
+}
+
+// Copy returns incident record with given state
+func (i Incident) Copy(state IncidentState, ts time.Time) Incident {
+	cpy := i
+	cpy.State = state
+	cpy.Timestamp = ts
+	cpy.EventTypes = append([]string{}, i.EventTypes...)
+	cpy.Tactics = append([]string{}, i.Tactics...)
+	cpy.Techniques = append([]meta.Technique{}, i.Techniques...)
+	cpy.Sources = append([]string{}, i.Sources...)
+	return cpy
+}
+
+func order(tactic string) int {
+	if idx, ok := tacticOrder[tactic]; ok {
+		return idx
+	}
+	return len(Tactics)
+}
+
+func contains(items []string, item string) bool {
+	for _, val := range items {
+		if val == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package deadletter
+
+/*
+	deadletter package wraps messages that a stage could not handle into envelopes
+	envelopes are sent to dead-letter topic, so they can be inspected and re-injected once the cause is fixed
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"go-peek/pkg/metrics"
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/events"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// reasons classify step where message failed
+const (
+	ReasonUnmapped = "unmapped"
+	ReasonDecode   = "decode"
+	ReasonEnrich   = "enrich"
+	ReasonEncode   = "encode"
+	ReasonParse    = "parse"
+)
+
+// classLength limits error text in class, long errors usually embed payload fragments
+const classLength = 120
+
+var (
+	ErrMissingStage  = errors.New("missing dead-letter stage name")
+	ErrMissingOutput = errors.New("missing dead-letter output channel")
+)
+
+var (
+	classQuoted = regexp.MustCompile(`"[^"]*"|'[^']*'|\x60[^\x60]*\x60`)
+	classNumber = regexp.MustCompile(`0x[0-9a-fA-F]+|\d+`)
+)
+
+// Envelope holds failed message with its origin and cause
+// offset and partition are -1 if origin is not known
+type Envelope struct {
+	Input     string    `json:"input"`
+	Topic     string    `json:"topic,omitempty"`
+	Partition int64     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key,omitempty"`
+	Stage     string    `json:"stage"`
+	Reason    string    `json:"reason"`
+	Kind      string    `json:"kind,omitempty"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"@timestamp"`
+	Payload   []byte    `json:"payload"`
+}
+
+// Class groups envelopes that failed for the same cause
+// numbers and quoted values are masked, so errors that embed them still end up in one class
+func (e Envelope) Class() string {
+	msg := classQuoted.ReplaceAllString(e.Error, "*")
+	msg = classNumber.ReplaceAllString(msg, "N")
+	if runes := []rune(msg); len(runes) > classLength {
+		msg = string(runes[:classLength]) + "..."
+	}
+	return e.Stage + "/" + e.Reason + ": " + msg
+}
+
+// NewEnvelope wraps message that failed in stage
+func NewEnvelope(msg consumer.Message, stage, reason string, err error) Envelope {
+	e := Envelope{
+		Input:     msg.Type.String(),
+		Topic:     msg.Source,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Stage:     stage,
+		Reason:    reason,
+		Timestamp: time.Now().UTC(),
+		Payload:   msg.Data,
+	}
+	if msg.Event != events.SimpleE {
+		e.Kind = msg.Event.String()
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// Filter selects envelopes, empty fields match anything
+type Filter struct {
+	Stage  string
+	Reason string
+	// Error is substring of error text
+	Error string
+	Since time.Time
+}
+
+func (f Filter) Match(e Envelope) bool {
+	if f.Stage != "" && f.Stage != e.Stage {
+		return false
+	}
+	if f.Reason != "" && f.Reason != e.Reason {
+		return false
+	}
+	if f.Error != "" && !strings.Contains(e.Error, f.Error) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// Config is used as parameter when instanciating new Writer
+type Config struct {
+	// Topic is dead-letter topic, writer is disabled if empty
+	Topic string
+	// Stage is name of command that failed to handle message
+	Stage string
+	// Output is fed to kafka producer
+	Output chan<- consumer.Message
+}
+
+func (c Config) Validate() error {
+	if c.Topic == "" {
+		return nil
+	}
+	if c.Stage == "" {
+		return ErrMissingStage
+	}
+	if c.Output == nil {
+		return ErrMissingOutput
+	}
+	return nil
+}
+
+// Writer sends envelopes of failed messages to dead-letter topic
+// nil or disabled Writer drops everything, so callers only need to check return value
+type Writer struct {
+	topic string
+	stage string
+	tx    chan<- consumer.Message
+}
+
+// Enabled reports if dead-letter topic is configured
+func (w *Writer) Enabled() bool {
+	return w != nil && w.topic != ""
+}
+
+// Send wraps message in envelope and passes it to producer
+// envelope holds input delivery handle, so input is committed only once envelope is delivered
+// returns false if writer is disabled
+func (w *Writer) Send(msg consumer.Message, reason string, err error) bool {
+	if !w.Enabled() {
+		return false
+	}
+	encoded, encErr := json.Marshal(NewEnvelope(msg, w.stage, reason, err))
+	if encErr != nil {
+		return false
+	}
+	w.tx <- consumer.Message{
+		Data:   encoded,
+		Time:   time.Now(),
+		Key:    reason,
+		Event:  msg.Event,
+		Source: w.stage,
+		Topic:  w.topic,
+		Ack:    msg.Ack.Hold(),
+	}
+	metrics.DeadLetters.WithLabelValues(w.stage, reason).Inc()
+	return true
+}
+
+func NewWriter(c Config) (*Writer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &Writer{topic: c.Topic, stage: c.Stage, tx: c.Output}, nil
+}
@@ -0,0 +1,130 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/events"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestWriterSummary(t *testing.T) {
+	tx := make(chan consumer.Message, 2)
+	w, err := NewWriter(Config{Topic: "peek-dlq", Stage: "enrich", Output: tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var acked bool
+	ack := consumer.NewAck(func() { acked = true })
+	msg := consumer.Message{
+		Data:      []byte(`{"broken`),
+		Source:    "winlogbeat",
+		Partition: 2,
+		Offset:    77,
+		Type:      consumer.Kafka,
+		Event:     events.EventLogE,
+		Ack:       ack,
+	}
+	if !w.Send(msg, ReasonDecode, errors.New(`unexpected end at offset 8 near "broken"`)) {
+		t.Fatal("enabled writer should send envelope")
+	}
+	if (*Writer)(nil).Send(msg, ReasonDecode, nil) {
+		t.Fatal("nil writer should not send envelope")
+	}
+	ack.Done()
+	if acked {
+		t.Fatal("input should stay pending until envelope is delivered")
+	}
+
+	out := <-tx
+	if out.Topic != "peek-dlq" {
+		t.Fatalf("envelope routed to %s", out.Topic)
+	}
+	out.Ack.Done()
+	if !acked {
+		t.Fatal("input should be released once envelope is delivered")
+	}
+	var e Envelope
+	if err := json.Unmarshal(out.Data, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Topic != "winlogbeat" || e.Partition != 2 || e.Offset != 77 || e.Input != "kafka" ||
+		e.Kind != "windows" || string(e.Payload) != `{"broken` {
+		t.Fatalf("envelope origin not kept: %+v", e)
+	}
+
+	other := e
+	other.Error = `unexpected end at offset 12 near "other"`
+	summary := make(Summary)
+	summary.Add(e)
+	summary.Add(other)
+	classes := summary.Sorted()
+	if len(classes) != 1 || classes[0].Count != 2 {
+		t.Fatalf("errors differing by values should share class: %+v", classes)
+	}
+	if expected := "enrich/decode: unexpected end at offset N near *"; classes[0].Class != expected {
+		t.Fatalf("expected class %s, got %s", expected, classes[0].Class)
+	}
+}
+
+func TestReadInject(t *testing.T) {
+	envelopes := []Envelope{
+		{Input: "kafka", Topic: "winlogbeat", Key: "host", Stage: "enrich", Reason: ReasonDecode, Payload: []byte("a")},
+		{Input: "syslog", Stage: "preprocess", Reason: ReasonParse, Payload: []byte("b")},
+	}
+	fetch := sarama.NewMockFetchResponse(t, 1)
+	for i, e := range envelopes {
+		encoded, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetch.SetMessage("peek-dlq", 0, int64(i), sarama.ByteEncoder(encoded))
+	}
+	fetch.SetMessage("peek-dlq", 0, 2, sarama.StringEncoder("garbage"))
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("peek-dlq", 0, broker.BrokerID()).
+			SetLeader("winlogbeat", 0, broker.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("peek-dlq", 0, sarama.OffsetOldest, 0).
+			SetOffset("peek-dlq", 0, sarama.OffsetNewest, 3),
+		"FetchRequest":       fetch,
+		"ProduceRequest":     sarama.NewMockProduceResponse(t).SetVersion(3),
+		"ApiVersionsRequest": sarama.NewMockApiVersionsResponse(t),
+	})
+
+	records := make([]Record, 0)
+	err := Read(context.Background(), ReadConfig{
+		Brokers: []string{broker.Addr()},
+		Topic:   "peek-dlq",
+	}, func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[2].Err == nil || records[0].Envelope.Topic != "winlogbeat" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+
+	injector, err := NewInjector(InjectConfig{Brokers: []string{broker.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer injector.Close()
+	if err := injector.Inject(records[0].Envelope); err != nil {
+		t.Fatal(err)
+	}
+	var unknown ErrUnknownOrigin
+	if err := injector.Inject(records[1].Envelope); !errors.As(err, &unknown) {
+		t.Fatalf("syslog envelope without target should fail, got %v", err)
+	}
+}
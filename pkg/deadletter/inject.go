@@ -0,0 +1,93 @@
+package deadletter
+
+import (
+	"fmt"
+	"go-peek/pkg/kafkaauth"
+
+	"github.com/Shopify/sarama"
+)
+
+type ErrUnknownOrigin struct {
+	Envelope Envelope
+}
+
+func (e ErrUnknownOrigin) Error() string {
+	return fmt.Sprintf(
+		"original topic of %s/%s envelope from %s input is unknown, target topic must be set",
+		e.Envelope.Stage,
+		e.Envelope.Reason,
+		e.Envelope.Input,
+	)
+}
+
+// InjectConfig is used as parameter when instanciating new Injector
+type InjectConfig struct {
+	Brokers  []string
+	Security kafkaauth.Config
+	// Target overrides original topic of envelopes
+	Target string
+}
+
+func (c InjectConfig) Validate() error {
+	if len(c.Brokers) == 0 {
+		return ErrMissingBrokers
+	}
+	return c.Security.Validate()
+}
+
+// Injector writes original payloads of envelopes back to kafka
+// synchronous producer is used, so every returned nil error means broker has the message
+type Injector struct {
+	producer sarama.SyncProducer
+	target   string
+}
+
+// Topic returns topic where envelope payload would be written
+func (i Injector) Topic(e Envelope) (string, error) {
+	if i.target != "" {
+		return i.target, nil
+	}
+	// syslog server input has no source topic to go back to
+	if e.Input != "kafka" || e.Topic == "" {
+		return "", ErrUnknownOrigin{Envelope: e}
+	}
+	return e.Topic, nil
+}
+
+// Inject writes envelope payload with original key to original or target topic
+func (i Injector) Inject(e Envelope) error {
+	topic, err := i.Topic(e)
+	if err != nil {
+		return err
+	}
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(e.Payload),
+	}
+	if e.Key != "" {
+		msg.Key = sarama.StringEncoder(e.Key)
+	}
+	_, _, err = i.producer.SendMessage(msg)
+	return err
+}
+
+func (i Injector) Close() error {
+	return i.producer.Close()
+}
+
+func NewInjector(c InjectConfig) (*Injector, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	config, err := newSaramaConfig(c.Security)
+	if err != nil {
+		return nil, err
+	}
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(c.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Injector{producer: producer, target: c.Target}, nil
+}
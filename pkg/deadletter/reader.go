@@ -0,0 +1,135 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-peek/pkg/ingest/kafka"
+	"go-peek/pkg/kafkaauth"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	ErrMissingBrokers = errors.New("missing kafka brokers")
+	ErrMissingTopic   = errors.New("missing dead-letter topic")
+)
+
+// Record is envelope with its position in dead-letter topic
+// Err is set if message is not a valid envelope
+type Record struct {
+	Partition int32
+	Offset    int64
+	Envelope  Envelope
+	Err       error
+}
+
+// ReadConfig is used as parameter for Read
+type ReadConfig struct {
+	Brokers  []string
+	Topic    string
+	Security kafkaauth.Config
+}
+
+func (c ReadConfig) Validate() error {
+	if len(c.Brokers) == 0 {
+		return ErrMissingBrokers
+	}
+	if c.Topic == "" {
+		return ErrMissingTopic
+	}
+	return c.Security.Validate()
+}
+
+// Read passes every record in dead-letter topic to fn, from oldest to newest offset at time of call
+// no consumer group is used, so reading does not affect offsets of any stage
+// stops on first error from fn or when context is cancelled
+func Read(ctx context.Context, c ReadConfig, fn func(Record) error) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	config, err := newSaramaConfig(c.Security)
+	if err != nil {
+		return err
+	}
+	client, err := sarama.NewClient(c.Brokers, config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(c.Topic)
+	if err != nil {
+		return err
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return err
+	}
+	defer consumer.Close()
+
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(c.Topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return err
+		}
+		newest, err := client.GetOffset(c.Topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return err
+		}
+		if newest <= oldest {
+			continue
+		}
+		if err := readPartition(ctx, consumer, c.Topic, partition, oldest, newest, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPartition(
+	ctx context.Context,
+	consumer sarama.Consumer,
+	topic string,
+	partition int32,
+	oldest, newest int64,
+	fn func(Record) error,
+) error {
+	pc, err := consumer.ConsumePartition(topic, partition, oldest)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+	for {
+		select {
+		case msg := <-pc.Messages():
+			record := Record{Partition: msg.Partition, Offset: msg.Offset}
+			record.Err = json.Unmarshal(msg.Value, &record.Envelope)
+			if err := fn(record); err != nil {
+				return err
+			}
+			// newest is offset of next message to be written
+			if msg.Offset >= newest-1 {
+				return nil
+			}
+		case err := <-pc.Errors():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func newSaramaConfig(security kafkaauth.Config) (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	version, err := sarama.ParseKafkaVersion(kafka.Version)
+	if err != nil {
+		return nil, err
+	}
+	config.Version = version
+	config.Consumer.Return.Errors = true
+	if err := security.Apply(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,103 @@
+package deadletter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClassSummary holds stats of envelopes in one error class
+type ClassSummary struct {
+	Class  string
+	Count  int
+	First  time.Time
+	Last   time.Time
+	Topics map[string]int
+	Kinds  map[string]int
+}
+
+// Summary counts envelopes per error class
+type Summary map[string]*ClassSummary
+
+func (s Summary) Add(e Envelope) {
+	class := e.Class()
+	item, ok := s[class]
+	if !ok {
+		item = &ClassSummary{
+			Class:  class,
+			First:  e.Timestamp,
+			Last:   e.Timestamp,
+			Topics: make(map[string]int),
+			Kinds:  make(map[string]int),
+		}
+		s[class] = item
+	}
+	item.Count++
+	if e.Timestamp.Before(item.First) {
+		item.First = e.Timestamp
+	}
+	if e.Timestamp.After(item.Last) {
+		item.Last = e.Timestamp
+	}
+	if e.Topic != "" {
+		item.Topics[e.Topic]++
+	}
+	if e.Kind != "" {
+		item.Kinds[e.Kind]++
+	}
+}
+
+// Sorted returns classes with most envelopes first
+func (s Summary) Sorted() []ClassSummary {
+	tx := make([]ClassSummary, 0, len(s))
+	for _, item := range s {
+		tx = append(tx, *item)
+	}
+	sort.Slice(tx, func(i, j int) bool {
+		if tx[i].Count != tx[j].Count {
+			return tx[i].Count > tx[j].Count
+		}
+		return tx[i].Class < tx[j].Class
+	})
+	return tx
+}
+
+// Keys is for implementing CSV header
+func (s Summary) Keys() []string {
+	return []string{"count", "first", "last", "topics", "kinds", "class"}
+}
+
+// CSVFormat implements atomic.CSVFormatter
+func (s Summary) CSVFormat(header bool) [][]string {
+	tx := make([][]string, 0, len(s)+1)
+	if header {
+		tx = append(tx, s.Keys())
+	}
+	for _, item := range s.Sorted() {
+		tx = append(tx, []string{
+			strconv.Itoa(item.Count),
+			item.First.Format(time.RFC3339),
+			item.Last.Format(time.RFC3339),
+			joinCounts(item.Topics),
+			joinCounts(item.Kinds),
+			item.Class,
+		})
+	}
+	return tx
+}
+
+func joinCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + strconv.Itoa(counts[key])
+	}
+	return strings.Join(keys, ",")
+}
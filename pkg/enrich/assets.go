@@ -0,0 +1,133 @@
+package enrich
+
+import (
+	"go-peek/pkg/providentia"
+	"time"
+)
+
+// assetID is the stable identity of an inventory record
+// addresses and aliases may change between updates, VM name should not
+func assetID(r providentia.Record) string {
+	switch {
+	case r.AnsibleName != "":
+		return r.AnsibleName
+	case r.HostName != "":
+		return r.HostName
+	case r.Pretty != "":
+		return r.Pretty
+	case r.Addr != nil:
+		return r.Addr.String()
+	}
+	return ""
+}
+
+// assetCache indexes inventory records by every lookup key
+// each key belongs to a single record, newest record wins if keys collide
+// not safe for concurrent use, handler guards it with assetsMu
+type assetCache struct {
+	records map[string]providentia.Record
+	index   map[string]string
+}
+
+func (c assetCache) get(key string) (providentia.Record, bool) {
+	id, ok := c.index[key]
+	if !ok {
+		return providentia.Record{}, false
+	}
+	val, ok := c.records[id]
+	return val, ok
+}
+
+// keys returns lookup keys currently owned by record
+func (c assetCache) keys(id string) []string {
+	r, ok := c.records[id]
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(r.Keys()))
+	for _, key := range r.Keys() {
+		if c.index[key] == id {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// put stores record if it is not older than current version
+// returns keys now owned by record and keys that should be dropped
+func (c *assetCache) put(r providentia.Record) (set, stale []string, ok bool) {
+	id := assetID(r)
+	if id == "" {
+		return nil, nil, false
+	}
+	current, exists := c.records[id]
+	if exists && r.Updated.Before(current.Updated) {
+		return nil, nil, false
+	}
+	c.records[id] = r
+
+	keys := make(map[string]bool)
+	for _, key := range r.Keys() {
+		keys[key] = true
+		if owner, taken := c.index[key]; taken && owner != id {
+			if other, ok := c.records[owner]; ok && other.Updated.After(r.Updated) {
+				continue
+			}
+		}
+		c.index[key] = id
+		set = append(set, key)
+	}
+	if exists {
+		for _, key := range current.Keys() {
+			if !keys[key] && c.index[key] == id {
+				delete(c.index, key)
+				stale = append(stale, key)
+			}
+		}
+	}
+	return set, stale, true
+}
+
+// remove drops the record that owns key, key can also be record ID
+// returns ID of dropped record and its lookup keys
+func (c *assetCache) remove(key string) (string, []string) {
+	id, ok := c.index[key]
+	if !ok {
+		id = key
+	}
+	return id, c.drop(id)
+}
+
+func (c *assetCache) drop(id string) []string {
+	if _, ok := c.records[id]; !ok {
+		return nil
+	}
+	keys := c.keys(id)
+	for _, key := range keys {
+		delete(c.index, key)
+	}
+	delete(c.records, id)
+	return keys
+}
+
+// expire drops records that were last updated before deadline
+// records without timestamp never expire
+func (c *assetCache) expire(deadline time.Time) (removed []string, expired int) {
+	for id, r := range c.records {
+		if r.Updated.IsZero() || !r.Updated.Before(deadline) {
+			continue
+		}
+		removed = append(removed, c.drop(id)...)
+		expired++
+	}
+	return removed, expired
+}
+
+func (c assetCache) len() int { return len(c.index) }
+
+func newAssetCache() *assetCache {
+	return &assetCache{
+		records: make(map[string]providentia.Record),
+		index:   make(map[string]string),
+	}
+}
@@ -0,0 +1,112 @@
+package enrich
+
+import (
+	"go-peek/pkg/providentia"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAssetCache(t *testing.T) {
+	now := time.Now()
+	cache := newAssetCache()
+
+	ws := providentia.Record{AnsibleName: "ws-01", HostName: "ws01", Addr: net.ParseIP("10.0.0.10"), Updated: now}
+	if _, _, ok := cache.put(ws); !ok {
+		t.Fatal("initial record not stored")
+	}
+
+	// older version must not override current record
+	old := ws
+	old.Addr = net.ParseIP("10.0.0.99")
+	old.Updated = now.Add(-time.Hour)
+	if _, _, ok := cache.put(old); ok {
+		t.Fatal("older record should be ignored")
+	}
+
+	// address change drops the old IP key
+	moved := ws
+	moved.Addr = net.ParseIP("10.0.0.11")
+	moved.Updated = now.Add(time.Minute)
+	_, stale, ok := cache.put(moved)
+	if !ok || len(stale) != 1 || stale[0] != "10.0.0.10" {
+		t.Fatalf("expected old address to be stale, got %v", stale)
+	}
+	if _, ok := cache.get("10.0.0.10"); ok {
+		t.Fatal("stale address still resolves")
+	}
+
+	// newer host takes over the address
+	srv := providentia.Record{AnsibleName: "srv-01", Addr: net.ParseIP("10.0.0.11"), Updated: now.Add(2 * time.Minute)}
+	cache.put(srv)
+	if val, _ := cache.get("10.0.0.11"); val.AnsibleName != "srv-01" {
+		t.Fatalf("address should belong to srv-01, got %s", val.AnsibleName)
+	}
+
+	// tombstone is keyed by address, only the owning record is dropped
+	if _, removed := cache.remove("10.0.0.11"); len(removed) != 2 {
+		t.Fatalf("expected 2 removed keys, got %v", removed)
+	}
+	if _, ok := cache.get("ws01"); !ok {
+		t.Fatal("unrelated record was removed")
+	}
+
+	if _, expired := cache.expire(now.Add(time.Hour)); expired != 1 || cache.len() != 0 {
+		t.Fatalf("expected all records to expire, got %d with %d keys left", expired, cache.len())
+	}
+}
+
+func TestAssetHistory(t *testing.T) {
+	start := time.Date(2022, 4, 19, 8, 0, 0, 0, time.UTC)
+	history := newAssetHistory()
+
+	history.add(providentia.Record{AnsibleName: "ws-01", Team: "blue01", Addr: net.ParseIP("10.0.0.10"), Updated: start})
+	history.add(providentia.Record{AnsibleName: "ws-01", Team: "blue01", Addr: net.ParseIP("10.0.0.10"), Updated: start.Add(time.Hour)})
+	history.add(providentia.Record{AnsibleName: "ws-02", Team: "blue02", Addr: net.ParseIP("10.0.0.10"), Updated: start.Add(3 * time.Hour)})
+	// replayed out of order
+	history.add(providentia.Record{AnsibleName: "ws-01", Team: "blue01", Addr: net.ParseIP("10.0.0.20"), Updated: start.Add(2 * time.Hour)})
+
+	if versions := history.versions["ws-01"]; len(versions) != 2 {
+		t.Fatalf("repeated record should extend version, got %d versions", len(versions))
+	}
+	for _, tc := range []struct {
+		ts   time.Time
+		ttl  time.Duration
+		team string
+	}{
+		{ts: start.Add(-time.Hour), team: "blue01"},
+		{ts: start.Add(90 * time.Minute), team: "blue01"},
+		{ts: start.Add(150 * time.Minute), team: ""},
+		{ts: start.Add(4 * time.Hour), team: "blue02"},
+		{ts: start.Add(90 * time.Minute), ttl: 10 * time.Minute, team: ""},
+	} {
+		val, ok := history.at("10.0.0.10", tc.ts, tc.ttl)
+		if !ok && tc.team != "" {
+			t.Fatalf("%s should resolve to %s", tc.ts, tc.team)
+		}
+		if val.Team != tc.team {
+			t.Fatalf("%s resolved to %s, expected %s", tc.ts, val.Team, tc.team)
+		}
+	}
+
+	// different record with the same timestamp replaces version instead of shadowing it under the same key
+	history.add(providentia.Record{AnsibleName: "ws-02", Team: "blue02", Addr: net.ParseIP("10.0.0.30"), Updated: start.Add(3 * time.Hour)})
+	if versions := history.versions["ws-02"]; len(versions) != 1 {
+		t.Fatalf("equal timestamp should replace version, got %+v", versions)
+	}
+	if val, ok := history.at("10.0.0.30", start.Add(4*time.Hour), 0); !ok || val.Team != "blue02" {
+		t.Fatalf("replaced version should resolve, got %+v", val)
+	}
+	if _, ok := history.at("10.0.0.10", start.Add(4*time.Hour), 0); ok {
+		t.Fatal("replaced version should not resolve old address")
+	}
+
+	// tombstone applies from its own time, not from when it was processed
+	history.remove("ws-02", start.Add(5*time.Hour))
+	if _, ok := history.at("10.0.0.30", start.Add(4*time.Hour), 0); !ok {
+		t.Fatal("asset should resolve before tombstone")
+	}
+	if _, ok := history.at("10.0.0.30", start.Add(6*time.Hour), 0); ok {
+		t.Fatal("asset should not resolve after tombstone")
+	}
+}
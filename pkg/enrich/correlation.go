@@ -0,0 +1,467 @@
+package enrich
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxCorrelationHits bounds state of a single group, oldest hits are dropped first
+const maxCorrelationHits = 1024
+
+// CorrelationType follows sigma correlation rule specification
+type CorrelationType string
+
+const (
+	CorrelationEventCount      CorrelationType = "event_count"
+	CorrelationValueCount      CorrelationType = "value_count"
+	CorrelationTemporal        CorrelationType = "temporal"
+	CorrelationTemporalOrdered CorrelationType = "temporal_ordered"
+)
+
+var ErrCorrelationNoRules = errors.New("correlation does not reference any rules")
+
+type ErrCorrelationRule struct {
+	Path   string
+	Title  string
+	Reason string
+}
+
+func (e ErrCorrelationRule) Error() string {
+	return fmt.Sprintf("invalid sigma correlation rule %s in %s: %s", e.Title, e.Path, e.Reason)
+}
+
+// CorrelationCondition is a threshold for count based correlations
+// only lower bounds are supported, as upper bounds can only be decided once window closes
+type CorrelationCondition struct {
+	GT    *int   `yaml:"gt"`
+	GTE   *int   `yaml:"gte"`
+	EQ    *int   `yaml:"eq"`
+	Field string `yaml:"field"`
+}
+
+func (c CorrelationCondition) match(count int) bool {
+	switch {
+	case c.GT != nil:
+		return count > *c.GT
+	case c.GTE != nil:
+		return count >= *c.GTE
+	case c.EQ != nil:
+		return count == *c.EQ
+	}
+	return false
+}
+
+// CorrelationRule aggregates matches of regular sigma rules
+type CorrelationRule struct {
+	ID          string   `yaml:"id"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Level       string   `yaml:"level"`
+	Tags        []string `yaml:"tags"`
+
+	Correlation struct {
+		Type      CorrelationType      `yaml:"type"`
+		Rules     fieldPaths           `yaml:"rules"`
+		GroupBy   fieldPaths           `yaml:"group-by"`
+		Timespan  string               `yaml:"timespan"`
+		Condition CorrelationCondition `yaml:"condition"`
+	} `yaml:"correlation"`
+
+	timespan time.Duration
+}
+
+func (r *CorrelationRule) validate() error {
+	if len(r.Correlation.Rules) == 0 {
+		return ErrCorrelationNoRules
+	}
+	timespan, err := parseTimespan(r.Correlation.Timespan)
+	if err != nil {
+		return err
+	}
+	r.timespan = timespan
+	cond := r.Correlation.Condition
+	switch r.Correlation.Type {
+	case CorrelationEventCount, CorrelationValueCount:
+		if cond.GT == nil && cond.GTE == nil && cond.EQ == nil {
+			return errors.New("count correlation needs gt, gte or eq condition")
+		}
+		if r.Correlation.Type == CorrelationValueCount && cond.Field == "" {
+			return errors.New("value_count correlation needs condition field")
+		}
+	case CorrelationTemporal, CorrelationTemporalOrdered:
+	default:
+		return fmt.Errorf("unsupported correlation type %s", r.Correlation.Type)
+	}
+	if r.ID == "" {
+		r.ID = r.Title
+	}
+	return nil
+}
+
+func (r CorrelationRule) references(result string) bool {
+	for _, ref := range r.Correlation.Rules {
+		if ref == result {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimespan accepts go durations and sigma day suffix
+func parseTimespan(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, errors.New("missing timespan")
+	}
+	if strings.HasSuffix(raw, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// LoadCorrelationRules reads sigma correlation rules from files or directories
+// YAML documents without correlation section are skipped, so regular rule directories can be reused
+func LoadCorrelationRules(paths ...string) ([]*CorrelationRule, error) {
+	rules := make([]*CorrelationRule, 0)
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if ext := strings.ToLower(filepath.Ext(path)); ext != ".yml" && ext != ".yaml" {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			parsed, err := parseCorrelationRules(data, path)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, parsed...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func parseCorrelationRules(data []byte, path string) ([]*CorrelationRule, error) {
+	rules := make([]*CorrelationRule, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var rule CorrelationRule
+		err := decoder.Decode(&rule)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rule.Correlation.Type == "" {
+			continue
+		}
+		if err := rule.validate(); err != nil {
+			return nil, ErrCorrelationRule{Path: path, Title: rule.Title, Reason: err.Error()}
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// CorrelationAlert is a synthetic alert that fires when correlation rule threshold is reached
+type CorrelationAlert struct {
+	Timestamp time.Time `json:"@timestamp"`
+	EventType string    `json:"EventType"`
+	Kind      string    `json:"Kind"`
+
+	RuleID    string          `json:"RuleID"`
+	RuleTitle string          `json:"RuleTitle"`
+	RuleType  CorrelationType `json:"RuleType"`
+	Level     string          `json:"Level"`
+	Tags      []string        `json:"Tags"`
+
+	GroupBy map[string]string `json:"GroupBy,omitempty"`
+	Count   int               `json:"Count"`
+	Events  []string          `json:"Events"`
+
+	// GameMeta is copied from last contributing event, with MITRE info from rule and all events
+	GameMeta *meta.GameAsset `json:"GameMeta"`
+}
+
+type correlationHit struct {
+	ts         time.Time
+	id         string
+	rule       string
+	value      string
+	techniques []meta.Technique
+}
+
+type correlationGroup struct {
+	hits []correlationHit
+	last time.Time
+}
+
+// prune drops hits that fell out of window ending at ts
+func (g *correlationGroup) prune(ts time.Time, window time.Duration) {
+	i := 0
+	for i < len(g.hits) && ts.Sub(g.hits[i].ts) > window {
+		i++
+	}
+	g.hits = g.hits[i:]
+	if len(g.hits) > maxCorrelationHits {
+		g.hits = g.hits[len(g.hits)-maxCorrelationHits:]
+	}
+}
+
+// insert keeps hits sorted by time, as workers may deliver events slightly out of order
+func (g *correlationGroup) insert(hit correlationHit) {
+	i := sort.Search(len(g.hits), func(i int) bool { return g.hits[i].ts.After(hit.ts) })
+	g.hits = append(g.hits, correlationHit{})
+	copy(g.hits[i+1:], g.hits[i:])
+	g.hits[i] = hit
+	if hit.ts.After(g.last) {
+		g.last = hit.ts
+	}
+}
+
+// fired returns hits that satisfy correlation
+func (g correlationGroup) fired(rule *CorrelationRule) ([]correlationHit, bool) {
+	switch rule.Correlation.Type {
+	case CorrelationEventCount:
+		return g.hits, rule.Correlation.Condition.match(len(g.hits))
+	case CorrelationValueCount:
+		values := make(map[string]bool)
+		for _, hit := range g.hits {
+			if hit.value != "" {
+				values[hit.value] = true
+			}
+		}
+		return g.hits, rule.Correlation.Condition.match(len(values))
+	case CorrelationTemporal:
+		seen := make(map[string]bool)
+		for _, hit := range g.hits {
+			seen[hit.rule] = true
+		}
+		for _, ref := range rule.Correlation.Rules {
+			if !seen[ref] {
+				return nil, false
+			}
+		}
+		return g.hits, true
+	case CorrelationTemporalOrdered:
+		tx := make([]correlationHit, 0, len(rule.Correlation.Rules))
+		next := 0
+		for _, hit := range g.hits {
+			if hit.rule == rule.Correlation.Rules[next] {
+				tx = append(tx, hit)
+				next++
+				if next == len(rule.Correlation.Rules) {
+					return tx, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// SigmaCorrelator aggregates per-event sigma matches into correlation alerts
+// state is kept per event kind, correlation rule and group-by values
+// safe for concurrent use
+type SigmaCorrelator struct {
+	rules  []*CorrelationRule
+	mu     *sync.Mutex
+	groups map[string]*correlationGroup
+	// latest is newest observed event time, used instead of wall clock so replayed data expires correctly
+	latest time.Time
+}
+
+// Observe records sigma matches of a single event and returns alerts for correlations that fired
+// id identifies the event in alert, for example kafka topic, partition and offset
+// events without timestamp are skipped, as they can not be placed in correlation timespan
+func (s *SigmaCorrelator) Observe(
+	kind events.Atomic,
+	event events.GameEvent,
+	asset *meta.GameAsset,
+	fields FieldMap,
+	id string,
+) []CorrelationAlert {
+	if asset == nil || len(asset.SigmaResults) == 0 {
+		return nil
+	}
+	ts := event.Time()
+	if ts.IsZero() {
+		return nil
+	}
+	mapped := mappedEvent{GameEvent: event, fields: fields}
+
+	var techniques []meta.Technique
+	if asset.MitreAttack != nil {
+		techniques = asset.MitreAttack.Techniques
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts.After(s.latest) {
+		s.latest = ts
+	}
+
+	var tx []CorrelationAlert
+	for _, rule := range s.rules {
+		for _, result := range asset.SigmaResults {
+			ref := result.ID
+			if !rule.references(ref) {
+				if ref = result.Title; !rule.references(ref) {
+					continue
+				}
+			}
+			groupBy := make(map[string]string, len(rule.Correlation.GroupBy))
+			keys := []string{kind.String(), rule.ID}
+			for _, field := range rule.Correlation.GroupBy {
+				val := selectString(mapped, field)
+				groupBy[field] = val
+				keys = append(keys, val)
+			}
+			key := strings.Join(keys, "\x00")
+
+			group, ok := s.groups[key]
+			if !ok {
+				group = &correlationGroup{}
+				s.groups[key] = group
+			}
+			group.insert(correlationHit{
+				ts:         ts,
+				id:         id,
+				rule:       ref,
+				value:      selectString(mapped, rule.Correlation.Condition.Field),
+				techniques: techniques,
+			})
+			group.prune(group.last, rule.timespan)
+
+			if hits, ok := group.fired(rule); ok {
+				tx = append(tx, newCorrelationAlert(rule, kind, asset, groupBy, hits))
+				delete(s.groups, key)
+			}
+			// single event contributes to a correlation only once
+			break
+		}
+	}
+	return tx
+}
+
+// Expire drops groups that have not seen matches within longest rule timespan of newest event
+func (s *SigmaCorrelator) Expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.latest
+	var longest time.Duration
+	for _, rule := range s.rules {
+		if rule.timespan > longest {
+			longest = rule.timespan
+		}
+	}
+	var count int
+	for key, group := range s.groups {
+		if now.Sub(group.last) > longest {
+			delete(s.groups, key)
+			count++
+		}
+	}
+	return count
+}
+
+// Len returns number of active correlation groups
+func (s *SigmaCorrelator) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.groups)
+}
+
+func NewSigmaCorrelator(rules []*CorrelationRule) *SigmaCorrelator {
+	return &SigmaCorrelator{
+		rules:  rules,
+		mu:     &sync.Mutex{},
+		groups: make(map[string]*correlationGroup),
+	}
+}
+
+func newCorrelationAlert(
+	rule *CorrelationRule,
+	kind events.Atomic,
+	asset *meta.GameAsset,
+	groupBy map[string]string,
+	hits []correlationHit,
+) CorrelationAlert {
+	alert := CorrelationAlert{
+		Timestamp: hits[len(hits)-1].ts,
+		EventType: "sigma correlation",
+		Kind:      kind.String(),
+		RuleID:    rule.ID,
+		RuleTitle: rule.Title,
+		RuleType:  rule.Correlation.Type,
+		Level:     rule.Level,
+		Tags:      rule.Tags,
+		GroupBy:   groupBy,
+		Count:     len(hits),
+		Events:    make([]string, 0, len(hits)),
+	}
+	if len(groupBy) == 0 {
+		alert.GroupBy = nil
+	}
+	techniques := make([]meta.Technique, 0)
+	for _, hit := range hits {
+		if hit.id != "" {
+			alert.Events = append(alert.Events, hit.id)
+		}
+		techniques = append(techniques, hit.techniques...)
+	}
+	cpy := *asset
+	cpy.EventType = alert.EventType
+	cpy.SigmaResults = nil
+	cpy.EventData = &meta.EventData{Key: rule.ID, Fields: []string{rule.Title}}
+	if len(techniques) > 0 {
+		cpy.MitreAttack = &meta.MitreAttack{Techniques: techniques}
+	} else {
+		cpy.MitreAttack = nil
+	}
+	alert.GameMeta = &cpy
+	return alert
+}
+
+func selectString(event mappedEvent, key string) string {
+	if key == "" {
+		return ""
+	}
+	val, ok := event.Select(key)
+	if !ok || val == nil {
+		return ""
+	}
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", val)
+}
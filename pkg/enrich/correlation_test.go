@@ -0,0 +1,98 @@
+package enrich
+
+import (
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"testing"
+	"time"
+
+	"github.com/markuskont/go-sigma-rule-engine"
+)
+
+const correlationRules = `
+title: regular rule without correlation is ignored
+detection:
+  selection:
+    cmd: whoami
+  condition: selection
+---
+title: Repeated sudo failures
+id: sudo-brute
+tags: [attack.t1110]
+correlation:
+  type: event_count
+  rules: sudo-fail
+  group-by: [syslog_host]
+  timespan: 5m
+  condition:
+    gte: 3
+---
+title: Recon then shell
+id: recon-shell
+correlation:
+  type: temporal_ordered
+  rules: [recon, shell]
+  timespan: 1h
+`
+
+func TestSigmaCorrelator(t *testing.T) {
+	rules, err := parseCorrelationRules([]byte(correlationRules), "test.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 correlation rules, got %d", len(rules))
+	}
+	c := NewSigmaCorrelator(rules)
+	start := time.Date(2022, 4, 19, 8, 0, 0, 0, time.UTC)
+
+	observe := func(ts time.Time, host, rule string) []CorrelationAlert {
+		event := &events.Snoopy{Syslog: atomic.Syslog{Timestamp: ts, Host: host}}
+		asset := &meta.GameAsset{SigmaResults: sigma.Results{{ID: rule}}}
+		return c.Observe(events.SnoopyE, event, asset, nil, ts.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		if alerts := observe(start.Add(time.Duration(i)*time.Minute), "web01", "sudo-fail"); len(alerts) != 0 {
+			t.Fatal("threshold not reached yet")
+		}
+	}
+	// other host is counted separately
+	if alerts := observe(start.Add(2*time.Minute), "web02", "sudo-fail"); len(alerts) != 0 {
+		t.Fatal("group-by should separate hosts")
+	}
+	alerts := observe(start.Add(3*time.Minute), "web01", "sudo-fail")
+	if len(alerts) != 1 || alerts[0].Count != 3 || alerts[0].GroupBy["syslog_host"] != "web01" || len(alerts[0].Events) != 3 {
+		t.Fatalf("expected event_count alert, got %+v", alerts)
+	}
+
+	// hits outside timespan do not count
+	observe(start.Add(10*time.Minute), "web01", "sudo-fail")
+	observe(start.Add(20*time.Minute), "web01", "sudo-fail")
+	if alerts := observe(start.Add(30*time.Minute), "web01", "sudo-fail"); len(alerts) != 0 {
+		t.Fatal("hits outside timespan should be dropped")
+	}
+
+	if alerts := observe(start, "db01", "shell"); len(alerts) != 0 {
+		t.Fatal("shell before recon should not fire")
+	}
+	observe(start.Add(time.Minute), "db01", "recon")
+	alerts = observe(start.Add(2*time.Minute), "db01", "shell")
+	if len(alerts) != 1 || alerts[0].RuleID != "recon-shell" || alerts[0].Count != 2 {
+		t.Fatalf("expected temporal_ordered alert, got %+v", alerts)
+	}
+
+	// untimestamped event during replay must not move correlation clock to wall time
+	groups := c.Len()
+	if alerts := observe(time.Time{}, "web01", "sudo-fail"); len(alerts) != 0 {
+		t.Fatal("event without timestamp should not be correlated")
+	}
+	if dropped := c.Expire(); dropped != 0 || c.Len() != groups {
+		t.Fatalf("event without timestamp expired %d of %d groups", dropped, groups)
+	}
+
+	if _, err := parseCorrelationRules([]byte("title: x\ncorrelation:\n  type: event_count\n  rules: a\n  timespan: 1m\n"), "bad.yml"); err == nil {
+		t.Fatal("event_count without condition should fail")
+	}
+}
@@ -6,25 +6,35 @@ import (
 	"errors"
 	"fmt"
 	"go-peek/pkg/intel/mitre"
+	"go-peek/pkg/metrics"
+	"go-peek/pkg/mitremeerkat"
 	"go-peek/pkg/models/atomic"
 	"go-peek/pkg/models/events"
 	"go-peek/pkg/models/meta"
 	"go-peek/pkg/persist"
 	"go-peek/pkg/providentia"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/dgraph-io/badger/v3"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/markuskont/go-sigma-rule-engine"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 const (
 	badgerPrefix        = "assets"
+	badgerHistoryPrefix = "history-assets"
 	badgerSidMapKey     = "enrich-suricata-sid-map"
 	badgerMissingSidKey = "enrich-suricata-sid-missing"
 )
 
-var ErrMissingPersist = errors.New("missing badgerdb persistance")
+var (
+	ErrMissingPersist   = errors.New("missing badgerdb persistance")
+	ErrMissingWaitGroup = errors.New("missing waitgroup")
+)
 
 type ErrMissingAssetData struct{ Event events.GameEvent }
 
@@ -40,7 +50,19 @@ type SigmaConfig struct {
 type Config struct {
 	Persist *persist.Badger
 	Mitre   mitre.Config
-	Sigma   map[events.Atomic]sigma.Ruleset
+	Sigma   SigmaRulesets
+	// FieldMaps translates sigma field names per event kind, built-in defaults are used if missing
+	FieldMaps FieldMaps
+	// MitreMaps extract MITRE ATT&CK info from event fields per kind, built-in defaults are used if missing
+	MitreMaps MitreMaps
+	// Correlations aggregate sigma matches over time, optional
+	Correlations []*CorrelationRule
+	// Networks is exercise network table for segment lookups, optional
+	Networks []*meta.Network
+	// AssetTTL drops asset records that have not been updated within this period, 0 disables expiry
+	AssetTTL time.Duration
+	// AssetLookup selects between current inventory and inventory at event time
+	AssetLookup AssetLookupMode
 }
 
 func (c Config) Validate() error {
@@ -56,10 +78,16 @@ type Counts struct {
 
 	AssetPickups uint
 	AssetUpdates uint
+	AssetStale   uint
+	AssetDeletes uint
+	AssetExpired uint
 	Assets       int
 
 	MappedMitreSIDs int
 
+	SigmaReloads    uint
+	SigmaReloadErrs uint
+
 	ParseErrs  countsParseErrs
 	Enrichment lookups
 	Problems   problems
@@ -70,42 +98,91 @@ type countsParseErrs struct {
 	Windows  uint
 	Syslog   uint
 	Snoopy   uint
+	Zeek     uint
+	Cef      uint
 }
 
 type lookups struct {
 	SuricataSidMatches uint
 	SuricataSidMisses  uint
 
-	SigmaMatches   uint
-	SigmaMisses    uint
-	SigmaNoRuleset uint
+	SigmaMatches      uint
+	SigmaMisses       uint
+	SigmaNoRuleset    uint
+	SigmaCorrelations uint
+
+	SegmentMatches uint
+	SegmentMisses  uint
 }
 
 type problems struct {
 	MissingSuricataTimestamp uint
 }
 
+// Handler is safe for concurrent use, so multiple workers can share asset and SID state
 type Handler struct {
-	Counts
+	// counts is guarded by countsMu, use Stats for a snapshot
+	counts   Counts
+	countsMu *sync.Mutex
+
+	// assetsMu guards assets, missingMu guards missingLookupSet
+	assetsMu  *sync.RWMutex
+	missingMu *sync.Mutex
 
 	missingLookupSet map[string]bool
-	missingSidMaps   map[int]string
 
-	sigma map[events.Atomic]sigma.Ruleset
+	// sidMu guards sidMap and missingSidMaps
+	sidMu          *sync.RWMutex
+	missingSidMaps map[int]string
+	sidMap         map[int]mitremeerkat.Mapping
+
+	sigma       *sigmaStore
+	fieldMaps   FieldMaps
+	mitreMaps   MitreMaps
+	correlation *SigmaCorrelator
+
+	mitre    *mitre.Mapper
+	networks *NetworkTable
+	assets   *assetCache
+	history  *assetHistory
+	assetTTL time.Duration
+	lookup   AssetLookupMode
+	persist  *persist.Badger
+}
+
+// Stats returns a snapshot of handler counters
+func (h *Handler) Stats() Counts {
+	h.countsMu.Lock()
+	defer h.countsMu.Unlock()
+	return h.counts
+}
 
-	mitre   *mitre.Mapper
-	assets  map[string]providentia.Record
-	persist *persist.Badger
+// count applies counter updates under lock
+func (h *Handler) count(fn func(c *Counts)) {
+	h.countsMu.Lock()
+	fn(&h.counts)
+	h.countsMu.Unlock()
 }
 
-func (h Handler) MissingSidMaps() map[int]string {
-	if h.missingSidMaps == nil {
-		return map[int]string{}
+// MitreVersions reports loaded MITRE ATT&CK collections
+func (h *Handler) MitreVersions() []mitre.Version {
+	return append([]mitre.Version{}, h.mitre.Versions...)
+}
+
+// MissingSidMaps returns a copy of suricata SIDs that lack MITRE mapping
+func (h *Handler) MissingSidMaps() map[int]string {
+	h.sidMu.RLock()
+	defer h.sidMu.RUnlock()
+	tx := make(map[int]string, len(h.missingSidMaps))
+	for sid, msg := range h.missingSidMaps {
+		tx[sid] = msg
 	}
-	return h.missingSidMaps
+	return tx
 }
 
-func (h Handler) MissingKeys() []string {
+func (h *Handler) MissingKeys() []string {
+	h.missingMu.Lock()
+	defer h.missingMu.Unlock()
 	keys := make([]string, 0, len(h.missingLookupSet))
 	for key := range h.missingLookupSet {
 		keys = append(keys, key)
@@ -113,40 +190,153 @@ func (h Handler) MissingKeys() []string {
 	return keys
 }
 
-func (h Handler) Persist() error {
-	if err := h.persist.SetSingle(badgerMissingSidKey, h.missingSidMaps); err != nil {
+func (h *Handler) Persist() error {
+	if err := h.persist.SetSingle(badgerMissingSidKey, h.MissingSidMaps()); err != nil {
 		return err
 	}
 	return nil
 }
 
+// AddAsset updates asset cache, older versions of known records are ignored
+// lookup keys that record no longer has are removed
 func (h *Handler) AddAsset(value providentia.Record) *Handler {
-	h.AssetPickups++
-	for _, key := range value.Keys() {
-		_, ok := h.assets[key]
-		if !ok {
-			h.AssetUpdates++
-			h.persist.Set(badgerPrefix, persist.GenericValue{Key: key, Data: value})
-			h.assets[key] = value
+	h.count(func(c *Counts) { c.AssetPickups++ })
+	h.assetsMu.Lock()
+	// history keeps every version, even if it is too old for current inventory
+	if v, ok := h.history.add(value); ok {
+		h.persist.Set(badgerHistoryPrefix, persist.GenericValue{Key: v.key(), Data: v})
+	}
+	ttl := h.recordTTL(value)
+	if ttl < 0 {
+		h.assetsMu.Unlock()
+		h.count(func(c *Counts) { c.AssetStale++ })
+		return h
+	}
+	set, stale, ok := h.assets.put(value)
+	if ok {
+		h.persistAsset(value, ttl, set, stale)
+	}
+	assets := h.assets.len()
+	h.assetsMu.Unlock()
+	metrics.Assets.Set(float64(assets))
+
+	h.count(func(c *Counts) {
+		if ok {
+			c.AssetUpdates++
+		} else {
+			c.AssetStale++
 		}
+		c.Assets = assets
+	})
+	return h
+}
+
+// RemoveAsset drops the record that owns lookup key along with all other keys of that record
+// used for tombstones on compacted asset topic, at is tombstone message time that marks deletion in history
+func (h *Handler) RemoveAsset(key string, at time.Time) *Handler {
+	if at.IsZero() {
+		// brokers before 0.10 do not timestamp messages
+		at = time.Now()
+	}
+	h.assetsMu.Lock()
+	id, removed := h.assets.remove(key)
+	h.persist.Delete(badgerPrefix, removed...)
+	if v, ok := h.history.remove(id, at); ok {
+		h.persist.Set(badgerHistoryPrefix, persist.GenericValue{Key: v.key(), Data: v})
 	}
-	h.Assets = len(h.assets)
+	assets := h.assets.len()
+	h.assetsMu.Unlock()
+	metrics.Assets.Set(float64(assets))
+
+	h.count(func(c *Counts) {
+		if len(removed) > 0 {
+			c.AssetDeletes++
+		}
+		c.Assets = assets
+	})
+	return h
+}
+
+// ExpireAssets drops records that have not been updated within TTL
+// returns number of expired records
+func (h *Handler) ExpireAssets() int {
+	if h.assetTTL <= 0 {
+		return 0
+	}
+	h.assetsMu.Lock()
+	removed, expired := h.assets.expire(time.Now().Add(-h.assetTTL))
+	h.persist.Delete(badgerPrefix, removed...)
+	assets := h.assets.len()
+	h.assetsMu.Unlock()
+	metrics.Assets.Set(float64(assets))
+
+	h.count(func(c *Counts) {
+		c.AssetExpired += uint(expired)
+		c.Assets = assets
+	})
+	return expired
+}
+
+// recordTTL returns time left until record expires, negative value means it already has
+// zero means record is kept until removed
+func (h *Handler) recordTTL(value providentia.Record) time.Duration {
+	if h.assetTTL <= 0 || value.Updated.IsZero() {
+		return 0
+	}
+	if ttl := h.assetTTL - time.Since(value.Updated); ttl > 0 {
+		return ttl
+	}
+	return -1
+}
+
+// persistAsset writes record under every key it owns, so badger mirrors the cache
+// entries carry TTL, so expired assets are also gone if enrichment was down
+func (h *Handler) persistAsset(value providentia.Record, ttl time.Duration, set, stale []string) {
+	h.persist.Delete(badgerPrefix, stale...)
+	if len(set) == 0 {
+		return
+	}
+	vals := make([]persist.GenericValue, 0, len(set))
+	for _, key := range set {
+		vals = append(vals, persist.GenericValue{Key: key, Data: value})
+	}
+	if ttl > 0 {
+		h.persist.SetWithTTL(badgerPrefix, ttl, vals...)
+	} else {
+		h.persist.Set(badgerPrefix, vals...)
+	}
+}
+
+// AddSidMap stores suricata SID to MITRE ATT&CK mapping for alert enrichment
+func (h *Handler) AddSidMap(value mitremeerkat.Mapping) *Handler {
+	h.sidMu.Lock()
+	if current, ok := h.sidMap[value.SID]; ok && current == value {
+		h.sidMu.Unlock()
+		return h
+	}
+	h.persist.Set(badgerSidMapKey, persist.GenericValue{Key: strconv.Itoa(value.SID), Data: value})
+	h.sidMap[value.SID] = value
+	delete(h.missingSidMaps, value.SID)
+	mapped := len(h.sidMap)
+	h.sidMu.Unlock()
+
+	h.count(func(c *Counts) { c.MappedMitreSIDs = mapped })
 	return h
 }
 
 func (h *Handler) Decode(raw []byte, kind events.Atomic) (events.GameEvent, error) {
 	var event events.GameEvent
-	h.Events++
+	h.count(func(c *Counts) { c.Events++ })
 
 	switch kind {
 	case events.SuricataE:
 		var obj atomic.DynamicSuricataEve
 		if err := json.Unmarshal(raw, &obj); err != nil {
-			h.ParseErrs.Suricata++
+			h.count(func(c *Counts) { c.ParseErrs.Suricata++ })
 			return nil, err
 		}
 		if ts := obj.Time(); ts.IsZero() {
-			h.Problems.MissingSuricataTimestamp++
+			h.count(func(c *Counts) { c.Problems.MissingSuricataTimestamp++ })
 		}
 		event = &events.Suricata{
 			Timestamp: obj.Time(),
@@ -155,9 +345,14 @@ func (h *Handler) Decode(raw []byte, kind events.Atomic) (events.GameEvent, erro
 	case events.EventLogE, events.SysmonE:
 		var obj atomic.DynamicWinlogbeat
 		if err := json.Unmarshal(raw, &obj); err != nil {
-			h.ParseErrs.Windows++
+			h.count(func(c *Counts) { c.ParseErrs.Windows++ })
 			return nil, err
 		}
+		// sysmon is usually shipped in the same stream as other windows logs
+		if kind == events.SysmonE || obj.IsSysmon() {
+			event = events.NewSysmon(obj)
+			break
+		}
 		event = &events.DynamicWinlogbeat{
 			Timestamp:         obj.Time(),
 			DynamicWinlogbeat: obj,
@@ -165,54 +360,93 @@ func (h *Handler) Decode(raw []byte, kind events.Atomic) (events.GameEvent, erro
 	case events.SyslogE:
 		var obj events.Syslog
 		if err := json.Unmarshal(raw, &obj); err != nil {
-			h.ParseErrs.Syslog++
+			h.count(func(c *Counts) { c.ParseErrs.Syslog++ })
 			return nil, err
 		}
 		event = &obj
 	case events.SnoopyE:
 		var obj events.Snoopy
 		if err := json.Unmarshal(raw, &obj); err != nil {
-			h.ParseErrs.Snoopy++
+			h.count(func(c *Counts) { c.ParseErrs.Snoopy++ })
 			return nil, err
 		}
 		event = &obj
+	case events.ZeekE:
+		var obj atomic.DynamicZeek
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			h.count(func(c *Counts) { c.ParseErrs.Zeek++ })
+			return nil, err
+		}
+		event = &events.Zeek{
+			Timestamp: obj.Time(),
+			Data:      obj,
+		}
+	case events.MazeRunnerE:
+		var obj events.Cef
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			h.count(func(c *Counts) { c.ParseErrs.Cef++ })
+			return nil, err
+		}
+		obj.Profile = events.CefProfiles[kind]
+		event = &obj
 	}
 	return event, nil
 }
 
-func (h *Handler) Enrich(event events.GameEvent) error {
+// Enrich attaches asset, sigma and MITRE ATT&CK info to event
+// returns attached meta, so callers do not need to know concrete event type
+func (h *Handler) Enrich(event events.GameEvent) (*meta.GameAsset, error) {
 	// get blank asset template with info from message
 	asset := event.GetAsset()
 	if asset == nil {
-		return ErrMissingAssetData{event}
+		return nil, ErrMissingAssetData{event}
 	}
 
 	// do asset db lookup
-	asset.Asset = *h.assetLookup(asset.Asset)
+	ts := event.Time()
+	asset.Asset = *h.assetLookup(asset.Asset, ts)
 	if asset.Source != nil {
-		asset.Source = h.assetLookup(*asset.Source)
+		asset.Source = h.assetLookup(*asset.Source, ts)
 	}
 	if asset.Destination != nil {
-		asset.Destination = h.assetLookup(*asset.Destination)
+		asset.Destination = h.assetLookup(*asset.Destination, ts)
 	}
 
 	// SIGMA match
-	if h.sigma != nil {
-		ruleset, ok := h.sigma[event.Kind()]
+	if rulesets := h.sigma.Load(); rulesets != nil {
+		ruleset, ok := rulesets[event.Kind()]
 		if ok {
-			if result, match := ruleset.EvalAll(event); match && len(result) > 0 {
+			mapped := mappedEvent{GameEvent: event, fields: h.fieldMaps[event.Kind()]}
+			if result, match := ruleset.EvalAll(mapped); match && len(result) > 0 {
 				asset.SigmaResults = result
-				h.Enrichment.SigmaMatches++
+				metrics.SigmaMatches.WithLabelValues(event.Kind().String()).Inc()
+				h.count(func(c *Counts) { c.Enrichment.SigmaMatches++ })
 			} else {
-				h.Enrichment.SigmaMisses++
+				h.count(func(c *Counts) { c.Enrichment.SigmaMisses++ })
 			}
 		} else {
-			h.Enrichment.SigmaNoRuleset++
+			h.count(func(c *Counts) { c.Enrichment.SigmaNoRuleset++ })
 		}
 	}
 
 	// add MITRE ATT&CK info
-	if mitreInfo := event.GetMitreAttack(); mitreInfo != nil {
+	mitreInfo := h.mitreMaps.Extract(event, h.mitre.Tactics)
+	if mitreInfo == nil || len(mitreInfo.Techniques) == 0 {
+		// extracted tactics are kept when technique comes from elsewhere
+		technique := event.GetMitreAttack()
+		if s, ok := event.(*events.Suricata); ok && technique == nil {
+			technique = h.sidMitreLookup(*s)
+		}
+		if technique != nil && mitreInfo != nil {
+			merged := *technique
+			merged.Tactics = append(append([]string{}, technique.Tactics...), mitreInfo.Tactics...)
+			technique = &merged
+		}
+		if technique != nil {
+			mitreInfo = technique
+		}
+	}
+	if mitreInfo != nil {
 		mitreInfo.Set(h.mitre.Mappings)
 		asset.MitreAttack = mitreInfo
 	}
@@ -241,31 +475,189 @@ func (h *Handler) Enrich(event events.GameEvent) error {
 
 	event.SetAsset(asset.SetDirection())
 
-	return nil
+	return asset, nil
+}
+
+// Correlate feeds sigma matches of enriched event to correlation rules
+// returns synthetic alerts for correlations that reached threshold
+func (h *Handler) Correlate(event events.GameEvent, asset *meta.GameAsset, id string) []CorrelationAlert {
+	if h.correlation == nil {
+		return nil
+	}
+	alerts := h.correlation.Observe(event.Kind(), event, asset, h.fieldMaps[event.Kind()], id)
+	for i, alert := range alerts {
+		if st := parseMitreTags(alert.Tags, h.mitre.Mappings); len(st) > 0 {
+			if alert.GameMeta.MitreAttack == nil {
+				alert.GameMeta.MitreAttack = &meta.MitreAttack{}
+			}
+			alert.GameMeta.MitreAttack.Techniques = append(st, alert.GameMeta.MitreAttack.Techniques...)
+		}
+		if alert.GameMeta.MitreAttack != nil {
+			alert.GameMeta.MitreAttack.Update()
+		}
+		alerts[i] = alert
+	}
+	if len(alerts) > 0 {
+		metrics.SigmaCorrelations.WithLabelValues(event.Kind().String()).Add(float64(len(alerts)))
+		h.count(func(c *Counts) { c.Enrichment.SigmaCorrelations += uint(len(alerts)) })
+	}
+	return alerts
+}
+
+// ExpireCorrelations drops correlation state that fell out of every rule timespan
+func (h *Handler) ExpireCorrelations() int {
+	if h.correlation == nil {
+		return 0
+	}
+	return h.correlation.Expire()
+}
+
+func (h *Handler) assetLookup(asset meta.Asset, ts time.Time) *meta.Asset {
+	return h.segmentLookup(h.recordLookup(asset, ts))
+}
+
+// segmentLookup attaches network segment to asset
+// hosts that are missing from asset table inherit team and zone from segment
+func (h *Handler) segmentLookup(asset *meta.Asset) *meta.Asset {
+	if h.networks == nil || asset.IP == nil {
+		return asset
+	}
+	segment, network := h.networks.Segment(asset.IP)
+	if segment == nil {
+		h.count(func(c *Counts) { c.Enrichment.SegmentMisses++ })
+		return asset
+	}
+	h.count(func(c *Counts) { c.Enrichment.SegmentMatches++ })
+	asset.Segment = segment
+	if !asset.IsAsset {
+		asset.IsAsset = network.Assets
+		if asset.Team == "" {
+			asset.Team = network.Team
+		}
+		if asset.Zone == "" {
+			asset.Zone = network.Zone
+		}
+	}
+	return asset
 }
 
-func (h Handler) assetLookup(asset meta.Asset) *meta.Asset {
+func (h *Handler) recordLookup(asset meta.Asset, ts time.Time) *meta.Asset {
 	if asset.IP != nil {
-		if val, ok := h.assets[asset.IP.String()]; ok {
+		if val, ok := h.getAsset(asset.IP.String(), ts); ok {
 			return val.Asset()
 		}
 	}
 	if asset.Host != "" {
-		if val, ok := h.assets[asset.Host]; ok {
-			delete(h.missingLookupSet, asset.Host)
+		if val, ok := h.getAsset(asset.Host, ts); ok {
+			h.setMissing(asset.Host, false)
 			return val.Asset()
 		} else if fqdn := asset.FQDN(); fqdn != "" {
-			if val, ok := h.assets[fqdn]; ok {
-				delete(h.missingLookupSet, fqdn)
+			if val, ok := h.getAsset(fqdn, ts); ok {
+				h.setMissing(fqdn, false)
 				return val.Asset()
 			}
 		}
-		h.missingLookupSet[asset.Host] = true
+		h.setMissing(asset.Host, true)
 	}
 	return &asset
 }
 
-func (h Handler) Close() error {
+// getAsset resolves lookup key against current inventory or, in point-in-time mode, inventory at ts
+// events without timestamp always use current inventory
+func (h *Handler) getAsset(key string, ts time.Time) (providentia.Record, bool) {
+	h.assetsMu.RLock()
+	defer h.assetsMu.RUnlock()
+	if h.lookup == AssetLookupPointInTime && !ts.IsZero() {
+		return h.history.at(key, ts, h.assetTTL)
+	}
+	return h.assets.get(key)
+}
+
+func (h *Handler) setMissing(key string, missing bool) {
+	h.missingMu.Lock()
+	defer h.missingMu.Unlock()
+	if missing {
+		h.missingLookupSet[key] = true
+	} else {
+		delete(h.missingLookupSet, key)
+	}
+	metrics.MissingLookups.Set(float64(len(h.missingLookupSet)))
+}
+
+// loadAssets rebuilds asset cache from badger
+// persisted keys may hold older versions of a record, those are rewritten or dropped
+func (h *Handler) loadAssets() error {
+	stored := make(map[string]providentia.Record)
+	for record := range h.persist.Scan(badgerPrefix) {
+		var obj providentia.Record
+		buf := bytes.NewBuffer(record.Data)
+		if err := gob.NewDecoder(buf).Decode(&obj); err != nil {
+			return err
+		}
+		stored[record.Key] = obj
+	}
+
+	latest := make(map[string]providentia.Record)
+	for _, obj := range stored {
+		id := assetID(obj)
+		if current, ok := latest[id]; !ok || obj.Updated.After(current.Updated) {
+			latest[id] = obj
+		}
+	}
+	records := make([]providentia.Record, 0, len(latest))
+	for _, obj := range latest {
+		if h.recordTTL(obj) >= 0 {
+			records = append(records, obj)
+		}
+	}
+	// replay oldest first, so newer records win key collisions
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Updated.Before(records[j].Updated)
+	})
+
+	h.assets = newAssetCache()
+	for _, obj := range records {
+		h.assets.put(obj)
+	}
+
+	stale := make([]string, 0)
+	for key, obj := range stored {
+		current, ok := h.assets.get(key)
+		switch {
+		case !ok:
+			stale = append(stale, key)
+		case assetID(current) != assetID(obj) || !current.Updated.Equal(obj.Updated):
+			h.persistAsset(current, h.recordTTL(current), []string{key}, nil)
+		}
+	}
+	if err := h.persist.Delete(badgerPrefix, stale...); err != nil {
+		return err
+	}
+
+	h.history = newAssetHistory()
+	for record := range h.persist.Scan(badgerHistoryPrefix) {
+		var obj assetVersion
+		buf := bytes.NewBuffer(record.Data)
+		if err := gob.NewDecoder(buf).Decode(&obj); err != nil {
+			return err
+		}
+		h.history.restore(obj)
+	}
+	// databases from before history was kept are seeded with current inventory
+	for _, obj := range records {
+		if _, ok := h.history.versions[assetID(obj)]; !ok {
+			if v, ok := h.history.add(obj); ok {
+				h.persist.Set(badgerHistoryPrefix, persist.GenericValue{Key: v.key(), Data: v})
+			}
+		}
+	}
+
+	h.counts.Assets = h.assets.len()
+	metrics.Assets.Set(float64(h.assets.len()))
+	return nil
+}
+
+func (h *Handler) Close() error {
 	return nil
 }
 
@@ -275,25 +667,41 @@ func NewHandler(c Config) (*Handler, error) {
 	}
 
 	handler := &Handler{
+		countsMu:         &sync.Mutex{},
+		assetsMu:         &sync.RWMutex{},
+		missingMu:        &sync.Mutex{},
+		sidMu:            &sync.RWMutex{},
 		persist:          c.Persist,
 		missingLookupSet: make(map[string]bool),
+		missingSidMaps:   make(map[int]string),
+		sidMap:           make(map[int]mitremeerkat.Mapping),
+	}
+	handler.assetTTL = c.AssetTTL
+	handler.lookup = c.AssetLookup
+	if err := handler.loadAssets(); err != nil {
+		return nil, err
 	}
-	assets := make(map[string]providentia.Record)
-	records := c.Persist.Scan(badgerPrefix)
 
-	for record := range records {
-		var obj providentia.Record
+	for record := range c.Persist.Scan(badgerSidMapKey) {
+		var obj mitremeerkat.Mapping
 		buf := bytes.NewBuffer(record.Data)
-		err := gob.NewDecoder(buf).Decode(&obj)
-		if err != nil {
+		if err := gob.NewDecoder(buf).Decode(&obj); err != nil {
 			return nil, err
 		}
-		for _, key := range obj.Keys() {
-			assets[key] = obj
-		}
+		handler.sidMap[obj.SID] = obj
+	}
+	handler.counts.MappedMitreSIDs = len(handler.sidMap)
+
+	err := c.Persist.GetSingle(badgerMissingSidKey, func(b []byte) error {
+		buf := bytes.NewBuffer(b)
+		return gob.NewDecoder(buf).Decode(&handler.missingSidMaps)
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	for sid := range handler.sidMap {
+		delete(handler.missingSidMaps, sid)
 	}
-	handler.assets = assets
-	handler.Assets = len(handler.assets)
 
 	m, err := mitre.NewMapper(c.Mitre)
 	if err != nil {
@@ -301,8 +709,23 @@ func NewHandler(c Config) (*Handler, error) {
 	}
 	handler.mitre = m
 
+	handler.sigma = &sigmaStore{}
 	if c.Sigma != nil && len(c.Sigma) > 0 {
-		handler.sigma = c.Sigma
+		handler.sigma.Store(c.Sigma)
+	}
+	if len(c.Networks) > 0 {
+		handler.networks = NewNetworkTable(c.Networks)
+	}
+	if len(c.Correlations) > 0 {
+		handler.correlation = NewSigmaCorrelator(c.Correlations)
+	}
+	handler.fieldMaps = c.FieldMaps
+	if handler.fieldMaps == nil {
+		handler.fieldMaps = DefaultFieldMaps()
+	}
+	handler.mitreMaps = c.MitreMaps
+	if handler.mitreMaps == nil {
+		handler.mitreMaps = DefaultMitreMaps()
 	}
 
 	return handler, nil
@@ -0,0 +1,136 @@
+package enrich
+
+import (
+	_ "embed"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldMapWildcard is a fallback template for fields that are not explicitly mapped
+const fieldMapWildcard = "*"
+
+//go:embed fieldmap.yaml
+var defaultFieldMaps []byte
+
+type ErrFieldMapKind struct {
+	Kind string
+	Path string
+}
+
+func (e ErrFieldMapKind) Error() string {
+	return fmt.Sprintf("unknown event kind %s in field map %s", e.Kind, e.Path)
+}
+
+// fieldPaths is a list of event paths that accepts a single string in YAML
+type fieldPaths []string
+
+func (f *fieldPaths) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*f = fieldPaths{value.Value}
+		return nil
+	}
+	var paths []string
+	if err := value.Decode(&paths); err != nil {
+		return err
+	}
+	*f = paths
+	return nil
+}
+
+// FieldMap translates sigma rule field names to event specific paths
+type FieldMap map[string][]string
+
+// Paths returns candidate event paths for sigma field, original key is always tried after explicit mappings
+func (f FieldMap) Paths(key string) []string {
+	paths := make([]string, 0, 4)
+	paths = append(paths, f[key]...)
+	paths = append(paths, key)
+	for _, tmpl := range f[fieldMapWildcard] {
+		paths = append(paths, strings.ReplaceAll(tmpl, fieldMapWildcard, key))
+	}
+	return paths
+}
+
+// FieldMaps holds sigma field mappings per event kind
+type FieldMaps map[events.Atomic]FieldMap
+
+// Merge overrides field mappings with ones from other object
+func (f FieldMaps) Merge(other FieldMaps) FieldMaps {
+	for kind, fields := range other {
+		if _, ok := f[kind]; !ok {
+			f[kind] = make(FieldMap)
+		}
+		for key, paths := range fields {
+			f[kind][key] = paths
+		}
+	}
+	return f
+}
+
+// ParseFieldMaps parses YAML field mappings, top level keys are event kinds
+func ParseFieldMaps(data []byte, path string) (FieldMaps, error) {
+	var raw map[string]map[string]fieldPaths
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	maps := make(FieldMaps)
+	for key, fields := range raw {
+		kind, ok := events.NewAtomic(key)
+		if !ok {
+			return nil, ErrFieldMapKind{Kind: key, Path: path}
+		}
+		m := make(FieldMap)
+		for field, paths := range fields {
+			m[field] = paths
+		}
+		maps[kind] = m
+	}
+	return maps, nil
+}
+
+// DefaultFieldMaps returns built-in mappings for sigma rules written against sysmon field names
+func DefaultFieldMaps() FieldMaps {
+	maps, err := ParseFieldMaps(defaultFieldMaps, "builtin")
+	if err != nil {
+		panic(err)
+	}
+	return maps
+}
+
+// LoadFieldMaps reads field mappings from YAML file and merges them with built-in defaults
+// empty path returns defaults
+func LoadFieldMaps(path string) (FieldMaps, error) {
+	maps := DefaultFieldMaps()
+	if path == "" {
+		return maps, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := ParseFieldMaps(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return maps.Merge(custom), nil
+}
+
+// mappedEvent wraps event for sigma evaluation, translating field names before lookup
+type mappedEvent struct {
+	events.GameEvent
+	fields FieldMap
+}
+
+// Select implements sigma.Selector
+func (m mappedEvent) Select(key string) (interface{}, bool) {
+	for _, path := range m.fields.Paths(key) {
+		if val, ok := m.GameEvent.Select(path); ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
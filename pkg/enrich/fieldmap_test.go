@@ -0,0 +1,41 @@
+package enrich
+
+import (
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/events"
+	"testing"
+)
+
+func TestFieldMap(t *testing.T) {
+	maps := DefaultFieldMaps()
+	custom, err := ParseFieldMaps([]byte("snoopy:\n  Image: [cwd, filename]\n"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	maps.Merge(custom)
+
+	event := &events.Snoopy{Snoopy: atomic.Snoopy{Cmd: "ls -la", Filename: "/bin/ls", Cwd: "/root"}}
+	mapped := mappedEvent{GameEvent: event, fields: maps[events.SnoopyE]}
+	if val, ok := mapped.Select("CommandLine"); !ok || val != "ls -la" {
+		t.Fatalf("CommandLine mapped to %v", val)
+	}
+	if val, ok := mapped.Select("Image"); !ok || val != "/root" {
+		t.Fatalf("Image override mapped to %v", val)
+	}
+	if val, ok := mapped.Select("cmd"); !ok || val != "ls -la" {
+		t.Fatalf("original key returned %v", val)
+	}
+
+	win := events.NewSysmon(atomic.DynamicWinlogbeat{
+		"winlog": map[string]interface{}{
+			"event_data": map[string]interface{}{"TargetFilename": `C:\evil.exe`},
+		},
+	})
+	mapped = mappedEvent{GameEvent: win, fields: maps[events.SysmonE]}
+	if val, ok := mapped.Select("TargetFilename"); !ok || val != `C:\evil.exe` {
+		t.Fatalf("wildcard mapped to %v", val)
+	}
+	if _, err := ParseFieldMaps([]byte("bogus:\n  a: b\n"), "test"); err == nil {
+		t.Fatal("expected error for unknown event kind")
+	}
+}
@@ -0,0 +1,221 @@
+package enrich
+
+import (
+	"fmt"
+	"go-peek/pkg/providentia"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ErrAssetLookupMode struct{ Mode string }
+
+func (e ErrAssetLookupMode) Error() string {
+	return fmt.Sprintf("invalid asset lookup mode %s, expected latest or point-in-time", e.Mode)
+}
+
+// AssetLookupMode selects which inventory version is used to enrich an event
+type AssetLookupMode int
+
+const (
+	// AssetLookupLatest uses current inventory regardless of event time
+	AssetLookupLatest AssetLookupMode = iota
+	// AssetLookupPointInTime uses inventory as it was when event happened, meant for reprocessing old data
+	AssetLookupPointInTime
+)
+
+func (m AssetLookupMode) String() string {
+	switch m {
+	case AssetLookupPointInTime:
+		return "point-in-time"
+	default:
+		return "latest"
+	}
+}
+
+func NewAssetLookupMode(raw string) (AssetLookupMode, error) {
+	switch strings.ToLower(raw) {
+	case "", "latest":
+		return AssetLookupLatest, nil
+	case "point-in-time", "pit":
+		return AssetLookupPointInTime, nil
+	default:
+		return AssetLookupLatest, ErrAssetLookupMode{Mode: raw}
+	}
+}
+
+// assetVersion is a record that was valid from From until next version of the same asset
+// Seen is last update that repeated the same data, Deleted marks removal of asset
+type assetVersion struct {
+	ID      string
+	From    time.Time
+	Seen    time.Time
+	Deleted bool
+	Record  providentia.Record
+}
+
+func (v assetVersion) key() string {
+	return v.ID + "-" + strconv.FormatInt(v.From.UnixNano(), 10)
+}
+
+func (v assetVersion) hasKey(key string) bool {
+	for _, k := range v.Record.Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sameRecord compares record content, ignoring update timestamp
+func sameRecord(a, b providentia.Record) bool {
+	return a.AnsibleName == b.AnsibleName &&
+		a.HostName == b.HostName &&
+		a.Pretty == b.Pretty &&
+		a.Domain == b.Domain &&
+		a.Role == b.Role &&
+		a.Addr.Equal(b.Addr) &&
+		a.Team == b.Team &&
+		a.OS == b.OS &&
+		a.NetworkName == b.NetworkName &&
+		a.FQDN == b.FQDN
+}
+
+// assetHistory keeps every known version of every asset, so lookups can be resolved at any point in time
+// versions may arrive out of order when asset topic is replayed
+// not safe for concurrent use, handler guards it with assetsMu
+type assetHistory struct {
+	// versions are sorted by From per asset ID
+	versions map[string][]assetVersion
+	// owners maps lookup key to all asset IDs that have ever used it
+	owners map[string]map[string]bool
+}
+
+// add inserts record as a new version, or extends the version it repeats
+// version with equal timestamp is replaced, as both would be persisted under the same key
+// returns version that should be persisted
+func (a *assetHistory) add(r providentia.Record) (assetVersion, bool) {
+	id := assetID(r)
+	if id == "" || r.Updated.IsZero() {
+		return assetVersion{}, false
+	}
+	versions := a.versions[id]
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].From.After(r.Updated)
+	})
+	if i > 0 && !versions[i-1].Deleted && sameRecord(versions[i-1].Record, r) {
+		if r.Updated.After(versions[i-1].Seen) {
+			versions[i-1].Seen = r.Updated
+		}
+		return versions[i-1], true
+	}
+	v := assetVersion{ID: id, From: r.Updated, Seen: r.Updated, Record: r}
+	a.put(v)
+	for _, key := range r.Keys() {
+		a.own(key, id)
+	}
+	return v, true
+}
+
+// remove marks asset as deleted from given time onward
+func (a *assetHistory) remove(id string, at time.Time) (assetVersion, bool) {
+	versions, ok := a.versions[id]
+	if !ok || len(versions) == 0 {
+		return assetVersion{}, false
+	}
+	v := assetVersion{ID: id, From: at, Seen: at, Deleted: true}
+	a.put(v)
+	return v, true
+}
+
+// restore loads persisted version as-is
+func (a *assetHistory) restore(v assetVersion) {
+	id := v.ID
+	if id == "" {
+		return
+	}
+	a.put(v)
+	if !v.Deleted {
+		for _, key := range v.Record.Keys() {
+			a.own(key, id)
+		}
+	}
+}
+
+// put inserts version in From order, replacing one that starts at the same time
+func (a *assetHistory) put(v assetVersion) {
+	versions := a.versions[v.ID]
+	i := sort.Search(len(versions), func(i int) bool {
+		return versions[i].From.After(v.From)
+	})
+	if i > 0 && versions[i-1].From.Equal(v.From) {
+		versions[i-1] = v
+		return
+	}
+	versions = append(versions, assetVersion{})
+	copy(versions[i+1:], versions[i:])
+	versions[i] = v
+	a.versions[v.ID] = versions
+}
+
+func (a *assetHistory) own(key, id string) {
+	if _, ok := a.owners[key]; !ok {
+		a.owners[key] = make(map[string]bool)
+	}
+	a.owners[key][id] = true
+}
+
+// at returns record that held lookup key at given time
+// version is considered expired if it was not seen within ttl, 0 disables expiry
+// events that predate all versions of all owners resolve to the earliest one
+func (a assetHistory) at(key string, ts time.Time, ttl time.Duration) (providentia.Record, bool) {
+	var (
+		best, earliest           assetVersion
+		foundBest, foundEarliest bool
+		known                    bool
+	)
+	for id := range a.owners[key] {
+		versions := a.versions[id]
+		i := sort.Search(len(versions), func(i int) bool {
+			return versions[i].From.After(ts)
+		})
+		if i == 0 {
+			for _, v := range versions {
+				if v.Deleted || !v.hasKey(key) {
+					continue
+				}
+				if !foundEarliest || v.From.Before(earliest.From) {
+					earliest, foundEarliest = v, true
+				}
+				break
+			}
+			continue
+		}
+		known = true
+		v := versions[i-1]
+		if v.Deleted || !v.hasKey(key) {
+			continue
+		}
+		if ttl > 0 && ts.Sub(v.Seen) > ttl {
+			continue
+		}
+		if !foundBest || v.From.After(best.From) {
+			best, foundBest = v, true
+		}
+	}
+	if foundBest {
+		return best.Record, true
+	}
+	if foundEarliest && !known {
+		return earliest.Record, true
+	}
+	return providentia.Record{}, false
+}
+
+func newAssetHistory() *assetHistory {
+	return &assetHistory{
+		versions: make(map[string][]assetVersion),
+		owners:   make(map[string]map[string]bool),
+	}
+}
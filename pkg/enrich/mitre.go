@@ -1,6 +1,7 @@
 package enrich
 
 import (
+	"go-peek/pkg/models/events"
 	"go-peek/pkg/models/meta"
 	"strings"
 )
@@ -24,9 +25,46 @@ loop:
 			continue loop
 		}
 		tx = append(tx, t)
+		// sub-technique tags also count toward parent technique
+		if parent, ok := mappings[t.Parent]; ok && t.Parent != "" {
+			tx = append(tx, parent)
+		}
 	}
 	if len(tx) == 0 {
 		return nil
 	}
 	return tx
 }
+
+// sidMitreLookup resolves MITRE technique for suricata alerts that lack it in rule metadata
+// unknown SIDs are collected into missing report
+func (h *Handler) sidMitreLookup(s events.Suricata) *meta.MitreAttack {
+	sid, ok := s.SignatureID()
+	if !ok {
+		return nil
+	}
+	h.sidMu.RLock()
+	mapping, ok := h.sidMap[sid]
+	h.sidMu.RUnlock()
+	if !ok {
+		h.count(func(c *Counts) { c.Enrichment.SuricataSidMisses++ })
+		h.sidMu.Lock()
+		h.missingSidMaps[sid] = s.Signature()
+		h.sidMu.Unlock()
+		return nil
+	}
+	h.count(func(c *Counts) { c.Enrichment.SuricataSidMatches++ })
+	id := strings.ToUpper(strings.TrimSpace(mapping.ID))
+	if id == "" {
+		// SID is known but deliberately left unmapped
+		return nil
+	}
+	t := meta.Technique{ID: id, Name: mapping.Name}
+	if mapping.Tactic != "" {
+		t.Phases = []string{mapping.Tactic}
+	}
+	return &meta.MitreAttack{
+		Technique:  t,
+		Techniques: []meta.Technique{t},
+	}
+}
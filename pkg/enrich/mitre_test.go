@@ -0,0 +1,110 @@
+package enrich
+
+import (
+	"go-peek/pkg/mitremeerkat"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/persist"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestPersist(t *testing.T) *persist.Badger {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	p, err := persist.NewBadger(persist.Config{Directory: t.TempDir(), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func newTestHandler(t *testing.T, p *persist.Badger) *Handler {
+	t.Helper()
+	h, err := NewHandler(Config{Persist: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func suricataAlert(t *testing.T, sid int, signature string) events.Suricata {
+	t.Helper()
+	raw, err := json.Marshal(map[string]any{
+		"timestamp":  "2022-04-20T10:00:00.000000+0000",
+		"event_type": "alert",
+		"alert":      map[string]any{"signature_id": sid, "signature": signature},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj atomic.DynamicSuricataEve
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		t.Fatal(err)
+	}
+	return events.Suricata{Data: obj}
+}
+
+func TestSidMitreLookup(t *testing.T) {
+	p := newTestPersist(t)
+	h := newTestHandler(t, p)
+	h.AddSidMap(mitremeerkat.Mapping{SID: 2000001, ID: "t1059", Name: "Command and Scripting Interpreter", Tactic: "execution"})
+	// known SID that is deliberately left unmapped
+	h.AddSidMap(mitremeerkat.Mapping{SID: 2000002, MSG: "ET INFO benign"})
+
+	testCases := []struct {
+		desc      string
+		sid       int
+		signature string
+		technique string
+		missing   bool
+	}{
+		{desc: "mapped", sid: 2000001, signature: "ET EXPLOIT powershell", technique: "T1059"},
+		{desc: "unmapped", sid: 2000002, signature: "ET INFO benign"},
+		{desc: "unknown", sid: 2000003, signature: "ET SCAN nmap", missing: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			attack := h.sidMitreLookup(suricataAlert(t, tc.sid, tc.signature))
+			switch {
+			case tc.technique == "" && attack != nil:
+				t.Fatalf("expected no technique, got %+v", attack)
+			case tc.technique != "" && (attack == nil || attack.Technique.ID != tc.technique):
+				t.Fatalf("expected %s, got %+v", tc.technique, attack)
+			case tc.technique != "" && (len(attack.Technique.Phases) != 1 || attack.Technique.Phases[0] != "execution"):
+				t.Fatalf("expected tactic from mapping, got %+v", attack.Technique)
+			}
+			msg, missing := h.MissingSidMaps()[tc.sid]
+			if missing != tc.missing || missing && msg != tc.signature {
+				t.Fatalf("missing report for %d: %t [%s]", tc.sid, missing, msg)
+			}
+		})
+	}
+	if h.counts.Enrichment.SuricataSidMatches != 2 || h.counts.Enrichment.SuricataSidMisses != 1 {
+		t.Fatalf("unexpected sid counts %+v", h.counts.Enrichment)
+	}
+	if err := h.Persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	// mappings and missing report survive restart
+	h = newTestHandler(t, p)
+	if h.counts.MappedMitreSIDs != 2 {
+		t.Fatalf("expected 2 mapped SIDs after reload, got %d", h.counts.MappedMitreSIDs)
+	}
+	if attack := h.sidMitreLookup(suricataAlert(t, 2000001, "")); attack == nil || attack.Technique.ID != "T1059" {
+		t.Fatalf("reloaded mapping not resolved, got %+v", attack)
+	}
+	if _, ok := h.MissingSidMaps()[2000003]; !ok {
+		t.Fatal("missing report not reloaded")
+	}
+
+	// mapping that arrives later clears missing report
+	h.AddSidMap(mitremeerkat.Mapping{SID: 2000003, ID: "T1046", Name: "Network Service Discovery"})
+	if _, ok := h.MissingSidMaps()[2000003]; ok {
+		t.Fatal("mapped SID should be removed from missing report")
+	}
+}
@@ -0,0 +1,211 @@
+package enrich
+
+import (
+	_ "embed"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mitreDefaultPattern is used for rules that do not define one, value is expected to hold technique IDs
+const mitreDefaultPattern = `(?i)(?P<technique>\bT\d{4}(?:\.\d{3})?\b)`
+
+var mitreTacticID = regexp.MustCompile(`(?i)^TA\d{4}$`)
+
+//go:embed mitremap.yaml
+var defaultMitreMaps []byte
+
+type ErrMitreMapPattern struct {
+	Kind    string
+	Pattern string
+	Path    string
+	Err     error
+}
+
+func (e ErrMitreMapPattern) Error() string {
+	return fmt.Sprintf("invalid MITRE extraction pattern %s for %s in %s: %s", e.Pattern, e.Kind, e.Path, e.Err)
+}
+
+// MitreRule extracts techniques and tactics from event field with regular expression
+type MitreRule struct {
+	Fields  fieldPaths `yaml:"field"`
+	Pattern string     `yaml:"pattern"`
+
+	re *regexp.Regexp
+}
+
+func (r *MitreRule) compile() error {
+	if r.Pattern == "" {
+		r.Pattern = mitreDefaultPattern
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return err
+	}
+	r.re = re
+	return nil
+}
+
+// MitreMaps holds MITRE extraction rules per event kind
+type MitreMaps map[events.Atomic][]MitreRule
+
+// Merge replaces rules of event kinds that are defined in other object
+func (m MitreMaps) Merge(other MitreMaps) MitreMaps {
+	for kind, rules := range other {
+		m[kind] = rules
+	}
+	return m
+}
+
+// Extract collects MITRE ATT&CK info from event fields
+// returns nil if event kind has no rules or neither technique nor tactic was found
+// tactic names are resolved to IDs with tactic table when possible
+func (m MitreMaps) Extract(event events.GameEvent, tactics meta.Tactics) *meta.MitreAttack {
+	rules, ok := m[event.Kind()]
+	if !ok {
+		return nil
+	}
+	attack := &meta.MitreAttack{}
+	for _, rule := range rules {
+		val, ok := selectFirst(event, rule.Fields)
+		if !ok {
+			continue
+		}
+		for _, item := range mitreValues(val) {
+			for _, match := range rule.re.FindAllStringSubmatch(item, -1) {
+				var technique meta.Technique
+				var tactic string
+				for i, group := range rule.re.SubexpNames() {
+					value := strings.TrimSpace(match[i])
+					switch group {
+					case "technique":
+						technique.ID = strings.ToUpper(value)
+					case "name":
+						technique.Name = value
+					case "tactic":
+						tactic = resolveTactic(value, tactics)
+					}
+				}
+				switch {
+				case technique.ID != "":
+					if tactic != "" {
+						technique.Tactics = []string{tactic}
+					}
+					attack.Techniques = append(attack.Techniques, technique)
+				case tactic != "":
+					attack.Tactics = append(attack.Tactics, tactic)
+				}
+			}
+		}
+	}
+	if len(attack.Techniques) == 0 && len(attack.Tactics) == 0 {
+		return nil
+	}
+	// events may carry only tactic metadata, primary technique stays empty then
+	if len(attack.Techniques) > 0 {
+		attack.Technique = attack.Techniques[0]
+	}
+	return attack
+}
+
+func selectFirst(event events.GameEvent, paths []string) (any, bool) {
+	for _, path := range paths {
+		if val, ok := event.Select(path); ok && val != nil {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+func mitreValues(val any) []string {
+	switch v := val.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		tx := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				tx = append(tx, s)
+			}
+		}
+		return tx
+	}
+	return nil
+}
+
+// resolveTactic maps tactic name or short name to ID, enterprise matrix is preferred on name clash
+func resolveTactic(value string, tactics meta.Tactics) string {
+	if value == "" || mitreTacticID.MatchString(value) {
+		return strings.ToUpper(value)
+	}
+	short := strings.ReplaceAll(strings.ToLower(value), " ", "-")
+	var found string
+	for id, tactic := range tactics {
+		if tactic.ShortName != short && !strings.EqualFold(tactic.Name, value) {
+			continue
+		}
+		if found == "" || tactic.Domain == "enterprise-attack" {
+			found = id
+		}
+	}
+	if found == "" {
+		return value
+	}
+	return found
+}
+
+// ParseMitreMaps parses YAML extraction rules, top level keys are event kinds
+func ParseMitreMaps(data []byte, path string) (MitreMaps, error) {
+	var raw map[string][]MitreRule
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	maps := make(MitreMaps)
+	for key, rules := range raw {
+		kind, ok := events.NewAtomic(key)
+		if !ok {
+			return nil, ErrFieldMapKind{Kind: key, Path: path}
+		}
+		for i := range rules {
+			if err := rules[i].compile(); err != nil {
+				return nil, ErrMitreMapPattern{Kind: key, Pattern: rules[i].Pattern, Path: path, Err: err}
+			}
+		}
+		maps[kind] = rules
+	}
+	return maps, nil
+}
+
+// DefaultMitreMaps returns built-in extraction rules for sysmon-modular rule names and suricata ET metadata
+func DefaultMitreMaps() MitreMaps {
+	maps, err := ParseMitreMaps(defaultMitreMaps, "builtin")
+	if err != nil {
+		panic(err)
+	}
+	return maps
+}
+
+// LoadMitreMaps reads extraction rules from YAML file and merges them with built-in defaults
+// empty path returns defaults
+func LoadMitreMaps(path string) (MitreMaps, error) {
+	maps := DefaultMitreMaps()
+	if path == "" {
+		return maps, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	custom, err := ParseMitreMaps(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return maps.Merge(custom), nil
+}
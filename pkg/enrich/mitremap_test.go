@@ -0,0 +1,115 @@
+package enrich
+
+import (
+	"fmt"
+	"go-peek/pkg/mitremeerkat"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"testing"
+)
+
+func TestMitreMaps(t *testing.T) {
+	maps := DefaultMitreMaps()
+	tactics := meta.Tactics{
+		"TA0002": {ID: "TA0002", Name: "Execution", ShortName: "execution", Domain: "enterprise-attack"},
+		"TA0104": {ID: "TA0104", Name: "Execution", ShortName: "execution-ics", Domain: "ics-attack"},
+	}
+
+	suricata := &events.Suricata{Data: atomic.DynamicSuricataEve{
+		"event_type": "alert",
+		"alert": map[string]any{
+			"metadata": map[string]any{
+				"mitre_technique_id": []any{"T1059", "t1059.001"},
+				"mitre_tactic_id":    []any{"TA0002"},
+			},
+		},
+	}}
+	attack := maps.Extract(suricata, tactics)
+	if attack == nil || len(attack.Techniques) != 2 || attack.Techniques[1].ID != "T1059.001" {
+		t.Fatalf("unexpected suricata extraction %+v", attack)
+	}
+	if len(attack.Tactics) != 1 || attack.Tactics[0] != "TA0002" {
+		t.Fatalf("unexpected suricata tactics %+v", attack.Tactics)
+	}
+
+	// tactic metadata alone is still extracted
+	tacticOnly := &events.Suricata{Data: atomic.DynamicSuricataEve{
+		"event_type": "alert",
+		"alert": map[string]any{
+			"metadata": map[string]any{"mitre_tactic_id": []any{"TA0002", "TA0002"}},
+		},
+	}}
+	attack = maps.Extract(tacticOnly, tactics)
+	if attack == nil || len(attack.Techniques) != 0 || attack.ID != "" {
+		t.Fatalf("unexpected tactic only extraction %+v", attack)
+	}
+	attack.Update()
+	if len(attack.Tactics) != 1 || attack.Tactics[0] != "TA0002" {
+		t.Fatalf("unexpected tactic only tactics %+v", attack.Tactics)
+	}
+
+	sysmon := events.NewSysmon(atomic.DynamicWinlogbeat{
+		"rule": map[string]any{"name": "technique_id=T1055,technique_name=Process Injection"},
+	})
+	attack = maps.Extract(sysmon, tactics)
+	if attack == nil || attack.ID != "T1055" || attack.Name != "Process Injection" {
+		t.Fatalf("unexpected sysmon extraction %+v", attack)
+	}
+
+	custom, err := ParseMitreMaps([]byte(
+		"snoopy:\n  - field: cmd\n    pattern: 'mitre=(?P<technique>T\\d{4}):(?P<tactic>[a-z-]+)'\n",
+	), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	maps.Merge(custom)
+	snoopy := &events.Snoopy{Snoopy: atomic.Snoopy{Cmd: "wrap mitre=T1003:execution mitre=T1021:lateral-movement"}}
+	attack = maps.Extract(snoopy, tactics)
+	if attack == nil || len(attack.Techniques) != 2 {
+		t.Fatalf("unexpected snoopy extraction %+v", attack)
+	}
+	if tx := attack.Techniques[0].Tactics; len(tx) != 1 || tx[0] != "TA0002" {
+		t.Fatalf("tactic name not resolved %+v", tx)
+	}
+	if tx := attack.Techniques[1].Tactics; len(tx) != 1 || tx[0] != "lateral-movement" {
+		t.Fatalf("unknown tactic should be kept %+v", tx)
+	}
+	if maps.Extract(&events.Syslog{}, tactics) != nil {
+		t.Fatal("kind without rules should not extract")
+	}
+	if _, err := ParseMitreMaps([]byte("snoopy:\n  - field: cmd\n    pattern: '('\n"), "test"); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
+
+func TestEnrichExtractedTactics(t *testing.T) {
+	h := newTestHandler(t, newTestPersist(t))
+	h.AddSidMap(mitremeerkat.Mapping{SID: 2000001, ID: "T1046", Tactic: "discovery"})
+	decode := func(sid int) events.GameEvent {
+		event, err := h.Decode([]byte(fmt.Sprintf(`{"timestamp":"2022-04-20T10:00:00.000000+0000","event_type":"alert",`+
+			`"alert":{"signature_id":%d,"metadata":{"mitre_tactic_id":["TA0007"]}}}`, sid)), events.SuricataE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	// tactic only alert keeps tactic
+	asset, err := h.Enrich(decode(2000002))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := asset.MitreAttack; a == nil || a.ID != "" || len(a.Tactics) != 1 || a.Tactics[0] != "TA0007" {
+		t.Fatalf("tactic only alert lost tactic %+v", a)
+	}
+
+	// technique from SID map is merged with extracted tactic
+	asset, err = h.Enrich(decode(2000001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := asset.MitreAttack; a == nil || a.ID != "T1046" || len(a.Tactics) != 1 || a.Tactics[0] != "TA0007" {
+		t.Fatalf("sid technique not merged with extracted tactic %+v", a)
+	}
+}
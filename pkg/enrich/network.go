@@ -0,0 +1,271 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go-peek/pkg/models/meta"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ErrNetworkFormat struct {
+	Path string
+}
+
+func (e ErrNetworkFormat) Error() string {
+	return fmt.Sprintf("unsupported network table format %s, expected json, csv or yaml", e.Path)
+}
+
+type ErrNetworkRecord struct {
+	Path   string
+	Line   int
+	Reason string
+}
+
+func (e ErrNetworkRecord) Error() string {
+	return fmt.Sprintf("invalid network record in %s line %d: %s", e.Path, e.Line, e.Reason)
+}
+
+// prefixTable maps masked network address to segment for every prefix length in use
+type prefixTable struct {
+	// lengths are sorted from most to least specific
+	lengths []int
+	nets    map[int]map[string]*meta.Network
+}
+
+func (p *prefixTable) add(ipnet net.IPNet, n *meta.Network) {
+	if ipnet.IP == nil || ipnet.Mask == nil {
+		return
+	}
+	ones, _ := ipnet.Mask.Size()
+	if _, ok := p.nets[ones]; !ok {
+		p.nets[ones] = make(map[string]*meta.Network)
+		p.lengths = append(p.lengths, ones)
+		sort.Sort(sort.Reverse(sort.IntSlice(p.lengths)))
+	}
+	p.nets[ones][ipnet.IP.Mask(ipnet.Mask).String()] = n
+}
+
+func (p prefixTable) lookup(ip net.IP, bits int) (*meta.Network, bool) {
+	for _, ones := range p.lengths {
+		if n, ok := p.nets[ones][ip.Mask(net.CIDRMask(ones, bits)).String()]; ok {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// NetworkTable does longest prefix matching of IP addresses against exercise network segments
+// table is read-only after creation, so it is safe for concurrent use
+type NetworkTable struct {
+	v4 prefixTable
+	v6 prefixTable
+}
+
+// Len returns number of network segments in table
+func (t NetworkTable) Len() int {
+	var count int
+	for _, nets := range t.v4.nets {
+		count += len(nets)
+	}
+	for _, nets := range t.v6.nets {
+		count += len(nets)
+	}
+	return count
+}
+
+// Lookup returns the most specific segment that contains IP address
+func (t NetworkTable) Lookup(ip net.IP) (*meta.Network, bool) {
+	if ip == nil {
+		return nil, false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return t.v4.lookup(v4, 32)
+	}
+	return t.v6.lookup(ip, 128)
+}
+
+// Segment returns shorthand of the most specific segment that contains IP address
+func (t NetworkTable) Segment(ip net.IP) (*meta.NetSegment, *meta.Network) {
+	n, ok := t.Lookup(ip)
+	if !ok {
+		return nil, nil
+	}
+	v4, v6 := n.Shorthand()
+	if ip.To4() != nil {
+		return v4, n
+	}
+	return v6, n
+}
+
+func NewNetworkTable(networks []*meta.Network) *NetworkTable {
+	t := &NetworkTable{
+		v4: prefixTable{nets: make(map[int]map[string]*meta.Network)},
+		v6: prefixTable{nets: make(map[int]map[string]*meta.Network)},
+	}
+	for _, n := range networks {
+		if n == nil {
+			continue
+		}
+		if n.IPv4.IP != nil {
+			ipnet := net.IPNet{IP: n.IPv4.IP.To4(), Mask: n.IPv4.Mask}
+			if len(ipnet.Mask) == net.IPv6len {
+				ipnet.Mask = ipnet.Mask[12:]
+			}
+			t.v4.add(ipnet, n)
+		}
+		t.v6.add(n.IPv6, n)
+	}
+	return t
+}
+
+// LoadNetworks reads exercise network table from disk
+// JSON is expected to be in pandas to_json() export format, CSV and YAML use flat records
+func LoadNetworks(path string) ([]*meta.Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var obj meta.NetworkPandasExport
+		if err := json.NewDecoder(f).Decode(&obj); err != nil {
+			return nil, err
+		}
+		return obj.Extract(), nil
+	case ".csv":
+		return parseNetworkCSV(f, path)
+	case ".yaml", ".yml":
+		return parseNetworkYAML(f, path)
+	default:
+		return nil, ErrNetworkFormat{Path: path}
+	}
+}
+
+// networkRecord is flat network table entry used in CSV and YAML formats
+type networkRecord struct {
+	Name         string `yaml:"name"`
+	Abbreviation string `yaml:"abbreviation"`
+	VLAN         string `yaml:"vlan"`
+	IPv4         string `yaml:"ipv4"`
+	IPv6         string `yaml:"ipv6"`
+	Desc         string `yaml:"description"`
+	Whois        string `yaml:"whois"`
+	Team         string `yaml:"team"`
+	Zone         string `yaml:"zone"`
+	Assets       *bool  `yaml:"assets"`
+}
+
+func (r networkRecord) network(id int) (*meta.Network, error) {
+	n := &meta.Network{
+		ID:           id,
+		Name:         r.Name,
+		Abbreviation: r.Abbreviation,
+		VLAN:         r.VLAN,
+		Desc:         r.Desc,
+		Whois:        r.Whois,
+		Team:         r.Team,
+		Zone:         r.Zone,
+		Assets:       r.Team != "",
+	}
+	if r.Assets != nil {
+		n.Assets = *r.Assets
+	}
+	if r.IPv4 == "" && r.IPv6 == "" {
+		return nil, fmt.Errorf("segment %s has no ipv4 or ipv6 network", r.Name)
+	}
+	if r.IPv4 != "" {
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(r.IPv4))
+		if err != nil {
+			return nil, err
+		}
+		n.IPv4 = *ipnet
+	}
+	if r.IPv6 != "" {
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(r.IPv6))
+		if err != nil {
+			return nil, err
+		}
+		n.IPv6 = *ipnet
+	}
+	return n, nil
+}
+
+func parseNetworkYAML(r io.Reader, path string) ([]*meta.Network, error) {
+	var records []networkRecord
+	if err := yaml.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+	tx := make([]*meta.Network, 0, len(records))
+	for i, record := range records {
+		n, err := record.network(i)
+		if err != nil {
+			return nil, ErrNetworkRecord{Path: path, Line: i + 1, Reason: err.Error()}
+		}
+		tx = append(tx, n)
+	}
+	return tx, nil
+}
+
+// parseNetworkCSV expects a header row, column names match YAML keys and are case insensitive
+func parseNetworkCSV(r io.Reader, path string) ([]*meta.Network, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, col := range header {
+		columns[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	tx := make([]*meta.Network, 0)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(key string) string {
+			if i, ok := columns[key]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		record := networkRecord{
+			Name:         get("name"),
+			Abbreviation: get("abbreviation"),
+			VLAN:         get("vlan"),
+			IPv4:         get("ipv4"),
+			IPv6:         get("ipv6"),
+			Desc:         get("description"),
+			Whois:        get("whois"),
+			Team:         get("team"),
+			Zone:         get("zone"),
+		}
+		if raw := get("assets"); raw != "" {
+			val, err := strconv.ParseBool(raw)
+			if err != nil {
+				return nil, ErrNetworkRecord{Path: path, Line: line, Reason: err.Error()}
+			}
+			record.Assets = &val
+		}
+		n, err := record.network(len(tx))
+		if err != nil {
+			return nil, ErrNetworkRecord{Path: path, Line: line, Reason: err.Error()}
+		}
+		tx = append(tx, n)
+	}
+	return tx, nil
+}
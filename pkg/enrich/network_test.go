@@ -0,0 +1,46 @@
+package enrich
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const networkCSV = `Name,VLAN,IPv4,IPv6,Team,Zone,Assets
+Blue 01,101,10.1.0.0/16,2a07:1181:130:3601::/64,BT01,internal,
+Blue 01 DMZ,102,10.1.1.0/24,,BT01,dmz,
+Red,666,100.64.0.0/10,,red,,false
+`
+
+func TestNetworkTable(t *testing.T) {
+	networks, err := parseNetworkCSV(strings.NewReader(networkCSV), "test.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := NewNetworkTable(networks)
+	if table.Len() != 4 {
+		t.Fatalf("expected 4 prefixes, got %d", table.Len())
+	}
+	for _, tc := range []struct {
+		IP     string
+		Name   string
+		Assets bool
+	}{
+		{IP: "10.1.1.10", Name: "Blue 01 DMZ", Assets: true},
+		{IP: "10.1.2.10", Name: "Blue 01", Assets: true},
+		{IP: "2a07:1181:130:3601::10", Name: "Blue 01", Assets: true},
+		{IP: "100.64.3.4", Name: "Red", Assets: false},
+		{IP: "8.8.8.8"},
+	} {
+		segment, network := table.Segment(net.ParseIP(tc.IP))
+		if tc.Name == "" {
+			if segment != nil {
+				t.Fatalf("%s should not match, got %s", tc.IP, segment.Name)
+			}
+			continue
+		}
+		if segment == nil || segment.Name != tc.Name || network.Assets != tc.Assets {
+			t.Fatalf("%s expected %s, got %+v", tc.IP, tc.Name, segment)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package enrich
+
+import (
+	"fmt"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"net"
+	"strings"
+)
+
+type ErrPartitionKey struct{ Strategy string }
+
+func (e ErrPartitionKey) Error() string {
+	return fmt.Sprintf("invalid partition key strategy %s, expected default, host, ip, team or kind", e.Strategy)
+}
+
+// PartitionKey selects kafka message key for enriched events
+// messages with the same key end up in the same partition, so their order is kept
+type PartitionKey int
+
+const (
+	// PartitionKeyDefault keys only fast-tracked events by kind, other events are spread evenly
+	PartitionKeyDefault PartitionKey = iota
+	// PartitionKeyHost keys by asset host name, falling back to asset address
+	PartitionKeyHost
+	// PartitionKeyIP keys by asset address, falling back to connection source address
+	PartitionKeyIP
+	// PartitionKeyTeam keys by owning team of asset or connection peers
+	PartitionKeyTeam
+	// PartitionKeyKind keys by event kind
+	PartitionKeyKind
+)
+
+func (p PartitionKey) String() string {
+	switch p {
+	case PartitionKeyHost:
+		return "host"
+	case PartitionKeyIP:
+		return "ip"
+	case PartitionKeyTeam:
+		return "team"
+	case PartitionKeyKind:
+		return "kind"
+	default:
+		return "default"
+	}
+}
+
+// Key returns message key for enriched event
+// empty key means value is not known, caller should keep its default key
+func (p PartitionKey) Key(kind events.Atomic, asset *meta.GameAsset) string {
+	if p == PartitionKeyKind {
+		return kind.String()
+	}
+	if asset == nil {
+		return ""
+	}
+	switch p {
+	case PartitionKeyHost:
+		if asset.Host != "" {
+			return asset.Host
+		}
+		return ipKey(asset.IP)
+	case PartitionKeyIP:
+		if key := ipKey(asset.IP); key != "" || asset.Source == nil {
+			return key
+		}
+		return ipKey(asset.Source.IP)
+	case PartitionKeyTeam:
+		for _, item := range []*meta.Asset{&asset.Asset, asset.Source, asset.Destination} {
+			if item != nil && item.Team != "" {
+				return item.Team
+			}
+		}
+	}
+	return ""
+}
+
+func NewPartitionKey(raw string) (PartitionKey, error) {
+	switch strings.ToLower(raw) {
+	case "", "default":
+		return PartitionKeyDefault, nil
+	case "host":
+		return PartitionKeyHost, nil
+	case "ip":
+		return PartitionKeyIP, nil
+	case "team":
+		return PartitionKeyTeam, nil
+	case "kind":
+		return PartitionKeyKind, nil
+	default:
+		return PartitionKeyDefault, ErrPartitionKey{Strategy: raw}
+	}
+}
+
+func ipKey(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,43 @@
+package enrich
+
+import (
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"net"
+	"testing"
+)
+
+func TestPartitionKey(t *testing.T) {
+	asset := &meta.GameAsset{
+		Asset:       meta.Asset{IP: net.ParseIP("10.0.0.5")},
+		Source:      &meta.Asset{IP: net.ParseIP("10.0.0.1")},
+		Destination: &meta.Asset{Host: "dc01", Team: "blue01"},
+	}
+	for _, tc := range []struct {
+		raw, expected string
+	}{
+		{raw: "", expected: ""},
+		{raw: "host", expected: "10.0.0.5"},
+		{raw: "ip", expected: "10.0.0.5"},
+		{raw: "team", expected: "blue01"},
+		{raw: "kind", expected: "suricata"},
+	} {
+		p, err := NewPartitionKey(tc.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key := p.Key(events.SuricataE, asset); key != tc.expected {
+			t.Fatalf("%s strategy: expected key %q, got %q", p, tc.expected, key)
+		}
+	}
+	asset.Host = "ws01"
+	if key := PartitionKeyHost.Key(events.SuricataE, asset); key != "ws01" {
+		t.Fatalf("host strategy should prefer host name, got %q", key)
+	}
+	if key := PartitionKeyTeam.Key(events.SuricataE, nil); key != "" {
+		t.Fatalf("unknown asset should leave key empty, got %q", key)
+	}
+	if _, err := NewPartitionKey("zone"); err == nil {
+		t.Fatal("unknown strategy should fail")
+	}
+}
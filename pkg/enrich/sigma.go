@@ -0,0 +1,221 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/markuskont/go-sigma-rule-engine"
+)
+
+var ErrMissingSigmaPaths = errors.New("missing sigma ruleset paths")
+
+type ErrSigmaLoad struct {
+	Path string
+	Err  error
+}
+
+func (e ErrSigmaLoad) Error() string {
+	return fmt.Sprintf("sigma ruleset %s load fail %s", e.Path, e.Err)
+}
+
+func (e ErrSigmaLoad) Unwrap() error { return e.Err }
+
+type ErrSigmaRulesDropped struct {
+	Path   string
+	Active int
+	Parsed int
+}
+
+func (e ErrSigmaRulesDropped) Error() string {
+	return fmt.Sprintf("sigma ruleset %s parsed %d rules, fewer than %d active", e.Path, e.Parsed, e.Active)
+}
+
+// SigmaRulesets maps event kinds to parsed sigma rulesets
+type SigmaRulesets map[events.Atomic]sigma.Ruleset
+
+// SigmaStats is a parse summary of a single ruleset directory
+type SigmaStats struct {
+	SigmaConfig
+	Ok          int
+	Failed      int
+	Unsupported int
+	Total       int
+}
+
+// LoadSigmaRulesets parses all configured ruleset directories
+// entire load fails if any directory is broken, so a bad edit would not wipe active rules
+func LoadSigmaRulesets(configs []SigmaConfig) (SigmaRulesets, []SigmaStats, error) {
+	rulesets := make(SigmaRulesets)
+	stats := make([]SigmaStats, 0, len(configs))
+	for _, c := range configs {
+		ruleset, err := sigma.NewRuleset(sigma.Config{
+			Directory: []string{c.Path},
+		})
+		if err != nil {
+			return nil, stats, ErrSigmaLoad{Path: c.Path, Err: err}
+		}
+		rulesets[c.Kind] = *ruleset
+		stats = append(stats, SigmaStats{
+			SigmaConfig: c,
+			Ok:          ruleset.Ok,
+			Failed:      ruleset.Failed,
+			Unsupported: ruleset.Unsupported,
+			Total:       ruleset.Total,
+		})
+	}
+	return rulesets, stats, nil
+}
+
+// sigmaStore holds rulesets that can be swapped while events are being evaluated
+type sigmaStore struct{ value atomic.Value }
+
+func (s *sigmaStore) Load() SigmaRulesets {
+	rulesets, _ := s.value.Load().(SigmaRulesets)
+	return rulesets
+}
+
+func (s *sigmaStore) Store(rulesets SigmaRulesets) { s.value.Store(rulesets) }
+
+// SetSigma atomically replaces active sigma rulesets
+// events that are being evaluated will finish with old rules
+func (h *Handler) SetSigma(rulesets SigmaRulesets) *Handler {
+	if len(rulesets) == 0 {
+		rulesets = nil
+	}
+	h.sigma.Store(rulesets)
+	h.count(func(c *Counts) { c.SigmaReloads++ })
+	return h
+}
+
+// ReloadSigma parses rulesets from disk and swaps them in on success
+// individual rules may fail to parse like on startup, but currently active rules are kept
+// if a directory fails to load or yields fewer parsed rules, so a bad edit would not drop them
+func (h *Handler) ReloadSigma(configs []SigmaConfig) ([]SigmaStats, error) {
+	rulesets, stats, err := LoadSigmaRulesets(configs)
+	if err == nil {
+		active := h.sigma.Load()
+		for _, s := range stats {
+			if prev, ok := active[s.Kind]; ok && rulesets[s.Kind].Ok < prev.Ok {
+				err = ErrSigmaRulesDropped{Path: s.Path, Active: prev.Ok, Parsed: rulesets[s.Kind].Ok}
+				break
+			}
+		}
+	}
+	if err != nil {
+		h.count(func(c *Counts) { c.SigmaReloadErrs++ })
+		return stats, err
+	}
+	h.SetSigma(rulesets)
+	return stats, nil
+}
+
+// SigmaWatcher notifies about rule file changes in sigma ruleset directories
+// bursts of filesystem events are collapsed into a single notification
+type SigmaWatcher struct {
+	Errors chan error
+
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+	notify   chan struct{}
+}
+
+// Notify returns a channel that receives a value whenever rulesets should be reloaded
+func (s SigmaWatcher) Notify() <-chan struct{} { return s.notify }
+
+func (s *SigmaWatcher) Run(wg *sync.WaitGroup, ctx context.Context) error {
+	if wg == nil {
+		return ErrMissingWaitGroup
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer s.watcher.Close()
+
+		timer := time.NewTimer(s.debounce)
+		timer.Stop()
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-s.watcher.Events:
+				if !ok {
+					return
+				}
+				// new subdirectories need to be watched explicitly, fsnotify is not recursive
+				if event.Op&fsnotify.Create != 0 {
+					s.add(event.Name)
+				}
+				timer.Reset(s.debounce)
+			case err, ok := <-s.watcher.Errors:
+				if !ok {
+					return
+				}
+				s.sendErr(err)
+			case <-timer.C:
+				select {
+				case s.notify <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s SigmaWatcher) add(root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if err := s.watcher.Add(path); err != nil {
+				s.sendErr(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s SigmaWatcher) sendErr(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+	}
+}
+
+func NewSigmaWatcher(configs []SigmaConfig, debounce time.Duration) (*SigmaWatcher, error) {
+	if len(configs) == 0 {
+		return nil, ErrMissingSigmaPaths
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if debounce <= 0 {
+		debounce = 2 * time.Second
+	}
+	s := &SigmaWatcher{
+		Errors:   make(chan error, 10),
+		watcher:  watcher,
+		debounce: debounce,
+		notify:   make(chan struct{}, 1),
+	}
+	for _, c := range configs {
+		if err := watcher.Add(c.Path); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		s.add(c.Path)
+	}
+	return s, nil
+}
@@ -0,0 +1,143 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSigmaRule = `title: test rule %d
+id: 00000000-0000-0000-0000-00000000000%d
+logsource:
+  product: windows
+detection:
+  selection:
+    Image|endswith: '\whoami.exe'
+  condition: selection
+`
+
+func writeSigmaRule(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadSigma(t *testing.T) {
+	dir := t.TempDir()
+	writeSigmaRule(t, dir, "rule1.yml", fmt.Sprintf(testSigmaRule, 1, 1))
+	configs := []SigmaConfig{{Kind: events.SysmonE, Path: dir}}
+
+	h := &Handler{countsMu: &sync.Mutex{}, sigma: &sigmaStore{}}
+	active := func() int {
+		ruleset, ok := h.sigma.Load()[events.SysmonE]
+		if !ok {
+			return 0
+		}
+		return ruleset.Ok
+	}
+
+	if _, err := h.ReloadSigma(configs); err != nil {
+		t.Fatal(err)
+	}
+	if n := active(); n != 1 {
+		t.Fatalf("expected 1 active rule, got %d", n)
+	}
+
+	writeSigmaRule(t, dir, "rule2.yml", fmt.Sprintf(testSigmaRule, 2, 2))
+	if _, err := h.ReloadSigma(configs); err != nil {
+		t.Fatal(err)
+	}
+	if n := active(); n != 2 {
+		t.Fatalf("expected 2 active rules after reload, got %d", n)
+	}
+
+	// single broken rule is tolerated like on startup
+	writeSigmaRule(t, dir, "broken.yml", "detection: [selection\n")
+	stats, err := h.ReloadSigma(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := active(); n != 2 || len(stats) != 1 || stats[0].Failed != 1 {
+		t.Fatalf("expected 2 active rules and 1 failed, got %d active %+v", n, stats)
+	}
+
+	// edit that breaks a parsed rule is rejected
+	writeSigmaRule(t, dir, "rule2.yml", "detection: [selection\n")
+	_, err = h.ReloadSigma(configs)
+	var dropErr ErrSigmaRulesDropped
+	if !errors.As(err, &dropErr) || dropErr.Active != 2 || dropErr.Parsed != 1 {
+		t.Fatalf("expected dropped rules error, got %v", err)
+	}
+	if n := active(); n != 2 {
+		t.Fatalf("dropped rules should keep 2 active rules, got %d", n)
+	}
+
+	_, err = h.ReloadSigma([]SigmaConfig{{Kind: events.SysmonE, Path: filepath.Join(dir, "missing")}})
+	var loadErr ErrSigmaLoad
+	if !errors.As(err, &loadErr) || loadErr.Path != filepath.Join(dir, "missing") {
+		t.Fatalf("expected load error for missing directory, got %v", err)
+	}
+	if n := active(); n != 2 {
+		t.Fatalf("failed load should keep 2 active rules, got %d", n)
+	}
+	if h.counts.SigmaReloads != 3 || h.counts.SigmaReloadErrs != 2 {
+		t.Fatalf("unexpected reload counts %d ok %d failed", h.counts.SigmaReloads, h.counts.SigmaReloadErrs)
+	}
+}
+
+func TestSigmaWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	debounce := 200 * time.Millisecond
+	watcher, err := NewSigmaWatcher([]SigmaConfig{{Kind: events.SysmonE, Path: dir}}, debounce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	if err := watcher.Run(&wg, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// burst of edits, including a new subdirectory, collapses into one notification
+	for i := 0; i < 5; i++ {
+		writeSigmaRule(t, dir, fmt.Sprintf("rule%d.yml", i), fmt.Sprintf(testSigmaRule, i, i))
+		time.Sleep(debounce / 10)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-watcher.Notify():
+		t.Fatal("notified before debounce elapsed")
+	default:
+	}
+	select {
+	case <-watcher.Notify():
+	case <-time.After(5 * debounce):
+		t.Fatal("no notification after burst")
+	}
+	select {
+	case <-watcher.Notify():
+		t.Fatal("burst produced more than one notification")
+	case <-time.After(2 * debounce):
+	}
+
+	// files in new subdirectory are watched as well
+	writeSigmaRule(t, filepath.Join(dir, "sub"), "rule.yml", fmt.Sprintf(testSigmaRule, 9, 9))
+	select {
+	case <-watcher.Notify():
+	case <-time.After(5 * debounce):
+		t.Fatal("no notification for subdirectory change")
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,226 @@
+package enrich
+
+import (
+	"errors"
+	"fmt"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SuppressKeyKind = "kind"
+	SuppressKeyRule = "rule"
+	SuppressKeySrc  = "src"
+	SuppressKeyDst  = "dst"
+)
+
+// SuppressKeyFields is default suppression key
+var SuppressKeyFields = []string{SuppressKeyKind, SuppressKeyRule, SuppressKeySrc, SuppressKeyDst}
+
+var ErrMissingSuppressWindow = errors.New("missing suppression window")
+
+type ErrSuppressKeyField struct{ Field string }
+
+func (e ErrSuppressKeyField) Error() string {
+	return fmt.Sprintf("invalid suppression key field %s, expected one of %s",
+		e.Field, strings.Join(SuppressKeyFields, ", "))
+}
+
+// SuppressConfig is used as parameter when instanciating new Suppressor
+type SuppressConfig struct {
+	// Window is how long repeats of a forwarded alert are suppressed
+	Window time.Duration
+	// Fields select key components, defaults to SuppressKeyFields
+	Fields []string
+}
+
+func (c *SuppressConfig) Validate() error {
+	if c.Window <= 0 {
+		return ErrMissingSuppressWindow
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = SuppressKeyFields
+	}
+	for _, field := range c.Fields {
+		switch field {
+		case SuppressKeyKind, SuppressKeyRule, SuppressKeySrc, SuppressKeyDst:
+		default:
+			return ErrSuppressKeyField{Field: field}
+		}
+	}
+	return nil
+}
+
+// SuppressKey identifies repeats of the same alert, unused components are left empty
+type SuppressKey struct {
+	Kind string `json:"Kind,omitempty"`
+	Rule string `json:"Rule,omitempty"`
+	Src  string `json:"Src,omitempty"`
+	Dst  string `json:"Dst,omitempty"`
+}
+
+// SuppressSummary reports alerts that were withheld from emit topic since last summary
+type SuppressSummary struct {
+	SuppressKey
+
+	Timestamp  time.Time `json:"@timestamp"`
+	EventType  string    `json:"EventType"`
+	Suppressed uint      `json:"Suppressed"`
+	FirstSeen  time.Time `json:"FirstSeen"`
+	LastSeen   time.Time `json:"LastSeen"`
+	Window     string    `json:"Window"`
+}
+
+type suppressEntry struct {
+	start      time.Time
+	first      time.Time
+	last       time.Time
+	suppressed uint
+}
+
+// Suppressor deduplicates fast-tracked alerts
+// first alert for a key is forwarded, repeats within window are only counted
+// safe for concurrent use
+type Suppressor struct {
+	window time.Duration
+	fields map[string]bool
+
+	mu      *sync.Mutex
+	entries map[SuppressKey]*suppressEntry
+}
+
+// Key builds suppression key from enriched event
+func (s Suppressor) Key(kind events.Atomic, asset *meta.GameAsset) SuppressKey {
+	var key SuppressKey
+	if asset == nil {
+		return key
+	}
+	if s.fields[SuppressKeyKind] {
+		key.Kind = kind.String()
+	}
+	if s.fields[SuppressKeyRule] {
+		key.Rule = suppressRule(asset)
+	}
+	if s.fields[SuppressKeySrc] {
+		// events without connection info are attributed to reporting host
+		if asset.Source != nil {
+			key.Src = suppressAsset(asset.Source)
+		} else {
+			key.Src = suppressAsset(&asset.Asset)
+		}
+	}
+	if s.fields[SuppressKeyDst] && asset.Destination != nil {
+		key.Dst = suppressAsset(asset.Destination)
+	}
+	return key
+}
+
+// Allow reports if alert should be forwarded
+func (s *Suppressor) Allow(key SuppressKey, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || now.Sub(entry.start) >= s.window {
+		if ok && entry.suppressed > 0 {
+			// keep counting until summary is flushed, but start a new window
+			entry.start = now
+			return true
+		}
+		s.entries[key] = &suppressEntry{start: now}
+		return true
+	}
+	if entry.suppressed == 0 {
+		entry.first = now
+	}
+	entry.suppressed++
+	entry.last = now
+	return false
+}
+
+// Flush returns summaries for keys with suppressed alerts and resets their counters
+// keys with expired windows are forgotten
+func (s *Suppressor) Flush(now time.Time) []SuppressSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := make([]SuppressSummary, 0)
+	for key, entry := range s.entries {
+		if entry.suppressed > 0 {
+			tx = append(tx, SuppressSummary{
+				SuppressKey: key,
+				Timestamp:   now,
+				EventType:   "suppression summary",
+				Suppressed:  entry.suppressed,
+				FirstSeen:   entry.first,
+				LastSeen:    entry.last,
+				Window:      s.window.String(),
+			})
+			entry.suppressed = 0
+		}
+		if now.Sub(entry.start) >= s.window {
+			delete(s.entries, key)
+		}
+	}
+	sort.Slice(tx, func(i, j int) bool { return tx[i].Suppressed > tx[j].Suppressed })
+	return tx
+}
+
+// Len returns number of tracked keys
+func (s *Suppressor) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func NewSuppressor(c SuppressConfig) (*Suppressor, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Suppressor{
+		window:  c.Window,
+		fields:  make(map[string]bool, len(c.Fields)),
+		mu:      &sync.Mutex{},
+		entries: make(map[SuppressKey]*suppressEntry),
+	}
+	for _, field := range c.Fields {
+		s.fields[field] = true
+	}
+	return s, nil
+}
+
+// suppressRule identifies detection that produced the alert
+// sigma rule IDs take precedence, then signature ID or key from event data
+func suppressRule(asset *meta.GameAsset) string {
+	if len(asset.SigmaResults) > 0 {
+		ids := make([]string, 0, len(asset.SigmaResults))
+		for _, result := range asset.SigmaResults {
+			ids = append(ids, result.ID)
+		}
+		sort.Strings(ids)
+		return "sigma:" + strings.Join(ids, ",")
+	}
+	if data := asset.EventData; data != nil {
+		if data.ID != 0 {
+			return strconv.Itoa(data.ID)
+		}
+		return data.Key
+	}
+	if asset.MitreAttack != nil {
+		return asset.MitreAttack.Technique.ID
+	}
+	return ""
+}
+
+func suppressAsset(asset *meta.Asset) string {
+	switch {
+	case asset.Host != "":
+		return asset.Host
+	case asset.IP != nil:
+		return asset.IP.String()
+	}
+	return ""
+}
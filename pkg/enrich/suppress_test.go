@@ -0,0 +1,54 @@
+package enrich
+
+import (
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/models/meta"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSuppressor(t *testing.T) {
+	s, err := NewSuppressor(SuppressConfig{Window: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset := &meta.GameAsset{
+		EventData:   &meta.EventData{ID: 2024217},
+		Source:      &meta.Asset{IP: net.ParseIP("10.0.0.1")},
+		Destination: &meta.Asset{Host: "dc01"},
+	}
+	key := s.Key(events.SuricataE, asset)
+	if key != (SuppressKey{Kind: "suricata", Rule: "2024217", Src: "10.0.0.1", Dst: "dc01"}) {
+		t.Fatalf("unexpected key %+v", key)
+	}
+
+	start := time.Now()
+	if !s.Allow(key, start) {
+		t.Fatal("first alert should be forwarded")
+	}
+	for i := 1; i <= 10; i++ {
+		if s.Allow(key, start.Add(time.Duration(i)*time.Second)) {
+			t.Fatal("repeat within window should be suppressed")
+		}
+	}
+	other := key
+	other.Dst = "dc02"
+	if !s.Allow(other, start.Add(time.Second)) {
+		t.Fatal("different key should be forwarded")
+	}
+	if !s.Allow(key, start.Add(2*time.Minute)) {
+		t.Fatal("alert after window should be forwarded")
+	}
+
+	summaries := s.Flush(start.Add(2 * time.Minute))
+	if len(summaries) != 1 || summaries[0].Suppressed != 10 {
+		t.Fatalf("expected one summary with 10 suppressed alerts, got %+v", summaries)
+	}
+	if len(s.Flush(start.Add(5*time.Minute))) != 0 || s.Len() != 0 {
+		t.Fatal("expired keys should be forgotten after flush")
+	}
+	if _, err := NewSuppressor(SuppressConfig{Window: time.Minute, Fields: []string{"sid"}}); err == nil {
+		t.Fatal("invalid key field should fail")
+	}
+}
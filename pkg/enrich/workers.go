@@ -0,0 +1,99 @@
+package enrich
+
+import (
+	"errors"
+	"go-peek/pkg/models/consumer"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+var ErrMissingWorkerFunc = errors.New("missing worker handler func")
+
+// WorkerConfig is used as parameter when instanciating new WorkerPool
+type WorkerConfig struct {
+	// Workers is number of goroutines processing messages, defaults to 1
+	Workers int
+	// Ordered pins each input partition to a single worker, so events from a partition are processed in order
+	Ordered bool
+	// HandlerFunc is called by workers for every message
+	HandlerFunc func(*consumer.Message)
+}
+
+func (c *WorkerConfig) Validate() error {
+	if c.HandlerFunc == nil {
+		return ErrMissingWorkerFunc
+	}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	return nil
+}
+
+// WorkerPool distributes messages between enrichment workers
+// unordered pool shares a single queue, ordered pool has a queue per worker
+type WorkerPool struct {
+	queues  []chan *consumer.Message
+	ordered bool
+	fn      func(*consumer.Message)
+}
+
+// Send passes message to a worker, blocks if workers are busy
+func (p WorkerPool) Send(msg *consumer.Message) {
+	if !p.ordered {
+		p.queues[0] <- msg
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(msg.Source))
+	h.Write([]byte(strconv.FormatInt(msg.Partition, 10)))
+	p.queues[h.Sum32()%uint32(len(p.queues))] <- msg
+}
+
+// Run starts workers, workers exit when Close is called and queues are drained
+func (p WorkerPool) Run(wg *sync.WaitGroup) error {
+	if wg == nil {
+		return ErrMissingWaitGroup
+	}
+	for _, queue := range p.queues {
+		wg.Add(1)
+		go func(queue <-chan *consumer.Message) {
+			defer wg.Done()
+			for msg := range queue {
+				p.fn(msg)
+			}
+		}(queue)
+	}
+	return nil
+}
+
+// Close stops accepting new messages
+func (p WorkerPool) Close() {
+	closed := make(map[chan *consumer.Message]bool)
+	for _, queue := range p.queues {
+		if !closed[queue] {
+			close(queue)
+			closed[queue] = true
+		}
+	}
+}
+
+func NewWorkerPool(c WorkerConfig) (*WorkerPool, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	p := &WorkerPool{
+		queues:  make([]chan *consumer.Message, c.Workers),
+		ordered: c.Ordered,
+		fn:      c.HandlerFunc,
+	}
+	shared := make(chan *consumer.Message, c.Workers)
+	for i := range p.queues {
+		if c.Ordered {
+			p.queues[i] = make(chan *consumer.Message, 1)
+		} else {
+			p.queues[i] = shared
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,138 @@
+package enrich
+
+import (
+	"fmt"
+	"go-peek/pkg/mitremeerkat"
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/events"
+	"go-peek/pkg/providentia"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolOrdered(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen = make(map[int64][]int64)
+	)
+	pool, err := NewWorkerPool(WorkerConfig{
+		Workers: 4,
+		Ordered: true,
+		HandlerFunc: func(msg *consumer.Message) {
+			mu.Lock()
+			seen[msg.Partition] = append(seen[msg.Partition], msg.Offset)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	if err := pool.Run(&wg); err != nil {
+		t.Fatal(err)
+	}
+	for offset := int64(0); offset < 1000; offset++ {
+		pool.Send(&consumer.Message{Source: "events", Partition: offset % 3, Offset: offset})
+	}
+	pool.Close()
+	wg.Wait()
+
+	var total int
+	for partition, offsets := range seen {
+		total += len(offsets)
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Fatalf("partition %d out of order: %d after %d", partition, offsets[i], offsets[i-1])
+			}
+		}
+	}
+	if total != 1000 {
+		t.Fatalf("expected 1000 messages, got %d", total)
+	}
+}
+
+func TestHandlerConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	writeSigmaRule(t, dir, "rule1.yml", fmt.Sprintf(testSigmaRule, 1, 1))
+	rulesets, _, err := LoadSigmaRulesets([]SigmaConfig{{Kind: events.SysmonE, Path: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, newTestPersist(t))
+	h.SetSigma(rulesets)
+
+	start := time.Date(2022, 4, 20, 8, 0, 0, 0, time.UTC)
+	inputs := []struct {
+		kind events.Atomic
+		raw  string
+	}{
+		{kind: events.SuricataE, raw: `{"timestamp":"2022-04-20T10:00:00.000000+0000","event_type":"alert",` +
+			`"src_ip":"10.0.0.5","dest_ip":"10.0.0.10","alert":{"signature_id":2000001,"signature":"ET TEST"}}`},
+		{kind: events.SysmonE, raw: `{"@timestamp":"2022-04-20T10:00:00Z","winlog":{"channel":"Microsoft-Windows-Sysmon/Operational",` +
+			`"computer_name":"ws-01","event_id":1,"event_data":{"Image":"C:\\Windows\\System32\\whoami.exe","ProcessId":"42"}}}`},
+	}
+
+	// enrichment workers race inventory, SID map and ruleset updates
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				in := inputs[i%len(inputs)]
+				event, err := h.Decode([]byte(in.raw), in.kind)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := h.Enrich(event); err != nil {
+					t.Error(err)
+					return
+				}
+				h.Stats()
+				h.MissingKeys()
+			}
+		}()
+	}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			h.AddAsset(providentia.Record{
+				AnsibleName: "ws-01",
+				HostName:    "ws-01",
+				Addr:        net.ParseIP("10.0.0.5"),
+				Team:        fmt.Sprintf("blue%02d", i%3),
+				Updated:     start.Add(time.Duration(i) * time.Minute),
+			})
+			if i%10 == 0 {
+				h.RemoveAsset("10.0.0.5", start.Add(time.Duration(i)*time.Minute+time.Second))
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			reloaded, _, err := LoadSigmaRulesets([]SigmaConfig{{Kind: events.SysmonE, Path: dir}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			h.SetSigma(reloaded)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			h.AddSidMap(mitremeerkat.Mapping{SID: 2000001 + i%2, ID: "T1059"})
+			h.MissingSidMaps()
+		}
+	}()
+	wg.Wait()
+
+	if c := h.Stats(); c.Events != 800 || c.AssetPickups != 200 || c.Enrichment.SigmaMatches != 400 {
+		t.Fatalf("counts lost updates %+v", c)
+	}
+}
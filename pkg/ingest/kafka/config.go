@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"go-peek/pkg/kafkaauth"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,6 +17,11 @@ type Config struct {
 	OffsetMode    OffsetMode
 	Logger        *logrus.Logger
 	LogInterval   time.Duration
+	// AtLeastOnce attaches delivery handle to messages, offsets are marked only after it is released
+	// otherwise offsets are never marked, nothing is committed and restart resumes from previously committed offset or OffsetMode
+	AtLeastOnce bool
+	// Security holds TLS and SASL options for brokers
+	Security kafkaauth.Config
 }
 
 func NewDefaultConfig() *Config {
@@ -3,9 +3,11 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/models/consumer"
 	"go-peek/pkg/utils"
 
@@ -40,6 +42,7 @@ func NewConsumer(c *Config) (*Consumer, error) {
 			logInterval: c.LogInterval,
 			logger:      c.Logger,
 			name:        c.Name,
+			atLeastOnce: c.AtLeastOnce,
 		},
 		errs: utils.NewErrChan(100, fmt.Sprintf(
 			"kafka consumer for brokers %+v topics %+v",
@@ -62,6 +65,9 @@ func NewConsumer(c *Config) (*Consumer, error) {
 	}
 	obj.config.Version = version
 	obj.config.Consumer.Return.Errors = true
+	if err := c.Security.Apply(obj.config); err != nil {
+		return nil, err
+	}
 
 	group, err := sarama.NewConsumerGroup(c.Brokers, c.ConsumerGroup, obj.config)
 	if err != nil {
@@ -108,6 +114,7 @@ type handle struct {
 	messages    chan *consumer.Message
 	logInterval time.Duration
 	name        string
+	atLeastOnce bool
 	logger      *logrus.Logger
 }
 
@@ -163,6 +170,13 @@ func (c *handle) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.
 
 	logTick := time.NewTicker(c.logInterval)
 	first := time.NewTimer(10 * time.Second)
+	lag := metrics.ConsumerLag.WithLabelValues(c.name, claim.Topic(), strconv.Itoa(int(claim.Partition())))
+	var offsets *offsetTracker
+	if c.atLeastOnce {
+		offsets = newOffsetTracker(session, claim, metrics.ConsumerUnacked.WithLabelValues(
+			c.name, claim.Topic(), strconv.Itoa(int(claim.Partition())),
+		))
+	}
 loop:
 	for {
 		select {
@@ -176,7 +190,8 @@ loop:
 			if !ok {
 				break loop
 			}
-			c.messages <- &consumer.Message{
+			lag.Set(float64(claim.HighWaterMarkOffset() - msg.Offset - 1))
+			m := &consumer.Message{
 				Partition: int64(msg.Partition),
 				Data:      msg.Value,
 				Offset:    msg.Offset,
@@ -185,6 +200,10 @@ loop:
 				Key:       string(msg.Key),
 				Type:      consumer.Kafka,
 			}
+			if offsets != nil {
+				m.Ack = offsets.track(msg.Offset)
+			}
+			c.messages <- m
 		}
 	}
 	return nil
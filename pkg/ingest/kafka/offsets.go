@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"go-peek/pkg/models/consumer"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// offsetTracker marks claimed partition offsets only after all earlier messages are acknowledged
+// offsets are kept in consumption order, as partition offsets are not guaranteed to be contiguous
+// failed delivery leaves a gap that blocks further commits, so restart resumes from it
+type offsetTracker struct {
+	mu        sync.Mutex
+	session   sarama.ConsumerGroupSession
+	topic     string
+	partition int32
+
+	inflight []int64
+	acked    map[int64]bool
+	unacked  prometheus.Gauge
+}
+
+// track registers consumed offset and returns delivery handle for it
+func (t *offsetTracker) track(offset int64) *consumer.Ack {
+	t.mu.Lock()
+	t.inflight = append(t.inflight, offset)
+	t.unacked.Set(float64(len(t.inflight)))
+	t.mu.Unlock()
+	return consumer.NewAck(func() { t.ack(offset) })
+}
+
+func (t *offsetTracker) ack(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.acked[offset] = true
+	mark := int64(-1)
+	for len(t.inflight) > 0 && t.acked[t.inflight[0]] {
+		mark = t.inflight[0]
+		delete(t.acked, mark)
+		t.inflight = t.inflight[1:]
+	}
+	t.unacked.Set(float64(len(t.inflight)))
+	// partition may have been reassigned, new owner resumes from last commit
+	if mark < 0 || t.session.Context().Err() != nil {
+		return
+	}
+	t.session.MarkOffset(t.topic, t.partition, mark+1, "")
+}
+
+func newOffsetTracker(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+	unacked prometheus.Gauge,
+) *offsetTracker {
+	return &offsetTracker{
+		session:   session,
+		topic:     claim.Topic(),
+		partition: claim.Partition(),
+		inflight:  make([]int64, 0),
+		acked:     make(map[int64]bool),
+		unacked:   unacked,
+	}
+}
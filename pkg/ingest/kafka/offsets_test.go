@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type markSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *markSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.marked = append(s.marked, offset)
+}
+
+func (s *markSession) Context() context.Context { return context.Background() }
+
+func TestOffsetTracker(t *testing.T) {
+	session := &markSession{}
+	tracker := &offsetTracker{
+		session: session,
+		acked:   make(map[int64]bool),
+		unacked: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}),
+	}
+	// offsets on compacted topics have gaps
+	first, second, third := tracker.track(5), tracker.track(7), tracker.track(8)
+	third.Hold()
+
+	second.Done()
+	if len(session.marked) != 0 {
+		t.Fatalf("offset marked before earlier message was delivered: %v", session.marked)
+	}
+	first.Done()
+	if len(session.marked) != 1 || session.marked[0] != 8 {
+		t.Fatalf("expected commit up to offset 8, got %v", session.marked)
+	}
+	third.Done()
+	if len(session.marked) != 1 {
+		t.Fatalf("offset marked while output was still pending: %v", session.marked)
+	}
+	third.Done()
+	if last := session.marked[len(session.marked)-1]; last != 9 {
+		t.Fatalf("expected commit up to offset 9, got %d", last)
+	}
+}
@@ -0,0 +1,312 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"go-peek/pkg/kafkaauth"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	ErrSeekConflict = errors.New("seek time and relative offset are mutually exclusive")
+	ErrSeekNoTopics = errors.New("no topics to seek")
+)
+
+type ErrSeekFormat struct {
+	Item string
+}
+
+func (e ErrSeekFormat) Error() string {
+	return fmt.Sprintf("invalid seek offset %s, expected topic:partition:offset", e.Item)
+}
+
+type ErrSeekTopic struct {
+	Topic string
+}
+
+func (e ErrSeekTopic) Error() string {
+	return fmt.Sprintf("seek topic %s is not consumed", e.Topic)
+}
+
+type ErrSeekCommit struct {
+	Topic     string
+	Partition int32
+	Err       error
+}
+
+func (e ErrSeekCommit) Error() string {
+	return fmt.Sprintf("unable to commit seek offset for %s partition %d: %s", e.Topic, e.Partition, e.Err)
+}
+
+// Seek describes how consumer group offsets are repositioned before consumption starts
+// explicit partition offsets take precedence over time and relative seek
+type Seek struct {
+	// Time moves partitions to first message at or after timestamp
+	Time time.Time
+	// Offsets holds explicit offsets per topic and partition
+	Offsets map[string]map[int32]int64
+	// Relative moves committed offset forward, or back if negative
+	Relative int64
+	// Topics limits time and relative seek to these topics, all consumed topics are used if empty
+	Topics []string
+	// DryRun resolves offsets without committing them
+	DryRun bool
+}
+
+// Empty reports if seek would not move any partition
+func (s Seek) Empty() bool {
+	return s.Time.IsZero() && len(s.Offsets) == 0 && s.Relative == 0
+}
+
+func (s Seek) Validate() error {
+	if !s.Time.IsZero() && s.Relative != 0 {
+		return ErrSeekConflict
+	}
+	return nil
+}
+
+// SeekConfig is used as parameter for SeekConsumerGroup
+type SeekConfig struct {
+	Brokers       []string
+	ConsumerGroup string
+	// Topics are topics consumed by the group
+	Topics   []string
+	Security kafkaauth.Config
+	// OffsetMode is initial offset of consumer, used for partitions that group has not committed yet
+	OffsetMode OffsetMode
+	Seek       Seek
+}
+
+func (c SeekConfig) Validate() error {
+	if len(c.Topics) == 0 {
+		return ErrSeekNoTopics
+	}
+	consumed := make(map[string]bool, len(c.Topics))
+	for _, topic := range c.Topics {
+		consumed[topic] = true
+	}
+	for topic := range c.Seek.Offsets {
+		if !consumed[topic] {
+			return ErrSeekTopic{Topic: topic}
+		}
+	}
+	for _, topic := range c.Seek.Topics {
+		if !consumed[topic] {
+			return ErrSeekTopic{Topic: topic}
+		}
+	}
+	return c.Seek.Validate()
+}
+
+// SeekOffset is resolved position of a single partition
+type SeekOffset struct {
+	Topic     string
+	Partition int32
+	// Committed is current group offset, or resolved initial offset if group has not committed
+	Committed int64
+	Target    int64
+	// Oldest and Newest are partition bounds, target is clamped to them
+	Oldest, Newest int64
+}
+
+type SeekOffsets []SeekOffset
+
+// Keys is for implementing CSV header
+func (s SeekOffsets) Keys() []string {
+	return []string{"topic", "partition", "committed", "target", "oldest", "newest"}
+}
+
+// CSVFormat implements atomic.CSVFormatter
+func (s SeekOffsets) CSVFormat(header bool) [][]string {
+	tx := make([][]string, 0, len(s)+1)
+	if header {
+		tx = append(tx, s.Keys())
+	}
+	for _, item := range s {
+		tx = append(tx, []string{
+			item.Topic,
+			strconv.Itoa(int(item.Partition)),
+			strconv.FormatInt(item.Committed, 10),
+			strconv.FormatInt(item.Target, 10),
+			strconv.FormatInt(item.Oldest, 10),
+			strconv.FormatInt(item.Newest, 10),
+		})
+	}
+	return tx
+}
+
+// SeekConsumerGroup resolves seek targets for consumed partitions and commits them for consumer group
+// group members must not be active while offsets are committed
+// nothing is committed in dry run mode
+func SeekConsumerGroup(c SeekConfig) (SeekOffsets, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	config := sarama.NewConfig()
+	version, err := sarama.ParseKafkaVersion(Version)
+	if err != nil {
+		return nil, err
+	}
+	config.Version = version
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	switch c.OffsetMode {
+	case OffsetEarliest:
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case OffsetLatest:
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	}
+	if err := c.Security.Apply(config); err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(c.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	om, err := sarama.NewOffsetManagerFromClient(c.ConsumerGroup, client)
+	if err != nil {
+		return nil, err
+	}
+	defer om.Close()
+
+	limit := make(map[string]bool, len(c.Seek.Topics))
+	for _, topic := range c.Seek.Topics {
+		limit[topic] = true
+	}
+
+	tx := make(SeekOffsets, 0)
+	poms := make([]sarama.PartitionOffsetManager, 0)
+	for _, topic := range c.Topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+		explicit := c.Seek.Offsets[topic]
+		movable := len(limit) == 0 || limit[topic]
+		for _, partition := range partitions {
+			offset, ok := explicit[partition]
+			if !ok && !movable {
+				continue
+			}
+			if !ok && c.Seek.Time.IsZero() && c.Seek.Relative == 0 {
+				continue
+			}
+			item := SeekOffset{Topic: topic, Partition: partition}
+			if item.Oldest, err = client.GetOffset(topic, partition, sarama.OffsetOldest); err != nil {
+				return nil, err
+			}
+			if item.Newest, err = client.GetOffset(topic, partition, sarama.OffsetNewest); err != nil {
+				return nil, err
+			}
+			pom, err := om.ManagePartition(topic, partition)
+			if err != nil {
+				return nil, err
+			}
+			poms = append(poms, pom)
+			item.Committed, _ = pom.NextOffset()
+			// group has no commit yet, consumer would start from configured initial offset
+			switch item.Committed {
+			case sarama.OffsetNewest:
+				item.Committed = item.Newest
+			case sarama.OffsetOldest:
+				item.Committed = item.Oldest
+			}
+
+			switch {
+			case ok:
+				item.Target = offset
+			case !c.Seek.Time.IsZero():
+				ts := c.Seek.Time.UnixNano() / int64(time.Millisecond)
+				if item.Target, err = client.GetOffset(topic, partition, ts); err != nil {
+					return nil, err
+				}
+				// no messages after timestamp
+				if item.Target < 0 {
+					item.Target = item.Newest
+				}
+			default:
+				item.Target = item.Committed + c.Seek.Relative
+			}
+			if item.Target < item.Oldest {
+				item.Target = item.Oldest
+			}
+			if item.Target > item.Newest {
+				item.Target = item.Newest
+			}
+			if !c.Seek.DryRun {
+				// reset only moves back and mark only moves forward, one of them applies
+				pom.ResetOffset(item.Target, "")
+				pom.MarkOffset(item.Target, "")
+			}
+			tx = append(tx, item)
+		}
+	}
+	sort.Slice(tx, func(i, j int) bool {
+		if tx[i].Topic != tx[j].Topic {
+			return tx[i].Topic < tx[j].Topic
+		}
+		return tx[i].Partition < tx[j].Partition
+	})
+	if c.Seek.DryRun {
+		return tx, nil
+	}
+	om.Commit()
+	for _, pom := range poms {
+		select {
+		case err := <-pom.Errors():
+			if err != nil {
+				return tx, ErrSeekCommit{Topic: err.Topic, Partition: err.Partition, Err: err.Err}
+			}
+		default:
+		}
+	}
+	return tx, nil
+}
+
+// ParseSeekOffsets parses explicit offsets in topic:partition:offset format
+// topic names may contain colons, so last two fields are split off from the right
+func ParseSeekOffsets(items []string) (map[string]map[int32]int64, error) {
+	tx := make(map[string]map[int32]int64)
+	for _, item := range items {
+		bits := strings.Split(item, ":")
+		if len(bits) < 3 {
+			return nil, ErrSeekFormat{Item: item}
+		}
+		topic := strings.Join(bits[:len(bits)-2], ":")
+		partition, err := strconv.ParseInt(bits[len(bits)-2], 10, 32)
+		if err != nil || topic == "" {
+			return nil, ErrSeekFormat{Item: item}
+		}
+		offset, err := strconv.ParseInt(bits[len(bits)-1], 10, 64)
+		if err != nil || offset < 0 {
+			return nil, ErrSeekFormat{Item: item}
+		}
+		if tx[topic] == nil {
+			tx[topic] = make(map[int32]int64)
+		}
+		tx[topic][int32(partition)] = offset
+	}
+	return tx, nil
+}
+
+// ParseSeekTime parses RFC3339 timestamp, or duration that is subtracted from now
+// empty value returns zero time
+func ParseSeekTime(val string, now time.Time) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if ts, err := time.Parse(time.RFC3339, val); err == nil {
+		return ts, nil
+	}
+	ago, err := time.ParseDuration(strings.TrimPrefix(val, "-"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid seek time %s, expected RFC3339 timestamp or duration", val)
+	}
+	return now.Add(-ago), nil
+}
@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestSeekConsumerGroup(t *testing.T) {
+	since := time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)
+	ts := since.UnixNano() / int64(time.Millisecond)
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("events", 0, broker.BrokerID()).
+			SetLeader("events", 1, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, "peek", broker),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(t).
+			SetOffset("peek", "events", 0, 50, "", sarama.ErrNoError).
+			SetOffset("peek", "events", 1, -1, "", sarama.ErrNoError),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).
+			SetOffset("events", 0, sarama.OffsetOldest, 10).
+			SetOffset("events", 0, sarama.OffsetNewest, 100).
+			SetOffset("events", 0, ts, 40).
+			SetOffset("events", 1, sarama.OffsetOldest, 10).
+			SetOffset("events", 1, sarama.OffsetNewest, 100).
+			SetOffset("events", 1, ts, -1),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+		"ApiVersionsRequest":  sarama.NewMockApiVersionsResponse(t),
+	})
+
+	seekMode := func(s Seek, mode OffsetMode) SeekOffsets {
+		offsets, err := SeekConsumerGroup(SeekConfig{
+			Brokers:       []string{broker.Addr()},
+			ConsumerGroup: "peek",
+			Topics:        []string{"events"},
+			OffsetMode:    mode,
+			Seek:          s,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return offsets
+	}
+	seek := func(s Seek) SeekOffsets { return seekMode(s, OffsetLatest) }
+	check := func(name string, offsets SeekOffsets, targets ...int64) {
+		if len(offsets) != len(targets) {
+			t.Fatalf("%s: expected %d partitions, got %+v", name, len(targets), offsets)
+		}
+		for i, target := range targets {
+			if offsets[i].Target != target {
+				t.Fatalf("%s: partition %d expected target %d, got %+v", name, offsets[i].Partition, target, offsets[i])
+			}
+		}
+	}
+
+	// rewind past oldest offset is clamped, uncommitted partition starts from newest
+	check("relative", seek(Seek{Relative: -70, DryRun: true}), 10, 30)
+	for _, req := range broker.History() {
+		if _, ok := req.Request.(*sarama.OffsetCommitRequest); ok {
+			t.Fatal("dry run should not commit offsets")
+		}
+	}
+	// uncommitted partition of consumer that starts from beginning is relative to oldest
+	earliest := seekMode(Seek{Relative: 5, DryRun: true}, OffsetEarliest)
+	check("relative earliest", earliest, 55, 15)
+	if earliest[1].Committed != 10 {
+		t.Fatalf("uncommitted partition should report oldest offset, got %+v", earliest[1])
+	}
+	// partition without messages after timestamp resumes from newest
+	check("time", seek(Seek{Time: since}), 40, 100)
+	var committed bool
+	for _, req := range broker.History() {
+		if _, ok := req.Request.(*sarama.OffsetCommitRequest); ok {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Fatal("seek offsets were not committed")
+	}
+	explicit, err := ParseSeekOffsets([]string{"events:1:75"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("explicit", seek(Seek{Offsets: explicit, DryRun: true}), 75)
+
+	if _, err := ParseSeekOffsets([]string{"events:1"}); err == nil {
+		t.Fatal("offset without partition should not parse")
+	}
+	if _, err := SeekConsumerGroup(SeekConfig{Topics: []string{"events"}, Seek: Seek{Offsets: map[string]map[int32]int64{"other": {0: 1}}}}); err == nil {
+		t.Fatal("seek on topic that is not consumed should fail")
+	}
+}
@@ -1,83 +1,117 @@
 package mitre
 
+/*
+	mitre package loads MITRE ATT&CK STIX bundles into technique and tactic lookup tables
+	pinned release is embedded into binary, so enrichment works on air-gapped networks
+	run go generate to refresh embedded bundles after changing bundle/VERSION
+*/
+
+//go:generate bash ../../../scripts/fetch-mitre-attack.sh bundle
+
 import (
+	"bytes"
+	"compress/gzip"
+	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go-peek/pkg/models/meta"
 	"go-peek/pkg/utils"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
-const src = `https://raw.githubusercontent.com/mitre/cti/master/enterprise-attack/enterprise-attack.json`
+const srcTemplate = `https://raw.githubusercontent.com/mitre/cti/%s/%s/%s.json`
+
+//go:embed bundle
+var bundle embed.FS
+
+// Release is ATT&CK release tag of embedded bundles and download fallback
+var Release = func() string {
+	data, err := bundle.ReadFile("bundle/VERSION")
+	if err != nil {
+		return "master"
+	}
+	return strings.TrimSpace(string(data))
+}()
+
+var ErrMissingDump = errors.New("Mitre mapper missing dump file!")
+
+type ErrBundleParse struct {
+	Source string
+	Err    error
+}
+
+func (e ErrBundleParse) Error() string {
+	return fmt.Sprintf("MITRE bundle from %s parse fail %s", e.Source, e.Err)
+}
 
 type Config struct {
+	// EnterpriseDump caches downloaded enterprise bundle
 	EnterpriseDump string
-	MappingsDump   string
+	// IcsDump caches downloaded ICS bundle
+	IcsDump      string
+	MappingsDump string
+	// Bundles override embedded dataset, plain or gzipped STIX json
+	Bundles []string
+	// Download fetches pinned release if no bundle is available offline
+	Download bool
 }
 
 func (c *Config) Validate() error {
-	if c.EnterpriseDump == "" {
-		return errors.New("Mitre mapper missing dump file!")
+	if c.Download && (c.EnterpriseDump == "" || c.IcsDump == "") {
+		return ErrMissingDump
 	}
 	return nil
 }
 
+// Version identifies loaded ATT&CK collection
+type Version struct {
+	Name    string
+	Version string
+	Source  string
+	Count   int
+}
+
 type Mapper struct {
 	c        Config
 	Mappings meta.Techniques
+	Tactics  meta.Tactics
+	Versions []Version
 }
 
 func NewMapper(c Config) (*Mapper, error) {
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
-	var raw io.Reader
-	var dumpIsPresent bool
-	if utils.FileNotExists(c.EnterpriseDump) {
-		logrus.Tracef(
-			"Mitre enterprise dump %s does not exists, downloading from %s",
-			c.EnterpriseDump,
-			src,
-		)
-		response, err := http.Get(src)
-		if err != nil {
-			return nil, err
-		}
-		raw = response.Body
-		defer response.Body.Close()
-	} else {
-		logrus.Tracef("Loading %s", c.EnterpriseDump)
-		f, err := os.Open(c.EnterpriseDump)
-		if err != nil {
-			return nil, err
-		}
-		raw = f
-		dumpIsPresent = true
-		defer f.Close()
-	}
-	data, err := ioutil.ReadAll(raw)
+	sources, err := c.sources()
 	if err != nil {
 		return nil, err
 	}
-	if !dumpIsPresent {
-		f, err := os.Create(c.EnterpriseDump)
+	m := &Mapper{
+		c:        c,
+		Mappings: make(meta.Techniques),
+		Tactics:  make(meta.Tactics),
+		Versions: make([]Version, 0, len(sources)),
+	}
+	if len(sources) == 0 {
+		logrus.Warn("no MITRE ATT&CK bundle available, techniques will not be resolved")
+	}
+	for _, src := range sources {
+		version, err := m.parse(src.name, src.data)
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		if _, err := f.Write(data); err != nil {
-			return nil, err
-		}
-	}
-	mappings, err := parseEnterprise(data)
-	if err != nil {
-		return nil, err
+		logrus.Tracef("Loaded %s %s from %s", version.Name, version.Version, version.Source)
+		m.Versions = append(m.Versions, version)
 	}
 	if c.MappingsDump != "" {
 		f, err := os.Create(c.MappingsDump)
@@ -85,7 +119,7 @@ func NewMapper(c Config) (*Mapper, error) {
 			return nil, err
 		}
 		defer f.Close()
-		jsonMappings, err := json.Marshal(mappings)
+		jsonMappings, err := json.Marshal(m.Mappings)
 		if err != nil {
 			return nil, err
 		}
@@ -93,74 +127,115 @@ func NewMapper(c Config) (*Mapper, error) {
 			return nil, err
 		}
 	}
-	return &Mapper{Mappings: mappings, c: c}, nil
+	return m, nil
 }
 
-type RawKillChainPhase struct {
-	KillChainName string `json:"kill_chain_name"`
-	PhaseName     string `json:"phase_name"`
+type source struct {
+	name string
+	data []byte
 }
 
-type RawExternalReference struct {
-	SourceName  string `json:"source_name"`
-	ExternalID  string `json:"external_id"`
-	URL         string `json:"url"`
-	Description string `json:"description"`
+// sources picks first available dataset
+// override paths, then embedded bundles, then cached dumps, and download as last resort
+func (c Config) sources() ([]source, error) {
+	if len(c.Bundles) > 0 {
+		tx := make([]source, 0, len(c.Bundles))
+		for _, p := range c.Bundles {
+			logrus.Tracef("Loading %s", p)
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = decompress(data); err != nil {
+				return nil, err
+			}
+			tx = append(tx, source{name: p, data: data})
+		}
+		return tx, nil
+	}
+	embedded, err := fs.Glob(bundle, "bundle/*.json.gz")
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) > 0 {
+		tx := make([]source, 0, len(embedded))
+		for _, p := range embedded {
+			data, err := bundle.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = decompress(data); err != nil {
+				return nil, err
+			}
+			tx = append(tx, source{name: "embedded " + Release + " " + path.Base(p), data: data})
+		}
+		return tx, nil
+	}
+	tx := make([]source, 0, 2)
+	for _, d := range c.dumps() {
+		if d.path != "" && !utils.FileNotExists(d.path) {
+			logrus.Tracef("Loading %s", d.path)
+			data, err := ioutil.ReadFile(d.path)
+			if err != nil {
+				return nil, err
+			}
+			tx = append(tx, source{name: d.path, data: data})
+			continue
+		}
+		if !c.Download {
+			continue
+		}
+		src, err := download(d.domain, d.path)
+		if err != nil {
+			return nil, err
+		}
+		tx = append(tx, src)
+	}
+	return tx, nil
 }
 
-type RawEnterpriseItem struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Revoked bool   `json:"revoked"`
-	Type    string `json:"type"`
+type dump struct {
+	domain string
+	path   string
+}
 
-	KillChainPhases    []RawKillChainPhase    `json:"kill_chain_phases,omitempty"`
-	ExternalReferences []RawExternalReference `json:"external_references"`
+func (c Config) dumps() []dump {
+	return []dump{
+		{domain: "enterprise-attack", path: c.EnterpriseDump},
+		{domain: "ics-attack", path: c.IcsDump},
+	}
 }
 
-type RawEnterpriseJSON struct {
-	Objects []RawEnterpriseItem `json:"objects"`
+// download fetches pinned release of ATT&CK domain and caches it to dump path
+func download(domain, dump string) (source, error) {
+	src := fmt.Sprintf(srcTemplate, url.PathEscape(Release), domain, domain)
+	logrus.Tracef("Mitre dump %s does not exists, downloading from %s", dump, src)
+	response, err := http.Get(src)
+	if err != nil {
+		return source{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return source{}, fmt.Errorf("MITRE download from %s failed with %s", src, response.Status)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return source{}, err
+	}
+	if err := ioutil.WriteFile(dump, data, 0644); err != nil {
+		return source{}, err
+	}
+	return source{name: src, data: data}, nil
 }
 
-func parseEnterprise(input []byte) (meta.Techniques, error) {
-	var rawJSON RawEnterpriseJSON
-	if err := json.Unmarshal(input, &rawJSON); err != nil {
-		return nil, fmt.Errorf("MITRE enterprise from %s parse fail %s", src, err)
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
 	}
-	out := make(meta.Techniques)
-objects:
-	for _, obj := range rawJSON.Objects {
-		if obj.Type != "attack-pattern" {
-			continue objects
-		}
-		if obj.Revoked {
-			continue objects
-		}
-	references:
-		for _, ref := range obj.ExternalReferences {
-			if ref.SourceName != "mitre-attack" {
-				continue references
-			}
-			out[ref.ExternalID] = meta.Technique{
-				ID:   ref.ExternalID,
-				Name: obj.Name,
-				URL:  ref.URL,
-				Phases: func() []string {
-					if obj.KillChainPhases == nil || len(obj.KillChainPhases) == 0 {
-						return nil
-					}
-					tx := make([]string, 0)
-				phases:
-					for _, phase := range obj.KillChainPhases {
-						if phase.KillChainName != "mitre-attack" {
-							continue phases
-						}
-						tx = append(tx, phase.PhaseName)
-					}
-					return tx
-				}(),
-			}
-		}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
 	}
-	return out, nil
+	defer r.Close()
+	return io.ReadAll(r)
 }
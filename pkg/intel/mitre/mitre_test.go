@@ -1,18 +1,78 @@
 package mitre
 
 import (
+	"go-peek/pkg/models/meta"
+	"io/fs"
+	"path/filepath"
 	"testing"
-
-	"github.com/sirupsen/logrus"
 )
 
 func TestMitre(t *testing.T) {
-	logrus.SetLevel(logrus.TraceLevel)
-	_, err := NewMapper(Config{
-		EnterpriseDump: "/tmp/mitre-enterprise.json",
-		MappingsDump:   "/tmp/mitre-mappings.json",
+	m, err := NewMapper(Config{
+		Bundles:      []string{"testdata/attack.json"},
+		MappingsDump: filepath.Join(t.TempDir(), "mappings.json"),
 	})
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(m.Versions) != 1 || m.Versions[0].Version != "12.1" || m.Versions[0].Count != 3 {
+		t.Fatalf("unexpected versions %+v", m.Versions)
+	}
+	if _, ok := m.Mappings["T9999"]; ok {
+		t.Fatal("revoked technique should be skipped")
+	}
+	sub, ok := m.Mappings["T1059.001"]
+	if !ok || sub.Parent != "T1059" || len(sub.Tactics) != 1 || sub.Tactics[0] != "TA0002" {
+		t.Fatalf("unexpected sub-technique %+v", sub)
+	}
+	if ics := m.Mappings["T0807"]; len(ics.Tactics) != 1 || ics.Tactics[0] != "TA0104" {
+		t.Fatalf("unexpected ICS technique %+v", ics)
+	}
+	if m.Tactics["TA0002"].Name != "Execution" {
+		t.Fatalf("unexpected tactics %+v", m.Tactics)
+	}
+
+	attack := meta.MitreAttack{Techniques: []meta.Technique{{ID: "T1059.001"}}}
+	attack.Set(m.Mappings)
+	attack.Update()
+	if attack.ID != "T1059.001" || len(attack.Techniques) != 2 || attack.Techniques[1].ID != "T1059" {
+		t.Fatalf("sub-technique should roll up to parent, got %+v", attack.Techniques)
+	}
+	if len(attack.Tactics) != 1 || attack.Tactics[0] != "TA0002" {
+		t.Fatalf("unexpected tactic IDs %+v", attack.Tactics)
+	}
+}
+
+func TestEmbeddedBundle(t *testing.T) {
+	embedded, err := fs.Glob(bundle, "bundle/*.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// air-gapped deployments rely on both matrices being compiled in
+	for _, domain := range []string{"enterprise-attack", "ics-attack"} {
+		if _, err := fs.Stat(bundle, "bundle/"+domain+".json.gz"); err != nil {
+			t.Fatalf("%s bundle not embedded, run go generate ./pkg/intel/mitre/ and commit bundle/*.json.gz", domain)
+		}
+	}
+	m, err := NewMapper(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Versions) != len(embedded) {
+		t.Fatalf("expected %d embedded collections, got %+v", len(embedded), m.Versions)
+	}
+	for _, v := range m.Versions {
+		if v.Count == 0 {
+			t.Fatalf("embedded collection %s has no techniques", v.Source)
+		}
+	}
+	if _, ok := m.Mappings["T1059"]; !ok {
+		t.Fatal("embedded dataset is missing enterprise technique T1059")
+	}
+	if _, ok := m.Mappings["T0807"]; !ok {
+		t.Fatal("embedded dataset is missing ICS technique T0807")
+	}
+	if len(m.Tactics) == 0 {
+		t.Fatal("embedded dataset has no tactics")
+	}
 }
@@ -0,0 +1,159 @@
+package mitre
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/meta"
+	"strings"
+)
+
+// killChains maps ATT&CK kill chain names to their domains
+var killChains = map[string]string{
+	"mitre-attack":        "enterprise-attack",
+	"mitre-ics-attack":    "ics-attack",
+	"mitre-mobile-attack": "mobile-attack",
+}
+
+type RawKillChainPhase struct {
+	KillChainName string `json:"kill_chain_name"`
+	PhaseName     string `json:"phase_name"`
+}
+
+type RawExternalReference struct {
+	SourceName  string `json:"source_name"`
+	ExternalID  string `json:"external_id"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type RawEnterpriseItem struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Revoked    bool   `json:"revoked"`
+	Deprecated bool   `json:"x_mitre_deprecated"`
+	Type       string `json:"type"`
+
+	ShortName string   `json:"x_mitre_shortname,omitempty"`
+	Domains   []string `json:"x_mitre_domains,omitempty"`
+	Version   string   `json:"x_mitre_version,omitempty"`
+
+	RelationshipType string `json:"relationship_type,omitempty"`
+	SourceRef        string `json:"source_ref,omitempty"`
+	TargetRef        string `json:"target_ref,omitempty"`
+
+	KillChainPhases    []RawKillChainPhase    `json:"kill_chain_phases,omitempty"`
+	ExternalReferences []RawExternalReference `json:"external_references"`
+}
+
+// reference returns ATT&CK ID and URL of object
+func (r RawEnterpriseItem) reference() (RawExternalReference, bool) {
+	for _, ref := range r.ExternalReferences {
+		if _, ok := killChains[ref.SourceName]; ok && ref.ExternalID != "" {
+			return ref, true
+		}
+	}
+	return RawExternalReference{}, false
+}
+
+type RawEnterpriseJSON struct {
+	Objects []RawEnterpriseItem `json:"objects"`
+}
+
+// parse merges STIX bundle into mapper tables
+// tactics are resolved first, as techniques only refer to them by kill chain phase name
+func (m *Mapper) parse(src string, input []byte) (Version, error) {
+	var rawJSON RawEnterpriseJSON
+	if err := json.Unmarshal(input, &rawJSON); err != nil {
+		return Version{}, ErrBundleParse{Source: src, Err: err}
+	}
+	version := Version{Source: src}
+
+	// kill chain phase to tactic ID, phase names repeat between domains
+	phases := make(map[string]string)
+	for _, obj := range rawJSON.Objects {
+		if obj.Type != "x-mitre-tactic" || obj.Revoked || obj.Deprecated {
+			continue
+		}
+		ref, ok := obj.reference()
+		if !ok {
+			continue
+		}
+		tactic := meta.Tactic{
+			ID:        ref.ExternalID,
+			Name:      obj.Name,
+			ShortName: obj.ShortName,
+			URL:       ref.URL,
+		}
+		if len(obj.Domains) > 0 {
+			tactic.Domain = obj.Domains[0]
+		}
+		m.Tactics[tactic.ID] = tactic
+		for chain, domain := range killChains {
+			if tactic.Domain == "" || tactic.Domain == domain {
+				phases[chain+"/"+tactic.ShortName] = tactic.ID
+			}
+		}
+	}
+
+	// STIX object ID to technique ID for sub-technique relationships
+	stixIDs := make(map[string]string)
+	for _, obj := range rawJSON.Objects {
+		switch obj.Type {
+		case "x-mitre-collection":
+			version.Name = obj.Name
+			version.Version = obj.Version
+			continue
+		case "attack-pattern":
+		default:
+			continue
+		}
+		if obj.Revoked || obj.Deprecated {
+			continue
+		}
+		ref, ok := obj.reference()
+		if !ok {
+			continue
+		}
+		technique := meta.Technique{
+			ID:   ref.ExternalID,
+			Name: obj.Name,
+			URL:  ref.URL,
+		}
+		for _, phase := range obj.KillChainPhases {
+			if _, ok := killChains[phase.KillChainName]; !ok {
+				continue
+			}
+			technique.Phases = append(technique.Phases, phase.PhaseName)
+			if id, ok := phases[phase.KillChainName+"/"+phase.PhaseName]; ok {
+				technique.Tactics = append(technique.Tactics, id)
+			}
+		}
+		// fallback for bundles without relationship objects
+		if idx := strings.Index(technique.ID, "."); idx > 0 {
+			technique.Parent = technique.ID[:idx]
+		}
+		stixIDs[obj.ID] = technique.ID
+		m.Mappings[technique.ID] = technique
+		version.Count++
+	}
+
+	for _, obj := range rawJSON.Objects {
+		if obj.Type != "relationship" || obj.RelationshipType != "subtechnique-of" || obj.Revoked {
+			continue
+		}
+		child, ok := stixIDs[obj.SourceRef]
+		if !ok {
+			continue
+		}
+		parent, ok := stixIDs[obj.TargetRef]
+		if !ok {
+			continue
+		}
+		technique := m.Mappings[child]
+		technique.Parent = parent
+		m.Mappings[child] = technique
+	}
+	if version.Name == "" {
+		version.Name = src
+	}
+	return version, nil
+}
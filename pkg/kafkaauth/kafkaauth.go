@@ -0,0 +1,150 @@
+package kafkaauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+var (
+	ErrMissingCredentials = errors.New("SASL requires user and password")
+	ErrMissingKeyPair     = errors.New("TLS client certificate and key must be set together")
+)
+
+type ErrMechanism struct {
+	Mechanism string
+}
+
+func (e ErrMechanism) Error() string {
+	return fmt.Sprintf(
+		"unsupported SASL mechanism %s, use %s, %s or %s",
+		e.Mechanism, MechanismPlain, MechanismSCRAMSHA256, MechanismSCRAMSHA512,
+	)
+}
+
+type ErrCA struct {
+	Path string
+}
+
+func (e ErrCA) Error() string {
+	return fmt.Sprintf("no PEM certificates found in CA file %s", e.Path)
+}
+
+// TLSConfig holds broker connection encryption options
+type TLSConfig struct {
+	Enabled bool
+	// CA is PEM bundle for verifying brokers, system pool is used if empty
+	CA string
+	// Cert and Key enable client certificate authentication
+	Cert string
+	Key  string
+	// SkipVerify disables broker certificate verification, only meant for lab setups
+	SkipVerify bool
+}
+
+// SASLConfig holds broker authentication options, empty mechanism disables SASL
+type SASLConfig struct {
+	Mechanism string
+	User      string
+	Password  string
+}
+
+// Config is kafka security options shared by consumers and producers
+type Config struct {
+	TLS  TLSConfig
+	SASL SASLConfig
+}
+
+func (c Config) Validate() error {
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return ErrMissingKeyPair
+	}
+	switch c.mechanism() {
+	case "":
+		return nil
+	case MechanismPlain, MechanismSCRAMSHA256, MechanismSCRAMSHA512:
+	default:
+		return ErrMechanism{Mechanism: c.SASL.Mechanism}
+	}
+	if c.SASL.User == "" || c.SASL.Password == "" {
+		return ErrMissingCredentials
+	}
+	return nil
+}
+
+// Apply sets up security options in sarama config
+func (c Config) Apply(sc *sarama.Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.TLS.Enabled {
+		tc, err := c.TLS.config()
+		if err != nil {
+			return err
+		}
+		sc.Net.TLS.Enable = true
+		sc.Net.TLS.Config = tc
+	}
+	mechanism := c.mechanism()
+	if mechanism == "" {
+		return nil
+	}
+	sc.Net.SASL.Enable = true
+	sc.Net.SASL.Handshake = true
+	sc.Net.SASL.User = c.SASL.User
+	sc.Net.SASL.Password = c.SASL.Password
+	sc.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+	switch mechanism {
+	case MechanismSCRAMSHA256:
+		sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return newSCRAMClient(scram.SHA256)
+		}
+	case MechanismSCRAMSHA512:
+		sc.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return newSCRAMClient(scram.SHA512)
+		}
+	}
+	return nil
+}
+
+func (c Config) mechanism() string {
+	return strings.ToUpper(strings.TrimSpace(c.SASL.Mechanism))
+}
+
+func (t TLSConfig) config() (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.SkipVerify,
+	}
+	if t.CA != "" {
+		data, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, ErrCA{Path: t.CA}
+		}
+		tc.RootCAs = pool
+	}
+	if t.Cert != "" {
+		pair, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{pair}
+	}
+	return tc, nil
+}
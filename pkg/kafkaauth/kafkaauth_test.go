@@ -0,0 +1,116 @@
+package kafkaauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// handshakeReporter keeps TLS handshake failures of stand-in broker from failing the test
+type handshakeReporter struct {
+	*testing.T
+}
+
+func (r handshakeReporter) Error(args ...interface{})                 {}
+func (r handshakeReporter) Errorf(format string, args ...interface{}) {}
+
+// newTLSBroker starts stand-in broker behind TLS listener, returns its address and path to CA bundle
+func newTLSBroker(t *testing.T, reporter sarama.TestReporter) (*sarama.MockBroker, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "peek test broker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := sarama.NewMockBrokerListener(reporter, 1, listener)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()),
+	})
+	return broker, ca
+}
+
+func connect(addr string, c Config) error {
+	sc := sarama.NewConfig()
+	sc.Metadata.Retry.Max = 0
+	sc.Net.DialTimeout = time.Second
+	if err := c.Apply(sc); err != nil {
+		return err
+	}
+	client, err := sarama.NewClient([]string{addr}, sc)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func TestTLS(t *testing.T) {
+	broker, ca := newTLSBroker(t, t)
+	defer broker.Close()
+	if err := connect(broker.Addr(), Config{TLS: TLSConfig{Enabled: true, CA: ca}}); err != nil {
+		t.Fatalf("TLS connection with CA bundle failed: %s", err)
+	}
+	if err := connect(broker.Addr(), Config{TLS: TLSConfig{Enabled: true, SkipVerify: true}}); err != nil {
+		t.Fatalf("TLS connection without verification failed: %s", err)
+	}
+
+	untrusted, _ := newTLSBroker(t, handshakeReporter{t})
+	defer untrusted.Close()
+	if err := connect(untrusted.Addr(), Config{TLS: TLSConfig{Enabled: true, CA: ca}}); err == nil {
+		t.Fatal("broker signed by unknown CA should be rejected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, c := range []Config{
+		{SASL: SASLConfig{Mechanism: "GSSAPI", User: "peek", Password: "secret"}},
+		{SASL: SASLConfig{Mechanism: MechanismSCRAMSHA512, User: "peek"}},
+		{TLS: TLSConfig{Enabled: true, Cert: "client.pem"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("invalid config %+v passed validation", c)
+		}
+	}
+	sc := sarama.NewConfig()
+	c := Config{SASL: SASLConfig{Mechanism: "scram-sha-256", User: "peek", Password: "secret"}}
+	if err := c.Apply(sc); err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Net.SASL.Enable || sc.Net.SASL.Mechanism != sarama.SASLTypeSCRAMSHA256 || sc.Net.SASL.SCRAMClientGeneratorFunc == nil {
+		t.Fatalf("SCRAM not set up in sarama config: %+v", sc.Net.SASL)
+	}
+}
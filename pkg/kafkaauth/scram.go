@@ -0,0 +1,38 @@
+package kafkaauth
+
+import (
+	"github.com/xdg-go/scram"
+)
+
+// scramClient adapts xdg-go/scram conversation to sarama.SCRAMClient
+// user name and password are normalized with SASLprep, servers asking for fewer than 4096 iterations are rejected
+type scramClient struct {
+	hash  scram.HashGeneratorFcn
+	nonce scram.NonceGeneratorFcn
+
+	conversation *scram.ClientConversation
+}
+
+func (s *scramClient) Begin(userName, password, authzID string) error {
+	client, err := s.hash.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	if s.nonce != nil {
+		client = client.WithNonceGenerator(s.nonce)
+	}
+	s.conversation = client.NewConversation()
+	return nil
+}
+
+func (s *scramClient) Step(challenge string) (string, error) {
+	return s.conversation.Step(challenge)
+}
+
+func (s *scramClient) Done() bool {
+	return s.conversation.Done()
+}
+
+func newSCRAMClient(fn scram.HashGeneratorFcn) *scramClient {
+	return &scramClient{hash: fn}
+}
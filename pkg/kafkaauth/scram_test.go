@@ -0,0 +1,59 @@
+package kafkaauth
+
+import (
+	"testing"
+
+	"github.com/xdg-go/scram"
+)
+
+// TestSCRAMClient replays SCRAM-SHA-256 example from RFC 7677
+func TestSCRAMClient(t *testing.T) {
+	client := newSCRAMClient(scram.SHA256)
+	client.nonce = func() string { return "rOprNGfwEbeRWgbNEkqO" }
+	steps := []struct{ challenge, response string }{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{
+			"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	}
+	if err := client.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range steps {
+		if client.Done() {
+			t.Fatalf("conversation done early at step %d", i)
+		}
+		response, err := client.Step(step.challenge)
+		if err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+		if response != step.response {
+			t.Fatalf("step %d: expected %s, got %s", i, step.response, response)
+		}
+	}
+	if !client.Done() {
+		t.Fatal("conversation should be done")
+	}
+
+	for name, challenges := range map[string][]string{
+		"forged server signature": {steps[1].challenge, "v=AAAA"},
+		"low iteration count":     {"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=1024"},
+		"mandatory extension":     {"m=ext,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"},
+	} {
+		if err := client.Begin("user", "pencil", ""); err != nil {
+			t.Fatal(err)
+		}
+		client.Step("")
+		var err error
+		for _, challenge := range challenges {
+			if _, err = client.Step(challenge); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Fatalf("%s should be rejected", name)
+		}
+	}
+}
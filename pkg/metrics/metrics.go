@@ -0,0 +1,181 @@
+package metrics
+
+/*
+	metrics package holds a shared prometheus registry for all subcommands
+	collectors are package level, so any module can update them without passing handles around
+*/
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const namespace = "peek"
+
+var ErrMissingListenAddr = errors.New("missing metrics listen address")
+
+// Registry is shared by all collectors in this package
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	EventsIn = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_in_total",
+		Help:      "Events received per command and event kind.",
+	}, []string{"command", "kind"})
+
+	EventsOut = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_out_total",
+		Help:      "Events sent per command and event kind.",
+	}, []string{"command", "kind"})
+
+	ParseErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "parse_errors_total",
+		Help:      "Events that could not be parsed per command and event kind.",
+	}, []string{"command", "kind"})
+
+	SigmaMatches = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sigma_matches_total",
+		Help:      "Events that matched at least one sigma rule per event kind.",
+	}, []string{"kind"})
+
+	SigmaCorrelations = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sigma_correlations_total",
+		Help:      "Synthetic alerts from sigma correlation rules per event kind.",
+	}, []string{"kind"})
+
+	MitreEmits = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mitre_emits_total",
+		Help:      "Events sent to emit topic per event kind.",
+	}, []string{"kind"})
+
+	SuppressedEmits = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "suppressed_emits_total",
+		Help:      "Repeated alerts withheld from emit topic per event kind.",
+	}, []string{"kind"})
+
+	Assets = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "assets",
+		Help:      "Number of asset lookup keys known to enrichment.",
+	})
+
+	MissingLookups = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "missing_asset_lookups",
+		Help:      "Number of host keys that did not resolve to an asset.",
+	})
+
+	MitreDataset = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mitre_dataset_info",
+		Help:      "Loaded MITRE ATT&CK collections, value is number of techniques.",
+	}, []string{"name", "version", "source"})
+
+	ProducerMessages = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "producer_messages_total",
+		Help:      "Messages passed to kafka producer per topic.",
+	}, []string{"topic"})
+
+	ProducerErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "producer_errors_total",
+		Help:      "Kafka producer errors per topic.",
+	}, []string{"topic"})
+
+	ProducerDelivered = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "producer_delivered_total",
+		Help:      "Messages acknowledged by kafka brokers per topic. Only counted in at-least-once mode.",
+	}, []string{"topic"})
+
+	DeadLetters = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_letters_total",
+		Help:      "Messages sent to dead-letter topic per command and failure reason.",
+	}, []string{"command", "reason"})
+
+	ConsumerUnacked = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_unacked",
+		Help:      "Consumed messages per partition whose outputs are not yet delivered, offsets are committed up to first of them.",
+	}, []string{"consumer", "topic", "partition"})
+
+	ConsumerLag = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_lag",
+		Help:      "Difference between partition high water mark and last consumed offset.",
+	}, []string{"consumer", "topic", "partition"})
+
+	ElasticBulk = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "elastic_bulk",
+		Help:      "Cumulative elastic bulk processor stats.",
+	}, []string{"stat"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Serve exposes registry in prometheus text format on /metrics until context is cancelled
+func Serve(ctx context.Context, wg *sync.WaitGroup, addr string, logger *logrus.Logger) error {
+	if addr == "" {
+		return ErrMissingListenAddr
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	// listen before returning, so address errors reach the caller
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed && logger != nil {
+			logger.WithField("err", err).Error("metrics server")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	return nil
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestServeListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := Serve(context.Background(), nil, ln.Addr().String(), nil); err == nil {
+		t.Fatal("address in use should be reported")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	if err := Serve(ctx, &wg, "127.0.0.1:0", nil); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	wg.Wait()
+}
@@ -2,6 +2,7 @@ package atomic
 
 import (
 	"fmt"
+	"net"
 	"strings"
 	"time"
 )
@@ -23,6 +24,14 @@ func (c Cef) Content() map[string]string {
 	return c.Extensions
 }
 
+// GetIP parses extension value as IP address, CEF values are not quoted
+func (c Cef) GetIP(key string) net.IP {
+	if val, ok := c.Extensions[key]; ok {
+		return net.ParseIP(strings.TrimSpace(val))
+	}
+	return nil
+}
+
 // Time implements atomic.Event
 // Timestamp in event, should default to time.Time{} so time.IsZero() could be used to verify success
 func (c Cef) Time() time.Time { return time.Time{} }
@@ -3,8 +3,6 @@ package atomic
 import (
 	"net"
 	"time"
-
-	"go-peek/pkg/models/fields"
 )
 
 type MazeRunner struct {
@@ -13,21 +11,11 @@ type MazeRunner struct {
 }
 
 func (m MazeRunner) GetSrcIP() net.IP {
-	if val, ok := m.Cef.Extensions["src"]; ok {
-		if ip, err := fields.ParseStringIP(val); err == nil {
-			return ip
-		}
-	}
-	return nil
+	return m.Cef.GetIP("src")
 }
 
 func (m MazeRunner) GetDstIP() net.IP {
-	if val, ok := m.Cef.Extensions["dst"]; ok {
-		if ip, err := fields.ParseStringIP(val); err == nil {
-			return ip
-		}
-	}
-	return nil
+	return m.Cef.GetIP("dst")
 }
 
 // Time implements atomic.Event
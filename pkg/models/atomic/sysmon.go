@@ -0,0 +1,165 @@
+package atomic
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	SysmonChannel = "Microsoft-Windows-Sysmon/Operational"
+
+	SysmonProcessCreate     = 1
+	SysmonNetworkConnection = 3
+	SysmonDNSQuery          = 22
+)
+
+// SysmonProcess holds image and command line of a process that is referenced in sysmon event
+type SysmonProcess struct {
+	Image       string `json:"Image,omitempty"`
+	CommandLine string `json:"CommandLine,omitempty"`
+	GUID        string `json:"GUID,omitempty"`
+	PID         int    `json:"PID,omitempty"`
+}
+
+// SysmonNetwork holds connection info from network connection events
+type SysmonNetwork struct {
+	Protocol  string `json:"Protocol,omitempty"`
+	Initiated bool   `json:"Initiated"`
+	SrcIP     net.IP `json:"SrcIP,omitempty"`
+	SrcPort   int    `json:"SrcPort,omitempty"`
+	DstIP     net.IP `json:"DstIP,omitempty"`
+	DstPort   int    `json:"DstPort,omitempty"`
+	DstHost   string `json:"DstHost,omitempty"`
+}
+
+// SysmonDNS holds query info from DNS query events
+type SysmonDNS struct {
+	Query   string `json:"Query,omitempty"`
+	Results string `json:"Results,omitempty"`
+	Status  string `json:"Status,omitempty"`
+}
+
+// Sysmon is a typed subset of sysmon event data as shipped by winlogbeat
+// only fields that are needed for asset lookups and quick filtering are extracted
+type Sysmon struct {
+	EventID int               `json:"EventID"`
+	User    string            `json:"User,omitempty"`
+	Process SysmonProcess     `json:"Process"`
+	Parent  *SysmonProcess    `json:"Parent,omitempty"`
+	Hashes  map[string]string `json:"Hashes,omitempty"`
+	Network *SysmonNetwork    `json:"Network,omitempty"`
+	DNS     *SysmonDNS        `json:"DNS,omitempty"`
+}
+
+// IsSysmon reports if winlogbeat event originates from sysmon channel
+func (d DynamicWinlogbeat) IsSysmon() bool {
+	return d.Source() == SysmonChannel
+}
+
+// EventID returns windows event ID from winlog section
+func (d DynamicWinlogbeat) EventID() int {
+	w := d.GetWinlog()
+	if w == nil {
+		return 0
+	}
+	return anyToInt(w["event_id"])
+}
+
+// EventData returns winlog.event_data section
+func (d DynamicWinlogbeat) EventData() map[string]interface{} {
+	w := d.GetWinlog()
+	if w == nil {
+		return nil
+	}
+	if val, ok := w["event_data"].(map[string]interface{}); ok {
+		return val
+	}
+	return nil
+}
+
+// Sysmon extracts typed sysmon fields from event data
+// returns nil if event data section is missing
+func (d DynamicWinlogbeat) Sysmon() *Sysmon {
+	data := d.EventData()
+	if data == nil {
+		return nil
+	}
+	str := func(key string) string {
+		if val, ok := data[key].(string); ok {
+			return val
+		}
+		return ""
+	}
+	s := &Sysmon{
+		EventID: d.EventID(),
+		User:    str("User"),
+		Process: SysmonProcess{
+			Image:       str("Image"),
+			CommandLine: str("CommandLine"),
+			GUID:        str("ProcessGuid"),
+			PID:         anyToInt(data["ProcessId"]),
+		},
+		Hashes: parseSysmonHashes(str("Hashes")),
+	}
+	if parent := str("ParentImage"); parent != "" {
+		s.Parent = &SysmonProcess{
+			Image:       parent,
+			CommandLine: str("ParentCommandLine"),
+			GUID:        str("ParentProcessGuid"),
+			PID:         anyToInt(data["ParentProcessId"]),
+		}
+	}
+	switch s.EventID {
+	case SysmonNetworkConnection:
+		s.Network = &SysmonNetwork{
+			Protocol:  str("Protocol"),
+			Initiated: strings.EqualFold(str("Initiated"), "true"),
+			SrcIP:     net.ParseIP(str("SourceIp")),
+			SrcPort:   anyToInt(data["SourcePort"]),
+			DstIP:     net.ParseIP(str("DestinationIp")),
+			DstPort:   anyToInt(data["DestinationPort"]),
+			DstHost:   str("DestinationHostname"),
+		}
+	case SysmonDNSQuery:
+		s.DNS = &SysmonDNS{
+			Query:   str("QueryName"),
+			Results: str("QueryResults"),
+			Status:  str("QueryStatus"),
+		}
+	}
+	return s
+}
+
+// parseSysmonHashes splits sysmon hash string, e.g. SHA1=...,MD5=...,IMPHASH=...
+func parseSysmonHashes(raw string) map[string]string {
+	if raw == "" {
+		return nil
+	}
+	out := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		bits := strings.SplitN(item, "=", 2)
+		if len(bits) != 2 {
+			continue
+		}
+		out[strings.ToUpper(strings.TrimSpace(bits[0]))] = strings.TrimSpace(bits[1])
+	}
+	return out
+}
+
+// anyToInt handles numeric fields that are either JSON numbers or strings
+func anyToInt(val interface{}) int {
+	switch v := val.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if num, err := strconv.Atoi(v); err == nil {
+			return num
+		}
+	}
+	return 0
+}
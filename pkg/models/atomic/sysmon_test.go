@@ -0,0 +1,106 @@
+package atomic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeWinlogbeat(t *testing.T, eventID int, data map[string]any) DynamicWinlogbeat {
+	t.Helper()
+	raw, err := json.Marshal(map[string]any{
+		"@timestamp": "2022-04-20T10:00:00Z",
+		"winlog": map[string]any{
+			"channel":       SysmonChannel,
+			"computer_name": "ws01.corp.ex",
+			"event_id":      eventID,
+			"event_data":    data,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj DynamicWinlogbeat
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestSysmonProcessCreate(t *testing.T) {
+	obj := decodeWinlogbeat(t, SysmonProcessCreate, map[string]any{
+		"Image":             `C:\Windows\System32\cmd.exe`,
+		"CommandLine":       "cmd.exe /c whoami",
+		"ProcessId":         "4242",
+		"User":              `CORP\alice`,
+		"ParentImage":       `C:\Windows\explorer.exe`,
+		"ParentCommandLine": "explorer.exe",
+		"ParentProcessId":   "1000",
+		"Hashes":            "SHA1=aa,MD5=bb, imphash=cc",
+	})
+	if !obj.IsSysmon() {
+		t.Fatal("sysmon channel not detected")
+	}
+	s := obj.Sysmon()
+	if s == nil {
+		t.Fatal("sysmon fields not extracted")
+	}
+	if s.EventID != SysmonProcessCreate || s.User != `CORP\alice` {
+		t.Fatalf("bad event header %+v", s)
+	}
+	if s.Process.Image != `C:\Windows\System32\cmd.exe` || s.Process.CommandLine != "cmd.exe /c whoami" || s.Process.PID != 4242 {
+		t.Fatalf("bad process %+v", s.Process)
+	}
+	if s.Parent == nil || s.Parent.Image != `C:\Windows\explorer.exe` || s.Parent.PID != 1000 {
+		t.Fatalf("bad parent %+v", s.Parent)
+	}
+	for key, val := range map[string]string{"SHA1": "aa", "MD5": "bb", "IMPHASH": "cc"} {
+		if s.Hashes[key] != val {
+			t.Fatalf("expected %s hash %s, got %+v", key, val, s.Hashes)
+		}
+	}
+	if s.Network != nil || s.DNS != nil {
+		t.Fatalf("process event should not have network or dns section %+v", s)
+	}
+}
+
+func TestSysmonNetworkConnection(t *testing.T) {
+	s := decodeWinlogbeat(t, SysmonNetworkConnection, map[string]any{
+		"Image":           `C:\Windows\System32\svchost.exe`,
+		"Protocol":        "tcp",
+		"Initiated":       "true",
+		"SourceIp":        "10.0.0.5",
+		"SourcePort":      "50000",
+		"DestinationIp":   "10.0.0.10",
+		"DestinationPort": 443,
+	}).Sysmon()
+	n := s.Network
+	if n == nil {
+		t.Fatal("network section missing")
+	}
+	if !n.Initiated || n.Protocol != "tcp" {
+		t.Fatalf("bad connection %+v", n)
+	}
+	if n.SrcIP.String() != "10.0.0.5" || n.SrcPort != 50000 {
+		t.Fatalf("bad source %s:%d", n.SrcIP, n.SrcPort)
+	}
+	if n.DstIP.String() != "10.0.0.10" || n.DstPort != 443 {
+		t.Fatalf("bad destination %s:%d", n.DstIP, n.DstPort)
+	}
+	if s.Parent != nil {
+		t.Fatalf("unexpected parent %+v", s.Parent)
+	}
+}
+
+func TestSysmonDNSQuery(t *testing.T) {
+	s := decodeWinlogbeat(t, SysmonDNSQuery, map[string]any{
+		"QueryName":    "evil.example.com",
+		"QueryResults": "::ffff:10.0.0.66;",
+		"QueryStatus":  "0",
+	}).Sysmon()
+	if s.DNS == nil || s.DNS.Query != "evil.example.com" || s.DNS.Status != "0" {
+		t.Fatalf("bad dns section %+v", s.DNS)
+	}
+	if s.Network != nil {
+		t.Fatalf("dns event should not have network section %+v", s.Network)
+	}
+}
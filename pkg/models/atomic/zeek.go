@@ -1,6 +1,9 @@
 package atomic
 
 import (
+	"math"
+	"net"
+	"strings"
 	"time"
 
 	"go-peek/pkg/models/fields"
@@ -40,3 +43,115 @@ func (z ZeekCobalt) Source() string { return z.IDOrigH.String() }
 // Sender implements atomic.Event
 // Sender of message, usually a host
 func (z ZeekCobalt) Sender() string { return "cobalt" }
+
+// DynamicZeek is a generic Zeek JSON log entry
+// Zeek writes dotted keys such as id.orig_h as-is, but shippers may also nest them
+type DynamicZeek map[string]any
+
+// Get returns field value by flat key and falls back to nested lookup
+func (d DynamicZeek) Get(key string) (any, bool) {
+	if val, ok := d[key]; ok {
+		return val, true
+	}
+	var data map[string]any = d
+	bits := strings.Split(key, ".")
+	for i, bit := range bits {
+		val, ok := data[bit]
+		if !ok {
+			return nil, false
+		}
+		if i == len(bits)-1 {
+			return val, true
+		}
+		if data, ok = val.(map[string]any); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// GetString returns field value as string, if present and of correct type
+func (d DynamicZeek) GetString(key string) (string, bool) {
+	val, ok := d.Get(key)
+	if !ok {
+		return "", false
+	}
+	s, ok := val.(string)
+	return s, ok
+}
+
+// GetIP returns field value as IP address
+func (d DynamicZeek) GetIP(key string) net.IP {
+	val, ok := d.GetString(key)
+	if !ok {
+		return nil
+	}
+	return net.ParseIP(val)
+}
+
+// Path returns zeek log type, such as conn, dns, http, etc
+// _path is only present if zeek or shipper is configured to add it, so fall back to guessing from known fields
+func (d DynamicZeek) Path() string {
+	if val, ok := d.GetString("_path"); ok && val != "" {
+		return val
+	}
+	if val, ok := d.GetString("event.dataset"); ok && val != "" {
+		return strings.TrimPrefix(val, "zeek.")
+	}
+	switch {
+	case d.has("note"):
+		return "notice"
+	case d.has("query"), d.has("qtype_name"):
+		return "dns"
+	case d.has("method"), d.has("status_code"):
+		return "http"
+	case d.has("server_name"), d.has("cipher"):
+		return "ssl"
+	case d.has("conn_state"):
+		return "conn"
+	}
+	return ""
+}
+
+func (d DynamicZeek) has(key string) bool {
+	_, ok := d.Get(key)
+	return ok
+}
+
+// Time implements atomic.Event
+// Timestamp in event, should default to time.Time{} so time.IsZero() could be used to verify success
+func (d DynamicZeek) Time() time.Time {
+	for _, key := range []string{"ts", "@timestamp"} {
+		val, ok := d[key]
+		if !ok {
+			continue
+		}
+		switch v := val.(type) {
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+		case string:
+			if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return ts
+			}
+		case time.Time:
+			return v
+		}
+	}
+	return time.Time{}
+}
+
+// Source implements atomic.Event
+// Source of message, usually emitting program
+func (d DynamicZeek) Source() string { return d.Path() }
+
+// Sender implements atomic.Event
+// Sender of message, usually a host
+func (d DynamicZeek) Sender() string {
+	for _, key := range []string{"_system_name", "host.name", "observer.name"} {
+		if val, ok := d.GetString(key); ok && val != "" {
+			return val
+		}
+	}
+	return ""
+}
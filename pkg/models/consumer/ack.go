@@ -0,0 +1,36 @@
+package consumer
+
+import "sync/atomic"
+
+// Ack tracks downstream deliveries of input message
+// creator holds one reference, every derived output holds another
+// done callback runs once all references are released, for example to commit kafka offset
+// nil Ack is valid and does nothing, so inputs without delivery tracking need no special handling
+type Ack struct {
+	pending int64
+	done    func()
+}
+
+// Hold registers another pending delivery and returns the same handle for attaching to output message
+func (a *Ack) Hold() *Ack {
+	if a == nil {
+		return nil
+	}
+	atomic.AddInt64(&a.pending, 1)
+	return a
+}
+
+// Done releases one reference, callback is invoked when last one is gone
+func (a *Ack) Done() {
+	if a == nil {
+		return
+	}
+	if atomic.AddInt64(&a.pending, -1) == 0 && a.done != nil {
+		a.done()
+	}
+}
+
+// NewAck returns handle with single reference held by caller
+func NewAck(done func()) *Ack {
+	return &Ack{pending: 1, done: done}
+}
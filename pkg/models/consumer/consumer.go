@@ -21,6 +21,8 @@ func (s Source) String() string {
 		return "kafka"
 	case Logfile:
 		return "logfile"
+	case Syslog:
+		return "syslog"
 	default:
 		return "NA"
 	}
@@ -32,6 +34,7 @@ const (
 	Kafka
 	UxSock
 	Redis
+	Syslog
 )
 
 type Messager interface {
@@ -80,6 +83,25 @@ type Message struct {
 	// Optional sender IP address
 	// For example, syslog UDP sender info is usually taken from UDP source
 	Sender net.IP
+
+	// Optional output topic that overrides producer topic map function
+	// For example, when message was already routed to a sanitized copy topic
+	Topic string
+
+	// Optional delivery handle, released once message is handled or its outputs are delivered
+	// Outputs derived from input message should carry Ack.Hold() of the input
+	Ack *Ack
+
+	// Optional position of input message that this message was derived from
+	// Passed on as kafka record headers, so output can be traced back to its source
+	Origin *Origin
+}
+
+// Origin is position of message in input source
+type Origin struct {
+	Source    string
+	Partition int64
+	Offset    int64
 }
 
 type Offsets struct {
@@ -0,0 +1,249 @@
+package events
+
+import (
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/meta"
+	"net"
+	"strings"
+)
+
+// winlogbeat fields that may reveal game network layout, grouped by alias kind
+var (
+	winlogHostFields = []string{
+		"host.name",
+		"host.hostname",
+		"agent.name",
+		"agent.hostname",
+		"winlog.computer_name",
+		"winlog.user.domain",
+		"user.domain",
+		"winlog.event_data.SubjectDomainName",
+		"winlog.event_data.TargetDomainName",
+		"winlog.event_data.WorkstationName",
+	}
+	winlogUserFields = []string{
+		"user.name",
+		"winlog.user.name",
+		"winlog.event_data.SubjectUserName",
+		"winlog.event_data.TargetUserName",
+	}
+	// accounts in DOMAIN\user format
+	winlogAccountFields = []string{
+		"winlog.event_data.User",
+		"winlog.event_data.SourceUser",
+		"winlog.event_data.TargetUser",
+	}
+	winlogIPFields = []string{
+		"host.ip",
+		"source.ip",
+		"destination.ip",
+		"winlog.event_data.IpAddress",
+		"winlog.event_data.SourceIp",
+		"winlog.event_data.DestinationIp",
+	}
+	winlogTextFields = []string{
+		"message",
+		"process.command_line",
+		"process.parent.command_line",
+		"winlog.event_data.CommandLine",
+		"winlog.event_data.ParentCommandLine",
+		// network peers may be outside game network, so only known names are rewritten
+		"winlog.event_data.SourceHostname",
+		"winlog.event_data.DestinationHostname",
+	}
+)
+
+// Anonymize implements Anonymizer
+func (d *DynamicWinlogbeat) Anonymize(p meta.Pseudonyms) error {
+	for _, key := range winlogHostFields {
+		rewriteDotField(d.DynamicWinlogbeat, key, p.Host)
+	}
+	for _, key := range winlogUserFields {
+		rewriteDotField(d.DynamicWinlogbeat, key, p.User)
+	}
+	for _, key := range winlogAccountFields {
+		rewriteDotField(d.DynamicWinlogbeat, key, func(val string) string {
+			return anonymizeAccount(p, val)
+		})
+	}
+	for _, key := range winlogIPFields {
+		rewriteDotField(d.DynamicWinlogbeat, key, func(val string) string {
+			return anonymizeIP(p, val)
+		})
+	}
+	for _, key := range winlogTextFields {
+		rewriteDotField(d.DynamicWinlogbeat, key, p.Replace)
+	}
+	if d.GameMeta != nil {
+		d.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+// Anonymize implements Anonymizer
+// typed sysmon fields are extracted again from rewritten event
+func (s *Sysmon) Anonymize(p meta.Pseudonyms) error {
+	if err := s.DynamicWinlogbeat.Anonymize(p); err != nil {
+		return err
+	}
+	delete(s.DynamicWinlogbeat.DynamicWinlogbeat, "sysmon")
+	s.Sysmon = s.DynamicWinlogbeat.DynamicWinlogbeat.Sysmon()
+	return nil
+}
+
+// Anonymize implements Anonymizer
+func (s *Suricata) Anonymize(p meta.Pseudonyms) error {
+	rewriteDotField(s.Data, "host", p.Host)
+	for _, key := range []string{"src_ip", "dest_ip"} {
+		rewriteDotField(s.Data, key, func(val string) string {
+			return anonymizeIP(p, val)
+		})
+	}
+	if s.Syslog != nil {
+		anonymizeSyslog(p, s.Syslog)
+	}
+	if s.GameMeta != nil {
+		s.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+// Anonymize implements Anonymizer
+func (s *Syslog) Anonymize(p meta.Pseudonyms) error {
+	anonymizeSyslog(p, &s.Syslog)
+	if s.GameMeta != nil {
+		s.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+// Anonymize implements Anonymizer
+// user names are assigned before free text is rewritten, so they are known when message refers to them
+func (s *Snoopy) Anonymize(p meta.Pseudonyms) error {
+	if s.Username != "" {
+		s.Username = p.User(s.Username)
+	}
+	if s.Login != "" {
+		s.Login = p.User(s.Login)
+	}
+	anonymizeSyslog(p, &s.Syslog)
+	s.Cmd = p.Replace(s.Cmd)
+	s.Cwd = p.Replace(s.Cwd)
+	s.Filename = p.Replace(s.Filename)
+	if ssh := s.SSH; ssh != nil {
+		if ssh.SrcIP != nil {
+			ssh.SrcIP.IP = p.IP(ssh.SrcIP.IP)
+		}
+		if ssh.DstIP != nil {
+			ssh.DstIP.IP = p.IP(ssh.DstIP.IP)
+		}
+	}
+	if s.GameMeta != nil {
+		s.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+// Anonymize implements Anonymizer
+func (z *Zeek) Anonymize(p meta.Pseudonyms) error {
+	for _, key := range []string{"id.orig_h", "id.resp_h"} {
+		fn := func(val string) string { return anonymizeIP(p, val) }
+		// zeek logs may use flat dotted keys
+		if val, ok := z.Data[key].(string); ok {
+			z.Data[key] = fn(val)
+			continue
+		}
+		rewriteDotField(z.Data, key, fn)
+	}
+	if z.GameMeta != nil {
+		z.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+// standard CEF extension keys that may reveal game network layout, profile keys are added on top
+var (
+	cefHostFields = []string{"shost", "dhost", "dvchost", "sntdom", "dntdom"}
+	cefUserFields = []string{"suser", "duser"}
+	cefIPFields   = []string{"src", "dst", "dvc"}
+	cefTextFields = []string{"msg", "request", "filePath"}
+)
+
+// Anonymize implements Anonymizer
+func (c *Cef) Anonymize(p meta.Pseudonyms) error {
+	ext := c.Cef.Extensions
+	// profile keys may repeat standard ones, every value must be rewritten only once
+	done := make(map[string]bool)
+	rewrite := func(fn func(string) string, keys ...string) {
+		for _, key := range keys {
+			if val, ok := ext[key]; ok && val != "" && !done[key] {
+				ext[key] = fn(val)
+				done[key] = true
+			}
+		}
+	}
+	ipFn := func(val string) string { return anonymizeIP(p, strings.TrimSpace(val)) }
+	rewrite(p.Host, cefHostFields...)
+	rewrite(p.Host, c.Profile.SenderKey)
+	rewrite(p.User, cefUserFields...)
+	rewrite(ipFn, cefIPFields...)
+	rewrite(ipFn, c.Profile.SrcKey, c.Profile.DstKey)
+	rewrite(p.Replace, cefTextFields...)
+	anonymizeSyslog(p, &c.Syslog)
+	if c.GameMeta != nil {
+		c.GameMeta.Anonymize(p)
+	}
+	return nil
+}
+
+func anonymizeSyslog(p meta.Pseudonyms, s *atomic.Syslog) {
+	if s.Host != "" {
+		s.Host = p.Host(s.Host)
+	}
+	if s.IP != nil {
+		s.IP.IP = p.IP(s.IP.IP)
+	}
+	s.Message = p.Replace(s.Message)
+}
+
+func anonymizeIP(p meta.Pseudonyms, val string) string {
+	ip := net.ParseIP(val)
+	if ip == nil {
+		return p.Replace(val)
+	}
+	return p.IP(ip).String()
+}
+
+func anonymizeAccount(p meta.Pseudonyms, val string) string {
+	bits := strings.SplitN(val, `\`, 2)
+	if len(bits) != 2 {
+		return p.User(val)
+	}
+	return p.Host(bits[0]) + `\` + p.User(bits[1])
+}
+
+// rewriteDotField applies fn to string or string list value at dot path
+// missing fields are left as-is
+func rewriteDotField(d map[string]any, key string, fn func(string) string) {
+	bits := strings.Split(key, ".")
+	for _, bit := range bits[:len(bits)-1] {
+		next, ok := d[bit].(map[string]any)
+		if !ok {
+			return
+		}
+		d = next
+	}
+	last := bits[len(bits)-1]
+	switch val := d[last].(type) {
+	case string:
+		if val != "" {
+			d[last] = fn(val)
+		}
+	case []any:
+		for i, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				val[i] = fn(s)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/meta"
+	"time"
+)
+
+// CefProfile describes how a vendor packs asset information into CEF extensions
+type CefProfile struct {
+	Kind Atomic
+	// Extension keys for source and destination IP addresses
+	SrcKey string
+	DstKey string
+	// Extension keys for reporting device and program, syslog header is used as fallback
+	SenderKey string
+	SourceKey string
+}
+
+// CefMazeRunner is the profile for MazeRunner honeypot alerts from Cymmetria
+var CefMazeRunner = CefProfile{
+	Kind:      MazeRunnerE,
+	SrcKey:    "src",
+	DstKey:    "dst",
+	SenderKey: "dvchost",
+	SourceKey: "dntdom",
+}
+
+// CefProfiles maps event kinds to known CEF profiles
+var CefProfiles = map[Atomic]CefProfile{
+	MazeRunnerE: CefMazeRunner,
+}
+
+// Cef is a generic event for Common Event Format alerts that are shipped over syslog
+// Deception systems are the main source of CEF, so every event is emitted
+type Cef struct {
+	Syslog   atomic.Syslog   `json:"syslog"`
+	Cef      atomic.Cef      `json:"cef"`
+	GameMeta *meta.GameAsset `json:"GameMeta,omitempty"`
+
+	Profile CefProfile `json:"-"`
+}
+
+func (c Cef) Emit() bool { return true }
+
+func (c Cef) Kind() Atomic { return c.Profile.Kind }
+
+func (c Cef) GetMitreAttack() *meta.MitreAttack {
+	if c.GameMeta != nil {
+		return c.GameMeta.MitreAttack
+	}
+	return nil
+}
+
+// DumpEventData implements EventDataDumper
+func (c Cef) DumpEventData() *meta.EventData {
+	return &meta.EventData{
+		ID:     0,
+		Key:    c.Cef.SignatureID,
+		Fields: []string{c.Cef.Name},
+	}
+}
+
+// Keywords implements Keyworder
+func (c Cef) Keywords() ([]string, bool) {
+	return []string{c.Cef.Name}, c.Cef.Name != ""
+}
+
+// Select returns a success status and arbitrary field content if requested map key is present
+// CEF extensions take precedence over header fields
+func (c Cef) Select(key string) (interface{}, bool) {
+	if val, ok := c.Cef.Extensions[key]; ok {
+		return val, true
+	}
+	switch key {
+	case "DeviceVendor":
+		return c.Cef.DeviceVendor, true
+	case "DeviceProduct":
+		return c.Cef.DeviceProduct, true
+	case "DeviceVersion":
+		return c.Cef.DeviceVersion, true
+	case "SignatureID":
+		return c.Cef.SignatureID, true
+	case "Name":
+		return c.Cef.Name, true
+	case "Severity":
+		return c.Cef.Severity, true
+	}
+	return c.Syslog.GetField(key)
+}
+
+// JSONFormat implements atomic.JSONFormatter by wrapping json.Marshal
+func (c Cef) JSONFormat() ([]byte, error) { return json.Marshal(c) }
+
+// GetAsset is a getter for receiving event source and target information
+// For exampe, event source for syslog is usually the shipper, while suricata alert has affected source and destination IP addresses whereas directionality matters
+// Should provide needed information for doing external asset table lookups
+func (c Cef) GetAsset() *meta.GameAsset {
+	asset := &meta.GameAsset{
+		Asset: meta.Asset{Host: c.Sender()},
+		MitreAttack: &meta.MitreAttack{
+			Techniques: make([]meta.Technique, 0),
+		},
+	}
+	if ip := c.Cef.GetIP(c.Profile.SrcKey); ip != nil {
+		asset.Source = &meta.Asset{IP: ip}
+	}
+	if ip := c.Cef.GetIP(c.Profile.DstKey); ip != nil {
+		asset.Destination = &meta.Asset{IP: ip}
+	}
+	return asset
+}
+
+// SetAsset is a setter for setting meta to object without knowing the object type
+// all asset lookups and field discoveries should be done before using this method to maintain readability
+func (c *Cef) SetAsset(data *meta.GameAsset) {
+	c.GameMeta = data
+}
+
+// Time implements atomic.Event
+// Timestamp in event, should default to time.Time{} so time.IsZero() could be used to verify success
+func (c Cef) Time() time.Time { return c.Syslog.Time() }
+
+// Source implements atomic.Event
+// Source of message, usually emitting program
+func (c Cef) Source() string {
+	if val, ok := c.Cef.Extensions[c.Profile.SourceKey]; ok && val != "" {
+		return val
+	}
+	if c.Cef.DeviceProduct != "" {
+		return c.Cef.DeviceProduct
+	}
+	return c.Syslog.Source()
+}
+
+// Sender implements atomic.Event
+// Sender of message, usually a host
+func (c Cef) Sender() string {
+	if val, ok := c.Cef.Extensions[c.Profile.SenderKey]; ok && val != "" {
+		return val
+	}
+	return c.Syslog.Sender()
+}
@@ -0,0 +1,78 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"testing"
+	"time"
+)
+
+func TestCefMazeRunner(t *testing.T) {
+	line := `CEF:0|Cymmetria|MazeRunner|1.0|ssh-login|SSH login|10|src=10.0.0.5 dst=10.0.0.9 dvchost=decoy-01 dntdom=maze suser=root`
+	obj, err := atomic.ParseCEF(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// preprocess ships event as json, enrich decodes it and attaches profile by kind
+	raw, err := json.Marshal(Cef{
+		Syslog: atomic.Syslog{
+			Timestamp: time.Date(2022, 10, 10, 10, 0, 0, 0, time.UTC),
+			Host:      "mazerunner-mgmt",
+			Program:   "MazeRunner",
+			Message:   line,
+		},
+		Cef: *obj,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Cef
+	if err := json.Unmarshal(raw, &c); err != nil {
+		t.Fatal(err)
+	}
+	c.Profile = CefProfiles[MazeRunnerE]
+
+	if c.Kind() != MazeRunnerE {
+		t.Fatalf("expected %s kind, got %s", MazeRunnerE, c.Kind())
+	}
+	if !c.Emit() {
+		t.Fatal("deception alerts should always be emitted")
+	}
+	// profile keys take precedence over syslog header
+	if c.Sender() != "decoy-01" || c.Source() != "maze" {
+		t.Fatalf("bad sender %s or source %s", c.Sender(), c.Source())
+	}
+
+	asset := c.GetAsset()
+	if asset.Host != "decoy-01" {
+		t.Fatalf("expected decoy host, got %s", asset.Host)
+	}
+	if asset.Source == nil || asset.Source.IP.String() != "10.0.0.5" {
+		t.Fatalf("bad source %+v", asset.Source)
+	}
+	if asset.Destination == nil || asset.Destination.IP.String() != "10.0.0.9" {
+		t.Fatalf("bad destination %+v", asset.Destination)
+	}
+
+	for key, expected := range map[string]string{
+		"suser":         "root",
+		"dst":           "10.0.0.9",
+		"SignatureID":   "ssh-login",
+		"DeviceProduct": "MazeRunner",
+		"syslog_host":   "mazerunner-mgmt",
+	} {
+		val, ok := c.Select(key)
+		if !ok || val != expected {
+			t.Fatalf("select %s: expected %s, got %v", key, expected, val)
+		}
+	}
+	if _, ok := c.Select("missing"); ok {
+		t.Fatal("unknown field should not be selected")
+	}
+
+	// without profile keys, syslog header identifies sender
+	c.Profile = CefProfile{Kind: MazeRunnerE}
+	if c.Sender() != "mazerunner-mgmt" {
+		t.Fatalf("expected syslog sender fallback, got %s", c.Sender())
+	}
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"bytes"
+	"go-peek/pkg/models/meta"
 	"strings"
 	"time"
 )
@@ -10,8 +11,9 @@ type Timer interface {
 	Time() time.Time
 }
 
+// Anonymizer rewrites host names, user names and internal addresses in event body and meta with aliases
 type Anonymizer interface {
-	Anonymize(map[string]string) error
+	Anonymize(meta.Pseudonyms) error
 }
 
 type SaganFormatter interface {
@@ -99,6 +101,8 @@ var Atomics = []Atomic{
 	SnoopyE,
 	EventLogE,
 	SysmonE,
+	ZeekE,
+	MazeRunnerE,
 }
 
 // Functions
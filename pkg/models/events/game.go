@@ -191,6 +191,45 @@ func (s Suricata) Emit() bool {
 
 func (s Suricata) Kind() Atomic { return SuricataE }
 
+// IsAlert reports if underlying EVE record is an alert
+func (s Suricata) IsAlert() bool {
+	evType, ok := s.Data["event_type"].(string)
+	return ok && evType == "alert"
+}
+
+// SignatureID returns suricata rule SID for alert events
+func (s Suricata) SignatureID() (int, bool) {
+	if !s.IsAlert() {
+		return 0, false
+	}
+	raw, ok := getDotField("alert.signature_id", s.Data)
+	if !ok {
+		return 0, false
+	}
+	switch sid := raw.(type) {
+	case float64:
+		return int(sid), true
+	case int:
+		return sid, true
+	case int64:
+		return int(sid), true
+	}
+	return 0, false
+}
+
+// Signature returns suricata rule msg for alert events
+func (s Suricata) Signature() string {
+	raw, ok := getDotField("alert.signature", s.Data)
+	if !ok {
+		return ""
+	}
+	msg, ok := raw.(string)
+	if !ok {
+		return ""
+	}
+	return msg
+}
+
 func (s Suricata) GetMitreAttack() *meta.MitreAttack {
 	technique, ok := getDotField("alert.metadata.mitre_technique_id", s.Data)
 	if !ok {
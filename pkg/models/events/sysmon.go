@@ -0,0 +1,102 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/meta"
+	"strconv"
+)
+
+// Sysmon is winlogbeat event from sysmon channel with typed fields extracted on decode
+// Raw event is kept intact, so sigma rules can still access any field
+type Sysmon struct {
+	DynamicWinlogbeat
+	Sysmon *atomic.Sysmon `json:"-"`
+}
+
+func (s Sysmon) Kind() Atomic { return SysmonE }
+
+// DumpEventData implements EventDataDumper
+func (s Sysmon) DumpEventData() *meta.EventData {
+	if s.Sysmon == nil {
+		return s.DynamicWinlogbeat.DumpEventData()
+	}
+	data := &meta.EventData{
+		ID:     s.Sysmon.EventID,
+		Key:    s.Sysmon.Process.Image,
+		Fields: make([]string, 0),
+	}
+	if s.Sysmon.Process.CommandLine != "" {
+		data.Fields = append(data.Fields, s.Sysmon.Process.CommandLine)
+	}
+	if s.Sysmon.Parent != nil {
+		data.Fields = append(data.Fields, s.Sysmon.Parent.Image)
+	}
+	if n := s.Sysmon.Network; n != nil && n.DstIP != nil {
+		data.Fields = append(data.Fields, n.DstIP.String()+":"+strconv.Itoa(n.DstPort))
+	}
+	if s.Sysmon.DNS != nil {
+		data.Fields = append(data.Fields, s.Sysmon.DNS.Query)
+	}
+	if s.Sysmon.User != "" {
+		data.Fields = append(data.Fields, s.Sysmon.User)
+	}
+	return data
+}
+
+// Keywords implements Keyworder
+func (s Sysmon) Keywords() ([]string, bool) {
+	tx, _ := s.DynamicWinlogbeat.Keywords()
+	if s.Sysmon != nil {
+		if s.Sysmon.Process.CommandLine != "" {
+			tx = append(tx, s.Sysmon.Process.CommandLine)
+		}
+		if s.Sysmon.DNS != nil && s.Sysmon.DNS.Query != "" {
+			tx = append(tx, s.Sysmon.DNS.Query)
+		}
+	}
+	return tx, len(tx) > 0
+}
+
+// GetAsset is a getter for receiving event source and target information
+// For exampe, event source for syslog is usually the shipper, while suricata alert has affected source and destination IP addresses whereas directionality matters
+// Should provide needed information for doing external asset table lookups
+func (s Sysmon) GetAsset() *meta.GameAsset {
+	asset := s.DynamicWinlogbeat.GetAsset()
+	if s.Sysmon == nil || s.Sysmon.Network == nil {
+		return asset
+	}
+	if ip := s.Sysmon.Network.SrcIP; ip != nil {
+		asset.Source = &meta.Asset{IP: ip}
+	}
+	if ip := s.Sysmon.Network.DstIP; ip != nil {
+		asset.Destination = &meta.Asset{IP: ip}
+	}
+	return asset
+}
+
+// JSONFormat implements atomic.JSONFormatter by wrapping json.Marshal
+// raw event is copied, as the map is shared with other copies of the event
+func (s Sysmon) JSONFormat() ([]byte, error) {
+	obj := make(map[string]interface{}, len(s.DynamicWinlogbeat.DynamicWinlogbeat)+2)
+	for key, val := range s.DynamicWinlogbeat.DynamicWinlogbeat {
+		obj[key] = val
+	}
+	if s.GameMeta != nil {
+		obj["GameMeta"] = s.GameMeta
+	}
+	if s.Sysmon != nil {
+		obj["sysmon"] = s.Sysmon
+	}
+	return json.Marshal(obj)
+}
+
+func NewSysmon(obj atomic.DynamicWinlogbeat) *Sysmon {
+	return &Sysmon{
+		DynamicWinlogbeat: DynamicWinlogbeat{
+			Timestamp:         obj.Time(),
+			DynamicWinlogbeat: obj,
+		},
+		Sysmon: obj.Sysmon(),
+	}
+}
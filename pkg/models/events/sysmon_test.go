@@ -0,0 +1,66 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"testing"
+)
+
+func TestSysmon(t *testing.T) {
+	var obj atomic.DynamicWinlogbeat
+	if err := json.Unmarshal([]byte(`{
+		"@timestamp": "2022-04-20T10:00:00Z",
+		"winlog": {
+			"channel": "Microsoft-Windows-Sysmon/Operational",
+			"computer_name": "ws01.corp.ex",
+			"event_id": 3,
+			"event_data": {
+				"Image": "C:\\Windows\\System32\\svchost.exe",
+				"SourceIp": "10.0.0.5",
+				"DestinationIp": "10.0.0.10",
+				"DestinationPort": "443"
+			}
+		}
+	}`), &obj); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSysmon(obj)
+	// sigma rulesets and field maps are selected by kind
+	if s.Kind() != SysmonE || s.Kind().String() != "sysmon" {
+		t.Fatalf("expected sysmon kind, got %s", s.Kind())
+	}
+
+	asset := s.GetAsset()
+	if asset.Host != "ws01.corp.ex" {
+		t.Fatalf("expected sender host, got %s", asset.Host)
+	}
+	if asset.Source == nil || asset.Source.IP.String() != "10.0.0.5" {
+		t.Fatalf("bad source %+v", asset.Source)
+	}
+	if asset.Destination == nil || asset.Destination.IP.String() != "10.0.0.10" {
+		t.Fatalf("bad destination %+v", asset.Destination)
+	}
+	if data := s.DumpEventData(); data.ID != 3 || data.Key != `C:\Windows\System32\svchost.exe` {
+		t.Fatalf("bad event data %+v", data)
+	}
+
+	s.SetAsset(asset)
+	out, err := s.JSONFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var formatted map[string]any
+	if err := json.Unmarshal(out, &formatted); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"GameMeta", "sysmon", "winlog"} {
+		if _, ok := formatted[key]; !ok {
+			t.Fatalf("%s missing from output", key)
+		}
+	}
+	for _, key := range []string{"GameMeta", "sysmon"} {
+		if _, ok := obj[key]; ok {
+			t.Fatalf("JSONFormat should not add %s to raw event", key)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"go-peek/pkg/models/meta"
+	"strings"
+	"time"
+)
+
+// zeekCobaltTechnique is attached to cobalt strike beacon notices from zeeky
+// https://github.com/ccdcoe/zeeky
+var zeekCobaltTechnique = meta.Technique{ID: "T1071", Name: "Application Layer Protocol"}
+
+type Zeek struct {
+	Data      atomic.DynamicZeek
+	Timestamp time.Time       `json:"@timestamp"`
+	GameMeta  *meta.GameAsset `json:"GameMeta,omitempty"`
+}
+
+func (z Zeek) Emit() bool {
+	return z.GameMeta != nil && z.GameMeta.MitreAttack != nil
+}
+
+func (z Zeek) Kind() Atomic { return ZeekE }
+
+// IsCobalt reports if event is a custom cobalt strike notice
+func (z Zeek) IsCobalt() bool {
+	if z.Data.Path() != "notice" {
+		return false
+	}
+	note, _ := z.Data.GetString("note")
+	return strings.Contains(strings.ToLower(note), "cobalt")
+}
+
+func (z Zeek) GetMitreAttack() *meta.MitreAttack {
+	if !z.IsCobalt() {
+		return nil
+	}
+	return &meta.MitreAttack{
+		Technique:  zeekCobaltTechnique,
+		Techniques: []meta.Technique{zeekCobaltTechnique},
+	}
+}
+
+// DumpEventData implements EventDataDumper
+func (z Zeek) DumpEventData() *meta.EventData {
+	path := z.Data.Path()
+	if z.IsCobalt() {
+		path = "cobalt"
+	}
+	data := &meta.EventData{Key: path, Fields: []string{}}
+	var keys []string
+	switch path {
+	case "conn":
+		keys = []string{"proto", "service", "conn_state"}
+	case "dns":
+		keys = []string{"query", "qtype_name", "rcode_name"}
+	case "http":
+		keys = []string{"method", "host", "uri", "user_agent"}
+	case "ssl":
+		keys = []string{"server_name", "version", "ja3", "ja3s"}
+	case "notice", "cobalt":
+		keys = []string{"note", "msg", "sub"}
+	}
+	for _, key := range keys {
+		if val, ok := z.Data.GetString(key); ok && val != "" {
+			data.Fields = append(data.Fields, val)
+		}
+	}
+	return data
+}
+
+// Keywords implements Keyworder
+func (z Zeek) Keywords() ([]string, bool) {
+	tx := make([]string, 0)
+	for _, key := range []string{"msg", "sub", "query", "uri", "user_agent", "server_name"} {
+		if val, ok := z.Data.GetString(key); ok && val != "" {
+			tx = append(tx, val)
+		}
+	}
+	return tx, len(tx) > 0
+}
+
+// Select returns a success status and arbitrary field content if requested map key is present
+func (z Zeek) Select(key string) (any, bool) { return z.Data.Get(key) }
+
+// JSONFormat implements atomic.JSONFormatter by wrapping json.Marshal
+// event data is copied, as the map is shared with other copies of the event
+func (z Zeek) JSONFormat() ([]byte, error) {
+	obj := make(map[string]any, len(z.Data)+1)
+	for key, val := range z.Data {
+		obj[key] = val
+	}
+	obj["GameMeta"] = z.GameMeta
+	return json.Marshal(obj)
+}
+
+// GetAsset is a getter for receiving event source and target information
+// For exampe, event source for syslog is usually the shipper, while suricata alert has affected source and destination IP addresses whereas directionality matters
+// Should provide needed information for doing external asset table lookups
+func (z Zeek) GetAsset() *meta.GameAsset {
+	asset := &meta.GameAsset{Asset: meta.Asset{Host: z.Sender()}}
+	if ip := z.Data.GetIP("id.orig_h"); ip != nil {
+		asset.Source = &meta.Asset{IP: ip}
+	}
+	if ip := z.Data.GetIP("id.resp_h"); ip != nil {
+		asset.Destination = &meta.Asset{IP: ip}
+	}
+	return asset
+}
+
+// SetAsset is a setter for setting meta to object without knowing the object type
+// all asset lookups and field discoveries should be done before using this method to maintain readability
+func (z *Zeek) SetAsset(data *meta.GameAsset) {
+	z.GameMeta = data
+}
+
+// Time implements atomic.Event
+// Timestamp in event, should default to time.Time{} so time.IsZero() could be used to verify success
+func (z Zeek) Time() time.Time { return z.Data.Time() }
+
+// Source implements atomic.Event
+// Source of message, usually emitting program
+func (z Zeek) Source() string { return z.Data.Source() }
+
+// Sender implements atomic.Event
+// Sender of message, usually a host
+func (z Zeek) Sender() string { return z.Data.Sender() }
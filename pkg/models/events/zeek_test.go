@@ -0,0 +1,107 @@
+package events
+
+import (
+	"encoding/json"
+	"go-peek/pkg/models/atomic"
+	"testing"
+)
+
+func TestZeek(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		raw    string
+		path   string
+		src    string
+		dst    string
+		cobalt bool
+	}{
+		{
+			desc: "flat conn",
+			raw:  `{"ts":1650000000.5,"_path":"conn","id.orig_h":"10.0.0.1","id.resp_h":"10.0.0.2","conn_state":"SF"}`,
+			path: "conn",
+			src:  "10.0.0.1",
+			dst:  "10.0.0.2",
+		},
+		{
+			desc: "nested dns",
+			raw:  `{"ts":1650000000,"id":{"orig_h":"10.0.0.3","resp_h":"10.0.0.53"},"query":"example.com"}`,
+			path: "dns",
+			src:  "10.0.0.3",
+			dst:  "10.0.0.53",
+		},
+		{
+			desc:   "cobalt notice",
+			raw:    `{"ts":1650000000,"note":"CobaltStrike::Beacon","id.orig_h":"10.0.0.4"}`,
+			path:   "cobalt",
+			src:    "10.0.0.4",
+			cobalt: true,
+		},
+	} {
+		var data atomic.DynamicZeek
+		if err := json.Unmarshal([]byte(tc.raw), &data); err != nil {
+			t.Fatal(err)
+		}
+		z := Zeek{Data: data}
+		if z.Kind() != ZeekE {
+			t.Fatalf("%s: expected zeek kind, got %s", tc.desc, z.Kind())
+		}
+		if z.Time().IsZero() {
+			t.Fatalf("%s: timestamp not parsed", tc.desc)
+		}
+		if key := z.DumpEventData().Key; key != tc.path {
+			t.Fatalf("%s: expected path %s, got %s", tc.desc, tc.path, key)
+		}
+		if val, ok := z.Select("id.orig_h"); !ok || val != tc.src {
+			t.Fatalf("%s: dotted select returned %v", tc.desc, val)
+		}
+
+		asset := z.GetAsset()
+		if asset.Source == nil || asset.Source.IP.String() != tc.src {
+			t.Fatalf("%s: bad source %+v", tc.desc, asset.Source)
+		}
+		switch {
+		case tc.dst == "" && asset.Destination != nil:
+			t.Fatalf("%s: unexpected destination %+v", tc.desc, asset.Destination)
+		case tc.dst != "" && (asset.Destination == nil || asset.Destination.IP.String() != tc.dst):
+			t.Fatalf("%s: bad destination %+v", tc.desc, asset.Destination)
+		}
+
+		if z.IsCobalt() != tc.cobalt {
+			t.Fatalf("%s: expected cobalt %t", tc.desc, tc.cobalt)
+		}
+		mitre := z.GetMitreAttack()
+		if !tc.cobalt {
+			if mitre != nil {
+				t.Fatalf("%s: unexpected technique %+v", tc.desc, mitre)
+			}
+			continue
+		}
+		if mitre == nil || mitre.Technique.ID != "T1071" {
+			t.Fatalf("%s: expected T1071, got %+v", tc.desc, mitre)
+		}
+		asset.MitreAttack = mitre
+		z.SetAsset(asset)
+		if !z.Emit() {
+			t.Fatalf("%s: tagged event should be emitted", tc.desc)
+		}
+	}
+}
+
+func TestZeekJSONFormat(t *testing.T) {
+	z := Zeek{Data: atomic.DynamicZeek{"_path": "conn"}}
+	z.SetAsset(z.GetAsset())
+	out, err := z.JSONFormat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(out, &obj); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := obj["GameMeta"]; !ok {
+		t.Fatal("GameMeta missing from output")
+	}
+	if _, ok := z.Data["GameMeta"]; ok {
+		t.Fatal("JSONFormat should not modify event data")
+	}
+}
@@ -10,6 +10,10 @@ import (
 type StringNet struct{ net.IPNet }
 
 func (t *StringNet) UnmarshalJSON(b []byte) error {
+	// missing cells in table exports
+	if string(b) == "null" || string(b) == `""` {
+		return nil
+	}
 	raw, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
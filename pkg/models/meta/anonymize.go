@@ -0,0 +1,58 @@
+package meta
+
+import "net"
+
+// Pseudonyms provides persistent aliases for values that must not reach players
+// implementations must return the same alias for the same value
+type Pseudonyms interface {
+	// Host returns alias for host name, FQDNs resolve to alias of their host label
+	Host(string) string
+	// User returns alias for user name
+	User(string) string
+	// IP returns alias address, or original if address is not considered internal
+	IP(net.IP) net.IP
+	// Replace rewrites already known names and addresses within free text
+	Replace(string) string
+}
+
+// Anonymize rewrites identifying asset fields with aliases
+// inventory alias is kept as it is already player-safe, domain is dropped
+func (a *Asset) Anonymize(p Pseudonyms) {
+	if a.Host != "" {
+		a.Host = p.Host(a.Host)
+	}
+	if a.VM != "" {
+		a.VM = p.Host(a.VM)
+	}
+	if a.IP != nil {
+		a.IP = p.IP(a.IP)
+	}
+	a.Domain = ""
+}
+
+// Anonymize rewrites identifying meta fields with aliases
+// source and destination are copied before rewrite, as they may be shared with other records
+func (g *GameAsset) Anonymize(p Pseudonyms) {
+	g.Asset.Anonymize(p)
+	if g.Source != nil {
+		src := *g.Source
+		src.Anonymize(p)
+		g.Source = &src
+	}
+	if g.Destination != nil {
+		dst := *g.Destination
+		dst.Anonymize(p)
+		g.Destination = &dst
+	}
+	if g.EventData != nil {
+		data := &EventData{
+			ID:     g.EventData.ID,
+			Key:    p.Replace(g.EventData.Key),
+			Fields: make([]string, 0, len(g.EventData.Fields)),
+		}
+		for _, field := range g.EventData.Fields {
+			data.Fields = append(data.Fields, p.Replace(field))
+		}
+		g.EventData = data
+	}
+}
@@ -20,20 +20,24 @@ type Asset struct {
 	VM     string `json:"VM"`
 	Role   string `json:"Role"`
 
+	// Segment is network where asset IP belongs to, set even if asset itself is unknown
+	Segment *NetSegment `json:"Segment,omitempty"`
+
 	Indicators
 }
 
 func (a Asset) Copy() Asset {
 	return Asset{
-		Host:   a.Host,
-		Alias:  a.Alias,
-		OS:     a.OS,
-		IP:     net.ParseIP(a.IP.String()),
-		Zone:   a.Zone,
-		Team:   a.Team,
-		Domain: a.Domain,
-		VM:     a.VM,
-		Role:   a.Role,
+		Host:    a.Host,
+		Alias:   a.Alias,
+		OS:      a.OS,
+		IP:      net.ParseIP(a.IP.String()),
+		Zone:    a.Zone,
+		Team:    a.Team,
+		Domain:  a.Domain,
+		VM:      a.VM,
+		Role:    a.Role,
+		Segment: a.Segment,
 		Indicators: Indicators{
 			IsAsset: a.Indicators.IsAsset,
 		},
@@ -37,14 +37,17 @@ type GameAsset struct {
 }
 
 func (g *GameAsset) SetDirection() *GameAsset {
+	// events may only carry one side of the connection
+	src := g.Source != nil && g.Source.IsAsset
+	dst := g.Destination != nil && g.Destination.IsAsset
 	switch {
 	case g.Source == nil && g.Destination == nil:
 		g.Directionality = DirLocal
-	case g.Source.IsAsset && !g.Destination.IsAsset:
+	case src && !dst:
 		g.Directionality = DirOutbound
-	case !g.Source.IsAsset && g.Destination.IsAsset:
+	case !src && dst:
 		g.Directionality = DirInbound
-	case g.Source.IsAsset && g.Destination.IsAsset:
+	case src && dst:
 		g.Directionality = DirLateral
 	default:
 		g.Directionality = DirUnk
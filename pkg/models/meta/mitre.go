@@ -8,6 +8,8 @@ type MitreAttack struct {
 	Technique
 	Items      []string
 	Techniques []Technique
+	// Tactics is union of tactic IDs reached by all techniques, shadows tactics of primary technique
+	Tactics []string
 }
 
 func (m MitreAttack) Contains(key string) bool {
@@ -20,28 +22,49 @@ func (m MitreAttack) Contains(key string) bool {
 }
 
 func (m *MitreAttack) Update() {
-	if m.Techniques == nil || len(m.Techniques) < 1 {
+	if len(m.Techniques) == 0 && len(m.Tactics) == 0 {
 		return
 	}
-	// set to deduplicate values
-	seen := make(map[string]bool)
-	// new slice to deduplicate values
-	techniques := make([]Technique, 0, len(m.Techniques))
-	// shorthand list of technique names
-	items := make([]string, 0, len(m.Techniques))
+	if len(m.Techniques) > 0 {
+		// set to deduplicate values
+		seen := make(map[string]bool)
+		// new slice to deduplicate values
+		techniques := make([]Technique, 0, len(m.Techniques))
+		// shorthand list of technique names
+		items := make([]string, 0, len(m.Techniques))
 
-loop:
+	loop:
+		for _, t := range m.Techniques {
+			if seen[t.ID] {
+				continue loop
+			}
+			seen[t.ID] = true
+			items = append(items, t.Name)
+			techniques = append(techniques, t)
+		}
+		m.Items = items
+		m.Techniques = techniques
+		m.Technique = m.Techniques[0]
+	}
+
+	// tactics extracted from event without technique binding are kept
+	tactics := make([]string, 0)
+	seenTactics := make(map[string]bool)
+	for _, tactic := range m.Tactics {
+		if !seenTactics[tactic] {
+			seenTactics[tactic] = true
+			tactics = append(tactics, tactic)
+		}
+	}
 	for _, t := range m.Techniques {
-		if seen[t.ID] {
-			continue loop
+		for _, tactic := range t.Tactics {
+			if !seenTactics[tactic] {
+				seenTactics[tactic] = true
+				tactics = append(tactics, tactic)
+			}
 		}
-		seen[t.ID] = true
-		items = append(items, t.Name)
-		techniques = append(techniques, t)
 	}
-	m.Items = items
-	m.Techniques = techniques
-	m.Technique = m.Techniques[0]
+	m.Tactics = tactics
 }
 
 func (m *MitreAttack) Set(mapping Techniques) {
@@ -49,16 +72,27 @@ func (m *MitreAttack) Set(mapping Techniques) {
 		return
 	}
 	if mapping != nil {
+		parents := make([]Technique, 0)
 		for i, t := range m.Techniques {
 			if val, ok := mapping[t.ID]; ok {
 				m.Techniques[i] = Technique{
-					ID:     t.ID,
-					Name:   val.Name,
-					Phases: val.Phases,
-					URL:    val.URL,
+					ID:      t.ID,
+					Name:    val.Name,
+					Phases:  val.Phases,
+					URL:     val.URL,
+					Tactics: val.Tactics,
+					Parent:  val.Parent,
+				}
+				if len(val.Tactics) == 0 {
+					m.Techniques[i].Tactics = t.Tactics
+				}
+				// sub-techniques roll up to parent, so aggregations by parent ID also count them
+				if parent, ok := mapping[val.Parent]; ok && val.Parent != "" {
+					parents = append(parents, parent)
 				}
 			}
 		}
+		m.Techniques = append(m.Techniques, parents...)
 	}
 }
 
@@ -67,6 +101,21 @@ type Technique struct {
 	Name   string
 	URL    string
 	Phases []string
+	// Tactics holds tactic IDs, for example TA0002, Phases holds their short names
+	Tactics []string
+	// Parent is technique ID of a sub-technique parent, empty for top level techniques
+	Parent string `json:"Parent,omitempty"`
 }
 
+// Tactic is ATT&CK tactic, ShortName matches technique kill chain phase
+type Tactic struct {
+	ID        string
+	Name      string
+	ShortName string
+	URL       string
+	Domain    string
+}
+
+type Tactics map[string]Tactic
+
 type Techniques map[string]Technique
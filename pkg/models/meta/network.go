@@ -31,6 +31,8 @@ func (n NetworkPandasExport) Extract() []*Network {
 			Desc:         n.Desc[id],
 			Whois:        n.Whois[id],
 			Team:         n.Team[id],
+			// pandas table has no explicit flag, team networks are considered to hold game assets
+			Assets: n.Team[id] != "",
 		}
 		list = append(list, net)
 	}
@@ -48,18 +50,24 @@ type Network struct {
 	Desc         string    `json:"Description"`
 	Whois        string    `json:"WHOIS"`
 	Team         string    `json:"Team"`
+	Zone         string    `json:"Zone"`
+	// Assets marks that unknown hosts in this segment should be treated as game assets
+	Assets bool `json:"Assets"`
 }
 
 func (n Network) Shorthand() (*NetSegment, *NetSegment) {
+	return n.segment(n.IPv4), n.segment(n.IPv6)
+}
+
+func (n Network) segment(ipnet net.IPNet) *NetSegment {
 	return &NetSegment{
-			ID:   n.ID,
-			net:  n.IPv4,
-			Name: n.Name,
-		}, &NetSegment{
-			ID:   n.ID,
-			net:  n.IPv6,
-			Name: n.Name,
-		}
+		ID:   n.ID,
+		net:  ipnet,
+		Name: n.Name,
+		VLAN: n.VLAN,
+		Team: n.Team,
+		Zone: n.Zone,
+	}
 }
 
 // NetSegment is a shorthand representation of Network, more suitable to be used in de-normalized logging
@@ -67,6 +75,8 @@ type NetSegment struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 	VLAN string `json:"vlan"`
+	Team string `json:"team,omitempty"`
+	Zone string `json:"zone,omitempty"`
 	net  net.IPNet
 }
 
@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/events"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// provenance header keys, origin headers are only set if message knows its input position
+const (
+	HeaderOriginTopic     = "peek-origin-topic"
+	HeaderOriginPartition = "peek-origin-partition"
+	HeaderOriginOffset    = "peek-origin-offset"
+	HeaderKind            = "peek-kind"
+	HeaderStage           = "peek-stage"
+	HeaderVersion         = "peek-version"
+	HeaderProcessed       = "peek-processed"
+)
+
+// Provenance builds record headers that trace message back to its input
+func Provenance(msg consumer.Message, stage, version string, now time.Time) []sarama.RecordHeader {
+	tx := make([]sarama.RecordHeader, 0, 7)
+	add := func(key, value string) {
+		if value != "" {
+			tx = append(tx, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+		}
+	}
+	if origin := msg.Origin; origin != nil {
+		add(HeaderOriginTopic, origin.Source)
+		add(HeaderOriginPartition, strconv.FormatInt(origin.Partition, 10))
+		add(HeaderOriginOffset, strconv.FormatInt(origin.Offset, 10))
+	}
+	if msg.Event != events.SimpleE {
+		add(HeaderKind, msg.Event.String())
+	}
+	add(HeaderStage, stage)
+	add(HeaderVersion, version)
+	add(HeaderProcessed, now.UTC().Format(time.RFC3339Nano))
+	return tx
+}
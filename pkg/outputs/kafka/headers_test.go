@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/models/events"
+	"testing"
+	"time"
+)
+
+func TestProvenance(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	headers := func(msg consumer.Message) map[string]string {
+		tx := make(map[string]string)
+		for _, h := range Provenance(msg, "enrich", "v1", now) {
+			tx[string(h.Key)] = string(h.Value)
+		}
+		return tx
+	}
+
+	got := headers(consumer.Message{
+		Event:  events.SysmonE,
+		Origin: &consumer.Origin{Source: "winlogbeat", Partition: 3, Offset: 1200},
+	})
+	expected := map[string]string{
+		HeaderOriginTopic:     "winlogbeat",
+		HeaderOriginPartition: "3",
+		HeaderOriginOffset:    "1200",
+		HeaderKind:            "sysmon",
+		HeaderStage:           "enrich",
+		HeaderVersion:         "v1",
+		HeaderProcessed:       "2026-10-17T12:00:00Z",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d headers, got %+v", len(expected), got)
+	}
+	for key, val := range expected {
+		if got[key] != val {
+			t.Fatalf("header %s: expected %q, got %q", key, val, got[key])
+		}
+	}
+
+	// messages without input position or event kind only carry processing info
+	got = headers(consumer.Message{})
+	if _, ok := got[HeaderOriginTopic]; ok || got[HeaderKind] != "" || got[HeaderStage] != "enrich" {
+		t.Fatalf("unexpected headers %+v", got)
+	}
+}
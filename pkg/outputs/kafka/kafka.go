@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"go-peek/pkg/kafkaauth"
+	"go-peek/pkg/metrics"
 	"go-peek/pkg/models/consumer"
 
 	"github.com/Shopify/sarama"
@@ -19,6 +21,15 @@ type Config struct {
 	// TODO - automatically close producer if all feeders exit
 	AutoClose bool
 	Logger    *logrus.Logger
+	// AtLeastOnce waits for broker acknowledgement and releases message delivery handle on success
+	// otherwise messages are fire-and-forget and handles are released when passed to producer
+	AtLeastOnce bool
+	// Security holds TLS and SASL options for brokers
+	Security kafkaauth.Config
+	// Headers enables provenance record headers, Stage and Version are written into them
+	Headers bool
+	Stage   string
+	Version string
 }
 
 func NewDefaultConfig() *Config {
@@ -37,13 +48,21 @@ func (c *Config) Validate() error {
 	if c.SaramaConfig == nil {
 		c.SaramaConfig = newProducerConfig()
 	}
-	return nil
+	if c.AtLeastOnce {
+		c.SaramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+		c.SaramaConfig.Producer.Return.Successes = true
+	}
+	return c.Security.Apply(c.SaramaConfig)
 }
 
 type Producer struct {
 	handle   sarama.AsyncProducer
 	config   *sarama.Config
 	active   bool
+	acked    bool
+	headers  bool
+	stage    string
+	version  string
 	feeders  *sync.WaitGroup
 	errCount int
 	logger   *logrus.Logger
@@ -60,6 +79,10 @@ func NewProducer(c *Config) (*Producer, error) {
 		config:  c.SaramaConfig,
 		feeders: &sync.WaitGroup{},
 		logger:  c.Logger,
+		acked:   c.AtLeastOnce,
+		headers: c.Headers,
+		stage:   c.Stage,
+		version: c.Version,
 	}
 	if producer, err := sarama.NewAsyncProducer(c.Brokers, c.SaramaConfig); err != nil {
 		return nil, err
@@ -73,14 +96,34 @@ func NewProducer(c *Config) (*Producer, error) {
 	loop:
 		for h.active {
 			select {
-			case _, ok := <-h.handle.Errors():
+			case err, ok := <-h.handle.Errors():
 				if !ok {
 					break loop
 				}
 				h.errCount++
+				if err != nil && err.Msg != nil {
+					metrics.ProducerErrors.WithLabelValues(err.Msg.Topic).Inc()
+					// delivery handle is not released, so input offset is not committed and message is replayed on restart
+					if h.acked && h.logger != nil {
+						h.logger.WithFields(logrus.Fields{
+							"topic": err.Msg.Topic,
+							"err":   err.Err,
+						}).Error("kafka delivery failed")
+					}
+				}
 			}
 		}
 	}()
+	if h.acked {
+		go func() {
+			for msg := range h.handle.Successes() {
+				metrics.ProducerDelivered.WithLabelValues(msg.Topic).Inc()
+				if ack, ok := msg.Metadata.(*consumer.Ack); ok {
+					ack.Done()
+				}
+			}
+		}()
+	}
 
 	return h, nil
 }
@@ -133,12 +176,25 @@ func (p Producer) Feed(
 				m := &sarama.ProducerMessage{
 					Timestamp: msg.Time,
 					Value:     sarama.ByteEncoder(msg.Data),
-					Topic:     fn(msg),
+					Topic:     msg.Topic,
+				}
+				if m.Topic == "" {
+					m.Topic = fn(msg)
 				}
 				if msg.Key != "" {
 					m.Key = sarama.ByteEncoder(msg.Key)
 				}
+				if p.headers {
+					m.Headers = Provenance(msg, p.stage, p.version, time.Now())
+				}
+				if p.acked {
+					m.Metadata = msg.Ack
+				}
 				p.handle.Input() <- m
+				if !p.acked {
+					msg.Ack.Done()
+				}
+				metrics.ProducerMessages.WithLabelValues(m.Topic).Inc()
 				count++
 			case <-debug.C:
 				if p.logger != nil {
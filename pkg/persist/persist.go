@@ -124,13 +124,58 @@ func (b Badger) Set(prefix string, vals ...GenericValue) error {
 	txn := b.DB.NewTransaction(true)
 	defer txn.Discard()
 
-	if err := setLoop(txn, prefix, vals...); err != nil {
+	if err := setLoop(txn, prefix, 0, vals...); err != nil {
 		return err
 	}
 
 	return txn.Commit()
 }
 
+// SetWithTTL is like Set, but badger drops entries once ttl has passed
+func (b Badger) SetWithTTL(prefix string, ttl time.Duration, vals ...GenericValue) error {
+	if vals == nil || len(vals) == 0 {
+		return ErrNoValsToSet
+	}
+	if b.DB == nil {
+		return ErrMissingHandle
+	}
+	if logger := b.config.Logger; logger != nil {
+		logger.WithFields(logrus.Fields{
+			"prefix": prefix, "count": len(vals), "key": vals[0].Key, "ttl": ttl,
+		}).Tracef("Set badger entry with TTL")
+	}
+	txn := b.DB.NewTransaction(true)
+	defer txn.Discard()
+
+	if err := setLoop(txn, prefix, ttl, vals...); err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// Delete removes entries for keys under prefix, missing keys are ignored
+func (b Badger) Delete(prefix string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if b.DB == nil {
+		return ErrMissingHandle
+	}
+	return b.DB.Update(func(txn *badger.Txn) error {
+		for _, k := range keys {
+			key, err := GenericValue{Key: k}.key(prefix)
+			if err != nil {
+				return err
+			}
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 type ValueHandleFunc func([]byte) error
 
 func (b Badger) GetSingle(key string, handler ValueHandleFunc) error {
@@ -261,6 +306,7 @@ func NewBadger(c Config) (*Badger, error) {
 func setLoop(
 	txn *badger.Txn,
 	prefix string,
+	ttl time.Duration,
 	vals ...GenericValue,
 ) error {
 	for _, val := range vals {
@@ -274,7 +320,11 @@ func setLoop(
 		if err != nil {
 			return err
 		}
-		err = txn.Set(key, buf.Bytes())
+		entry := badger.NewEntry(key, buf.Bytes())
+		if ttl > 0 {
+			entry = entry.WithTTL(ttl)
+		}
+		err = txn.SetEntry(entry)
 		if err != nil {
 			return err
 		}
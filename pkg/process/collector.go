@@ -1,8 +1,10 @@
 package process
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"go-peek/pkg/models/consumer"
 )
 
 var (
@@ -11,40 +13,72 @@ var (
 
 var newline = []byte("\n")
 
-type CollectBulkFullFn func(*bytes.Buffer) error
+// CollectBulkFullFn handles collected batch
+// outputs should carry ack.Hold(), ack is released for all batch inputs once they are delivered
+// origins holds input position of every line in data, without payload
+// batch ack is released even if handler fails, so unprocessed lines should be dead-lettered, see ScanLines
+type CollectBulkFullFn func(data *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error
 
 type Collector struct {
 	Data *bytes.Buffer
 	Size int
 
 	HandlerFunc CollectBulkFullFn
+
+	acks    []*consumer.Ack
+	origins []consumer.Message
 }
 
-func (h *Collector) Collect(data []byte) error {
+// Collect appends message data to batch, ack of input message is released when batch is handled
+func (h *Collector) Collect(msg *consumer.Message) error {
 	if err := h.validate(); err != nil {
 		return err
 	}
+	data := msg.Data
+	var err error
 	if len(data)+len(newline)+h.Data.Len() >= h.Size {
-		if err := h.Flush(); err != nil {
-			return err
-		}
+		// failed batch is dropped, so data still goes to the next one
+		err = h.Flush()
 	}
 	h.Data.Write(data)
+	lines := bytes.Count(data, newline)
 	if !bytes.HasSuffix(data, newline) {
 		h.Data.Write(newline)
+		lines++
 	}
-	return nil
+	origin := *msg
+	origin.Data = nil
+	origin.Ack = nil
+	for i := 0; i < lines; i++ {
+		h.origins = append(h.origins, origin)
+	}
+	if msg.Ack != nil {
+		h.acks = append(h.acks, msg.Ack)
+	}
+	return err
 }
 
+// Flush passes batch to handler and starts a new one
+// batch ack is released even if handler fails, otherwise input offsets would never be committed again
 func (h *Collector) Flush() error {
 	if err := h.validate(); err != nil {
 		return err
 	}
-	if err := h.HandlerFunc(h.Data); err != nil {
-		return err
+	var batch *consumer.Ack
+	if len(h.acks) > 0 {
+		acks := h.acks
+		batch = consumer.NewAck(func() {
+			for _, ack := range acks {
+				ack.Done()
+			}
+		})
 	}
+	err := h.HandlerFunc(h.Data, batch, h.origins)
+	h.acks = nil
+	h.origins = nil
 	h.rotate()
-	return nil
+	batch.Done()
+	return err
 }
 
 func (h *Collector) rotate() *Collector {
@@ -69,3 +103,35 @@ func (h *Collector) validate() error {
 	}
 	return nil
 }
+
+// ScanLines calls fn for every line of collected batch, i indexes line origins
+// line that can not be scanned and all lines after it are passed to fail, so they would not be dropped silently
+func ScanLines(data *bytes.Buffer, fn func(line []byte, i int), fail func(line []byte, i int, err error)) error {
+	raw := data.Bytes()
+	var pos int
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxSyslogFrame)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		pos += advance
+		return advance, token, err
+	})
+	i := 0
+	for ; scanner.Scan(); i++ {
+		fn(scanner.Bytes(), i)
+	}
+	err := scanner.Err()
+	if err == nil {
+		return nil
+	}
+	for rest := raw[pos:]; len(rest) > 0; i++ {
+		line := rest
+		if idx := bytes.IndexByte(rest, '\n'); idx >= 0 {
+			line, rest = rest[:idx], rest[idx+1:]
+		} else {
+			rest = nil
+		}
+		fail(line, i, err)
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"go-peek/pkg/models/consumer"
+	"strings"
+	"testing"
+)
+
+func TestCollectorHandlerError(t *testing.T) {
+	var released int
+	newAck := func() *consumer.Ack { return consumer.NewAck(func() { released++ }) }
+
+	var handled []string
+	c := &Collector{
+		Size: 64,
+		HandlerFunc: func(b *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error {
+			scanner := bufio.NewScanner(b)
+			scanner.Buffer(make([]byte, 16), 16)
+			for scanner.Scan() {
+				handled = append(handled, scanner.Text())
+			}
+			return scanner.Err()
+		},
+	}
+
+	// line over scanner limit fails the batch
+	if err := c.Collect(&consumer.Message{Data: []byte(strings.Repeat("x", 32)), Ack: newAck()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != bufio.ErrTooLong {
+		t.Fatalf("expected %s, got %v", bufio.ErrTooLong, err)
+	}
+	if released != 1 {
+		t.Fatalf("failed batch should release input ack, %d released", released)
+	}
+
+	// failed flush on full batch keeps incoming message for the next one
+	if err := c.Collect(&consumer.Message{Data: []byte(strings.Repeat("y", 60)), Ack: newAck()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Collect(&consumer.Message{Data: []byte("short"), Ack: newAck()}); err != bufio.ErrTooLong {
+		t.Fatalf("expected %s from full batch, got %v", bufio.ErrTooLong, err)
+	}
+	if released != 2 {
+		t.Fatalf("failed batch should release input ack, %d released", released)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if released != 3 || len(handled) != 1 || handled[0] != "short" {
+		t.Fatalf("message after failed batch not delivered, %d released, handled %v", released, handled)
+	}
+}
+
+func TestCollectorOrigins(t *testing.T) {
+	type line struct {
+		text   string
+		source string
+		offset int64
+	}
+	var handled []line
+	c := &Collector{
+		HandlerFunc: func(b *bytes.Buffer, ack *consumer.Ack, origins []consumer.Message) error {
+			scanner := bufio.NewScanner(b)
+			for i := 0; scanner.Scan(); i++ {
+				if origins[i].Data != nil || origins[i].Ack != nil {
+					t.Fatalf("origin should not hold payload or ack %+v", origins[i])
+				}
+				handled = append(handled, line{scanner.Text(), origins[i].Source, origins[i].Offset})
+			}
+			return scanner.Err()
+		},
+	}
+	for _, msg := range []*consumer.Message{
+		{Data: []byte("a"), Source: "syslog", Partition: 1, Offset: 10},
+		// message may carry several lines
+		{Data: []byte("b\nc\n"), Source: "syslog", Partition: 0, Offset: 20},
+		{Data: []byte("d"), Source: "snoopy", Partition: 2, Offset: 30},
+	} {
+		if err := c.Collect(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []line{{"a", "syslog", 10}, {"b", "syslog", 20}, {"c", "syslog", 20}, {"d", "snoopy", 30}}
+	if len(handled) != len(expected) {
+		t.Fatalf("expected %d lines, got %+v", len(expected), handled)
+	}
+	for i := range expected {
+		if handled[i] != expected[i] {
+			t.Fatalf("line %d: expected %+v, got %+v", i, expected[i], handled[i])
+		}
+	}
+}
+
+func TestScanLines(t *testing.T) {
+	long := strings.Repeat("l", 128*1024)
+	broken := strings.Repeat("x", maxSyslogFrame+1)
+	var b bytes.Buffer
+	for _, line := range []string{"a", long, broken, "b", "c"} {
+		b.WriteString(line + "\n")
+	}
+
+	var scanned, failed []int
+	err := ScanLines(&b, func(line []byte, i int) {
+		scanned = append(scanned, i)
+		if i == 1 && string(line) != long {
+			t.Fatalf("line over default scanner limit should be delivered, got %d bytes", len(line))
+		}
+	}, func(line []byte, i int, err error) {
+		failed = append(failed, i)
+		if err != bufio.ErrTooLong {
+			t.Fatalf("expected %s, got %v", bufio.ErrTooLong, err)
+		}
+		if i == 2 && string(line) != broken || i == 4 && string(line) != "c" {
+			t.Fatalf("line %d not passed on intact, got %d bytes", i, len(line))
+		}
+	})
+	if err != bufio.ErrTooLong {
+		t.Fatalf("expected %s, got %v", bufio.ErrTooLong, err)
+	}
+	// lines after failed one are handed over for dead-lettering, not dropped
+	if len(scanned) != 2 || len(failed) != 3 || failed[0] != 2 || failed[2] != 4 {
+		t.Fatalf("unexpected split, scanned %v failed %v", scanned, failed)
+	}
+}
@@ -1,17 +1,22 @@
 package process
 
 import (
+	"bytes"
 	"errors"
 	"go-peek/pkg/models/atomic"
 	"go-peek/pkg/models/events"
+	"strings"
 	"time"
 
 	"github.com/influxdata/go-syslog/v3"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
 	"github.com/influxdata/go-syslog/v3/rfc5424"
 )
 
 var (
-	ErrInvalidSyslogType = errors.New("Not RFC5424")
+	ErrInvalidSyslogType   = errors.New("Not RFC5424")
+	ErrInvalidSyslog3164   = errors.New("Not RFC3164")
+	ErrUnknownSyslogFormat = errors.New("Unknown syslog format, missing PRI header")
 )
 
 type ErrUnsupportedEventType struct {
@@ -22,12 +27,71 @@ func (e ErrUnsupportedEventType) Error() string {
 	return "Unsupported event: " + string(e.Data)
 }
 
+// SyslogFormat is syslog header format as detected from raw message
+type SyslogFormat int
+
+const (
+	FormatUnknown SyslogFormat = iota
+	FormatRFC5424
+	FormatRFC3164
+)
+
+func (f SyslogFormat) String() string {
+	switch f {
+	case FormatRFC5424:
+		return "rfc5424"
+	case FormatRFC3164:
+		return "rfc3164"
+	default:
+		return "unknown"
+	}
+}
+
+// DetectSyslogFormat decides syslog header format from PRI and VERSION fields
+// RFC5424 header has numeric VERSION followed by space right after PRI, whereas RFC3164 continues with timestamp
+func DetectSyslogFormat(data []byte) SyslogFormat {
+	data = bytes.TrimLeft(data, " \t")
+	if len(data) < 3 || data[0] != '<' {
+		return FormatUnknown
+	}
+	end := bytes.IndexByte(data, '>')
+	// PRI is between 1 and 3 digits
+	if end < 2 || end > 4 {
+		return FormatUnknown
+	}
+	rest := data[end+1:]
+	var digits int
+	for _, c := range rest {
+		if c < '0' || c > '9' {
+			break
+		}
+		digits++
+	}
+	if digits > 0 && digits < 3 && len(rest) > digits && rest[digits] == ' ' && rest[0] != '0' {
+		return FormatRFC5424
+	}
+	return FormatRFC3164
+}
+
+// NormalizerCounts tracks parsed messages and failures per syslog format
+type NormalizerCounts struct {
+	RFC5424 uint
+	RFC3164 uint
+
+	ErrRFC5424 uint
+	ErrRFC3164 uint
+	ErrUnknown uint
+}
+
 type Normalizer struct {
 	RFC5424 syslog.Machine
+	RFC3164 syslog.Machine
+
+	Counts NormalizerCounts
 }
 
-func (n Normalizer) NormalizeSyslog(data []byte) (interface{}, error) {
-	msg, err := parseRFC5424(data, n)
+func (n *Normalizer) NormalizeSyslog(data []byte) (interface{}, error) {
+	msg, err := n.parseSyslog(data)
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +105,12 @@ func (n Normalizer) NormalizeSyslog(data []byte) (interface{}, error) {
 			Syslog: *msg,
 			Snoopy: *val,
 		}, nil
+	case *atomic.Cef:
+		return &events.Cef{
+			Syslog:  *msg,
+			Cef:     *val,
+			Profile: events.CefMazeRunner,
+		}, nil
 	case atomic.Syslog, *atomic.Syslog:
 		return &events.Syslog{
 			Syslog: *msg,
@@ -50,7 +120,31 @@ func (n Normalizer) NormalizeSyslog(data []byte) (interface{}, error) {
 	}
 }
 
-func parseRFC5424(data []byte, n Normalizer) (*atomic.Syslog, error) {
+func (n *Normalizer) parseSyslog(data []byte) (*atomic.Syslog, error) {
+	switch DetectSyslogFormat(data) {
+	case FormatRFC5424:
+		msg, err := parseRFC5424(data, n)
+		if err != nil {
+			n.Counts.ErrRFC5424++
+			return nil, err
+		}
+		n.Counts.RFC5424++
+		return msg, nil
+	case FormatRFC3164:
+		msg, err := parseRFC3164(data, n)
+		if err != nil {
+			n.Counts.ErrRFC3164++
+			return nil, err
+		}
+		n.Counts.RFC3164++
+		return msg, nil
+	default:
+		n.Counts.ErrUnknown++
+		return nil, ErrUnknownSyslogFormat
+	}
+}
+
+func parseRFC5424(data []byte, n *Normalizer) (*atomic.Syslog, error) {
 	msg, err := n.RFC5424.Parse(data)
 	if err != nil {
 		return nil, err
@@ -70,9 +164,64 @@ func parseRFC5424(data []byte, n Normalizer) (*atomic.Syslog, error) {
 	}
 }
 
+func parseRFC3164(data []byte, n *Normalizer) (*atomic.Syslog, error) {
+	msg, err := n.RFC3164.Parse(data)
+	// best effort parser returns partial message with error, header is all we need
+	if msg == nil {
+		if err == nil {
+			err = ErrInvalidSyslog3164
+		}
+		return nil, err
+	}
+	switch v := msg.(type) {
+	case *rfc3164.SyslogMessage:
+		if v.Message == nil {
+			if err == nil {
+				err = ErrInvalidSyslog3164
+			}
+			return nil, err
+		}
+		return &atomic.Syslog{
+			Timestamp: checkTime(v.Timestamp),
+			Facility:  checkStr(v.FacilityMessage()),
+			Host:      checkStr(v.Hostname),
+			Program:   checkStr(v.Appname),
+			Severity:  checkStr(v.SeverityLevel()),
+			Message:   fixRFC3164Message(data, v),
+		}, nil
+	default:
+		return nil, ErrInvalidSyslog3164
+	}
+}
+
+// fixRFC3164Message restores message that starts with brackets, like snoopy does
+// rfc3164 machine matches TAG[CONTENT]: greedily, so CONTENT ends up holding the beginning of message
+func fixRFC3164Message(data []byte, v *rfc3164.SyslogMessage) string {
+	msg := checkStr(v.Message)
+	if v.ProcID == nil || v.Appname == nil || !strings.ContainsAny(*v.ProcID, "[]") {
+		return msg
+	}
+	idx := bytes.Index(data, []byte(*v.Appname+"["))
+	if idx < 0 {
+		return msg
+	}
+	rest := data[idx+len(*v.Appname)+1:]
+	end := bytes.IndexByte(rest, ']')
+	if end < 0 {
+		return msg
+	}
+	rest = bytes.TrimPrefix(rest[end+1:], []byte(":"))
+	return string(bytes.TrimPrefix(rest, []byte(" ")))
+}
+
 func NewNormalizer() *Normalizer {
 	return &Normalizer{
 		RFC5424: rfc5424.NewParser(rfc5424.WithBestEffort()),
+		RFC3164: rfc3164.NewParser(
+			rfc3164.WithBestEffort(),
+			rfc3164.WithYear(rfc3164.CurrentYear{}),
+			rfc3164.WithRFC3339(),
+		),
 	}
 }
 
@@ -0,0 +1,96 @@
+package process
+
+import (
+	"go-peek/pkg/models/events"
+	"testing"
+)
+
+var syslogSamples = []struct {
+	Raw     string
+	Format  SyslogFormat
+	Host    string
+	Program string
+	Snoopy  bool
+	Cef     bool
+}{
+	{
+		Raw:     `<13>1 2022-10-10T10:00:00.000000+00:00 web-01 sshd 123 - - Accepted publickey for root`,
+		Format:  FormatRFC5424,
+		Host:    "web-01",
+		Program: "sshd",
+	},
+	{
+		Raw:     `<38>Oct 10 10:00:00 fw-02 sshd[123]: Accepted password for admin from 10.0.0.1 port 51234 ssh2`,
+		Format:  FormatRFC3164,
+		Host:    "fw-02",
+		Program: "sshd",
+	},
+	{
+		Raw:     `<86>2022-10-10T10:00:00+00:00 db-03 cron[22]: (root) CMD (run-parts /etc/cron.hourly)`,
+		Format:  FormatRFC3164,
+		Host:    "db-03",
+		Program: "cron",
+	},
+	{
+		Raw:     `<86>Oct 10 10:00:00 ws-04 snoopy[4321]: [uid:0 sid:1 tty:(none) cwd:/ filename:/bin/ls]: ls -la`,
+		Format:  FormatRFC3164,
+		Host:    "ws-04",
+		Program: "snoopy",
+		Snoopy:  true,
+	},
+	{
+		Raw:     `<134>Oct 10 10:00:00 MazeRunner CEF:0|Cymmetria|MazeRunner|1.0|ssh-login|SSH login|10|src=10.0.0.5 dst=10.0.0.9 dvchost=decoy-01`,
+		Format:  FormatRFC3164,
+		Host:    "decoy-01",
+		Program: "MazeRunner",
+		Cef:     true,
+	},
+}
+
+func TestNormalizeSyslog(t *testing.T) {
+	n := NewNormalizer()
+	for _, sample := range syslogSamples {
+		if f := DetectSyslogFormat([]byte(sample.Raw)); f != sample.Format {
+			t.Fatalf("[%s] detected as %s, expected %s", sample.Raw, f, sample.Format)
+		}
+		obj, err := n.NormalizeSyslog([]byte(sample.Raw))
+		if err != nil {
+			t.Fatalf("[%s] %s", sample.Raw, err)
+		}
+		switch v := obj.(type) {
+		case *events.Snoopy:
+			if !sample.Snoopy {
+				t.Fatalf("[%s] unexpected snoopy event", sample.Raw)
+			}
+			if v.Syslog.Host != sample.Host || v.Syslog.Program != sample.Program {
+				t.Fatalf("[%s] header mismatch: %+v", sample.Raw, v.Syslog)
+			}
+		case *events.Cef:
+			if !sample.Cef {
+				t.Fatalf("[%s] unexpected cef event", sample.Raw)
+			}
+			if v.Sender() != sample.Host || v.Source() != sample.Program {
+				t.Fatalf("[%s] header mismatch: %+v", sample.Raw, v.Cef)
+			}
+			asset := v.GetAsset()
+			if asset.Source == nil || asset.Destination == nil || !v.Emit() {
+				t.Fatalf("[%s] missing src or dst: %+v", sample.Raw, asset)
+			}
+		case *events.Syslog:
+			if sample.Snoopy || sample.Cef {
+				t.Fatalf("[%s] expected snoopy event", sample.Raw)
+			}
+			if v.Host != sample.Host || v.Program != sample.Program {
+				t.Fatalf("[%s] header mismatch: %+v", sample.Raw, v.Syslog)
+			}
+		default:
+			t.Fatalf("[%s] unexpected type %T", sample.Raw, obj)
+		}
+	}
+	if _, err := n.NormalizeSyslog([]byte("not a syslog message")); err == nil {
+		t.Fatal("expected error for message without PRI header")
+	}
+	if n.Counts.RFC5424 != 1 || n.Counts.RFC3164 != 4 || n.Counts.ErrUnknown != 1 {
+		t.Fatalf("unexpected counts %+v", n.Counts)
+	}
+}
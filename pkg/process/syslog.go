@@ -1,83 +1,349 @@
 package process
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"sync"
 	"time"
+
+	"go-peek/pkg/models/consumer"
+	"go-peek/pkg/utils"
+)
+
+const (
+	// maxSyslogFrame limits TCP frames, anything larger is considered a broken stream
+	maxSyslogFrame = 1024 * 1024
+	// defaultIdleTimeout closes TCP connections that have not sent anything for a while
+	defaultIdleTimeout = 5 * time.Minute
+	// pollInterval is used as UDP read and TCP accept deadline, so listeners could check for context cancellation
+	pollInterval = 1 * time.Second
+)
+
+var (
+	ErrMissingSyslogListener = errors.New("missing UDP and TCP syslog listener")
+	ErrMissingWaitGroup      = errors.New("missing waitgroup")
 )
 
+type ErrSyslogFrame struct {
+	Sender net.Addr
+	Reason string
+}
+
+func (e ErrSyslogFrame) Error() string {
+	return fmt.Sprintf("invalid syslog frame from %s: %s", e.Sender, e.Reason)
+}
+
+// SyslogServerConfig is used as parameter when instanciating new SyslogServer
+// zero port value disables the listener
+type SyslogServerConfig struct {
+	UDPPort int
+	TCPPort int
+	// IdleTimeout is TCP connection read deadline, defaults to 5 minutes
+	IdleTimeout time.Duration
+}
+
+func (c SyslogServerConfig) Validate() error {
+	if c.UDPPort == 0 && c.TCPPort == 0 {
+		return ErrMissingSyslogListener
+	}
+	if c.UDPPort < 0 || c.UDPPort > 65535 {
+		return fmt.Errorf("invalid udp syslog port %d", c.UDPPort)
+	}
+	if c.TCPPort < 0 || c.TCPPort > 65535 {
+		return fmt.Errorf("invalid tcp syslog port %d", c.TCPPort)
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("invalid tcp syslog idle timeout %s", c.IdleTimeout)
+	}
+	return nil
+}
+
+// SyslogServer receives raw syslog lines over UDP and TCP
+// messages are not parsed, only framing is handled so output could be fed to Normalizer
+// sender address of each message is preserved in consumer.Message
 type SyslogServer struct {
-	Listener  *net.UDPConn
-	Collector *Collector
-	Errors    chan error
+	UDP    *net.UDPConn
+	TCP    *net.TCPListener
+	Errors chan error
+
+	idleTimeout time.Duration
+	messages    chan *consumer.Message
 }
 
+// Messages implements consumer.Messager
+// channel is closed once all listeners have exited
+func (s SyslogServer) Messages() <-chan *consumer.Message { return s.messages }
+
 func (s SyslogServer) Run(wg *sync.WaitGroup, ctx context.Context) error {
-	if s.Listener == nil {
-		return errors.New("missing UDP handler")
+	if s.UDP == nil && s.TCP == nil {
+		return ErrMissingSyslogListener
 	}
 	if wg == nil {
-		return errors.New("missing waitgroup")
+		return ErrMissingWaitGroup
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var local sync.WaitGroup
+	if s.UDP != nil {
+		local.Add(1)
+		go func() {
+			defer local.Done()
+			s.runUDP(ctx)
+		}()
+	}
+	if s.TCP != nil {
+		local.Add(1)
+		go func() {
+			defer local.Done()
+			s.runTCP(ctx, &local)
+		}()
 	}
 	wg.Add(1)
-	go func(ctx context.Context) {
+	go func() {
 		defer wg.Done()
-		defer s.Listener.Close()
+		local.Wait()
+		close(s.messages)
+	}()
+	return nil
+}
 
-		buf := make([]byte, 1024*64)
-	loop:
-		for {
-			select {
-			case <-ctx.Done():
+func (s SyslogServer) runUDP(ctx context.Context) {
+	defer s.UDP.Close()
+	buf := make([]byte, 1024*64)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+		}
+		s.UDP.SetDeadline(time.Now().Add(pollInterval))
+		n, addr, err := s.UDP.ReadFromUDP(buf)
+		if err != nil {
+			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+				continue loop
+			}
+			s.sendErr(err)
+			continue loop
+		}
+		var sender net.IP
+		if addr != nil {
+			sender = addr.IP
+		}
+		// a single datagram may hold multiple newline separated messages
+		for _, line := range bytes.Split(buf[:n], newline) {
+			if !s.emit(ctx, line, sender) {
 				break loop
-			default:
 			}
-			s.Listener.SetDeadline(time.Now().Add(1e9))
-			n, _, err := s.Listener.ReadFromUDP(buf)
-			if err != nil {
-				if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
-					continue loop
-				}
-				select {
-				case s.Errors <- err:
-				default:
-				}
+		}
+	}
+}
+
+func (s SyslogServer) runTCP(ctx context.Context, wg *sync.WaitGroup) {
+	defer s.TCP.Close()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		default:
+		}
+		s.TCP.SetDeadline(time.Now().Add(pollInterval))
+		conn, err := s.TCP.Accept()
+		if err != nil {
+			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+				continue loop
 			}
-			if err := s.Collector.Collect(buf[:n]); err != nil {
-				select {
-				case s.Errors <- err:
-				default:
-				}
+			s.sendErr(err)
+			continue loop
+		}
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			s.handleTCP(ctx, conn)
+		}(conn)
+	}
+}
+
+func (s SyslogServer) handleTCP(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	var sender net.IP
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		sender = addr.IP
+	}
+	// blocking reads are interrupted by closing the connection on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		frame, err := ReadSyslogFrame(reader)
+		if err != nil {
+			// idle clients are dropped quietly, they are expected to reconnect
+			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+				return
 			}
+			if err != io.EOF && ctx.Err() == nil {
+				s.sendErr(ErrSyslogFrame{Sender: conn.RemoteAddr(), Reason: err.Error()})
+			}
+			return
 		}
-	}(ctx)
-	return nil
+		if !s.emit(ctx, frame, sender) {
+			return
+		}
+	}
+}
+
+func (s SyslogServer) emit(ctx context.Context, data []byte, sender net.IP) bool {
+	data = bytes.TrimRight(data, "\r\n")
+	if len(data) == 0 {
+		return true
+	}
+	msg := &consumer.Message{
+		Data:   utils.DeepCopyBytes(data),
+		Type:   consumer.Syslog,
+		Source: "syslog",
+		Time:   time.Now(),
+		Sender: sender,
+	}
+	select {
+	case s.messages <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s SyslogServer) sendErr(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+	}
 }
 
-func NewSyslogServer(fn CollectBulkFullFn, port int) (*SyslogServer, error) {
-	if fn == nil {
-		return nil, errors.New("missing bulk handler func")
+// ReadSyslogFrame reads a single syslog message from TCP stream as described in RFC6587
+// octet-counting is used when frame starts with a digit, otherwise message is newline terminated
+// both are limited to maxSyslogFrame, so a client that never sends a newline could not exhaust memory
+func ReadSyslogFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		// skip stray newlines between frames
+		if b[0] != '\n' && b[0] != '\r' {
+			break
+		}
+		r.Discard(1)
 	}
-	if port <= 0 || port >= 49151 {
-		return nil, fmt.Errorf("invalid udp syslog port %d", port)
+	b, _ := r.Peek(1)
+	if b[0] < '0' || b[0] > '9' {
+		return readSyslogLine(r)
 	}
-	server := &SyslogServer{}
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("0.0.0.0:%d", port))
+	// octet counting, MSG-LEN SP SYSLOG-MSG
+	head, err := peekOctetCount(r)
 	if err != nil {
 		return nil, err
 	}
-	listener, err := net.ListenUDP("udp", addr)
+	size, err := strconv.Atoi(string(head))
 	if err != nil {
 		return nil, err
 	}
-	server.Listener = listener
-	server.Collector = &Collector{
-		Size:        256 * 1024,
-		HandlerFunc: fn,
+	if size > maxSyslogFrame {
+		return nil, fmt.Errorf("octet count %d exceeds max frame size %d", size, maxSyslogFrame)
+	}
+	r.Discard(len(head) + 1)
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func readSyslogLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxSyslogFrame {
+			return nil, fmt.Errorf("line exceeds max frame size %d", maxSyslogFrame)
+		}
+		line = append(line, chunk...)
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(line) > 0:
+			return line, nil
+		}
+		return line, err
+	}
+}
+
+func peekOctetCount(r *bufio.Reader) ([]byte, error) {
+	for i := 1; i <= 8; i++ {
+		b, err := r.Peek(i)
+		if err != nil {
+			return nil, err
+		}
+		switch c := b[i-1]; {
+		case c == ' ':
+			return b[:i-1], nil
+		case c < '0' || c > '9':
+			return nil, fmt.Errorf("invalid octet count %q", b)
+		}
+	}
+	return nil, errors.New("octet count too long")
+}
+
+func NewSyslogServer(c SyslogServerConfig) (*SyslogServer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	server := &SyslogServer{
+		Errors:      make(chan error, 10),
+		idleTimeout: c.IdleTimeout,
+		messages:    make(chan *consumer.Message, 1024),
+	}
+	if server.idleTimeout == 0 {
+		server.idleTimeout = defaultIdleTimeout
+	}
+	if c.UDPPort > 0 {
+		addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("0.0.0.0:%d", c.UDPPort))
+		if err != nil {
+			return nil, err
+		}
+		listener, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		server.UDP = listener
+	}
+	if c.TCPPort > 0 {
+		addr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("0.0.0.0:%d", c.TCPPort))
+		if err != nil {
+			return nil, err
+		}
+		listener, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			if server.UDP != nil {
+				server.UDP.Close()
+			}
+			return nil, err
+		}
+		server.TCP = listener
 	}
-	server.Errors = make(chan error, 10)
 	return server, nil
 }
@@ -0,0 +1,95 @@
+package process
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go-peek/pkg/models/consumer"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadSyslogFrame(t *testing.T) {
+	stream := "<13>1 2022-10-10T10:00:00Z host app - - - first\n" +
+		"48 <13>1 2022-10-10T10:00:01Z host app - - - second" +
+		"\r\n<13>Oct 10 10:00:02 host app: third"
+	expected := []string{
+		"<13>1 2022-10-10T10:00:00Z host app - - - first\n",
+		"<13>1 2022-10-10T10:00:01Z host app - - - second",
+		"<13>Oct 10 10:00:02 host app: third",
+	}
+	r := bufio.NewReader(strings.NewReader(stream))
+	for i, want := range expected {
+		frame, err := ReadSyslogFrame(r)
+		if err != nil {
+			t.Fatalf("frame %d: %s", i, err)
+		}
+		if string(frame) != want {
+			t.Fatalf("frame %d: got [%s] want [%s]", i, string(frame), want)
+		}
+	}
+	if _, err := ReadSyslogFrame(r); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestReadSyslogFrameLimit(t *testing.T) {
+	// newline framed message is capped like octet-counted one
+	r := bufio.NewReader(strings.NewReader("<13>" + strings.Repeat("x", maxSyslogFrame)))
+	if _, err := ReadSyslogFrame(r); err == nil {
+		t.Fatal("unterminated line over max frame size should fail")
+	}
+	r = bufio.NewReader(strings.NewReader(fmt.Sprintf("%d <13>", maxSyslogFrame+1)))
+	if _, err := ReadSyslogFrame(r); err == nil {
+		t.Fatal("octet count over max frame size should fail")
+	}
+	line := "<13>" + strings.Repeat("x", 128*1024) + "\n"
+	r = bufio.NewReader(strings.NewReader(line))
+	if frame, err := ReadSyslogFrame(r); err != nil || string(frame) != line {
+		t.Fatalf("line over reader buffer should be read whole, got %d bytes, %v", len(frame), err)
+	}
+}
+
+func TestSyslogServerIdleTimeout(t *testing.T) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := SyslogServer{
+		TCP:         ln,
+		Errors:      make(chan error, 10),
+		idleTimeout: 100 * time.Millisecond,
+		messages:    make(chan *consumer.Message, 10),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	if err := s.Run(&wg, ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("<13>Oct 10 10:00:02 host app: partial")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("idle connection should be closed by server, got %v", err)
+	}
+	select {
+	case err := <-s.Errors:
+		t.Fatalf("idle timeout should not be reported, got %s", err)
+	default:
+	}
+}
@@ -0,0 +1,17 @@
+#!/bin/bash
+# fetch pinned MITRE ATT&CK STIX bundles for embedding into peek binary
+# usage: fetch-mitre-attack.sh <bundle dir>
+# release tag is read from VERSION file in bundle dir
+set -euo pipefail
+
+dir=${1:-pkg/intel/mitre/bundle}
+version=$(tr -d '[:space:]' < "$dir/VERSION")
+tag=$(printf '%s' "$version" | sed 's/&/%26/g')
+
+for domain in enterprise-attack ics-attack; do
+    url="https://raw.githubusercontent.com/mitre/cti/${tag}/${domain}/${domain}.json"
+    echo "fetching $url"
+    # keep previous bundle if fetch fails midway
+    curl -sSfL "$url" | gzip -9 > "$dir/${domain}.json.gz.tmp"
+    mv "$dir/${domain}.json.gz.tmp" "$dir/${domain}.json.gz"
+done